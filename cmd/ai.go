@@ -0,0 +1,408 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/productdevtool/pdt-cli/pkg/ai"
+	"github.com/productdevtool/pdt-cli/pkg/prompt"
+	"github.com/productdevtool/pdt-cli/pkg/task"
+	"github.com/productdevtool/pdt-cli/pkg/ui"
+	"github.com/productdevtool/pdt-cli/pkg/usage"
+	"github.com/spf13/cobra"
+)
+
+// aiConfig builds the provider configuration from PDT_AI_* environment variables,
+// with the --provider and --model flags taking precedence.
+func aiConfig() ai.Config {
+	cfg := ai.Config{
+		Provider: setting("PDT_AI_PROVIDER"),
+		Model:    setting("PDT_AI_MODEL"),
+		Command:  setting("PDT_AI_COMMAND"),
+		BaseURL:  setting("PDT_AI_BASE_URL"),
+		APIKey:   os.Getenv("PDT_AI_API_KEY"),
+
+		Input:          setting("PDT_AI_INPUT"),
+		PromptFileFlag: setting("PDT_AI_PROMPT_FILE_FLAG"),
+	}
+	if n, err := strconv.Atoi(setting("PDT_AI_MAX_PROMPT_BYTES")); err == nil {
+		cfg.MaxPromptBytes = n
+	}
+	if cfg.APIKey == "" {
+		cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+	}
+	if providerFlag != "" {
+		cfg.Provider = providerFlag
+	}
+	if modelFlag != "" {
+		cfg.Model = modelFlag
+	}
+	return cfg
+}
+
+// retryPolicy reads PDT_AI_MAX_RETRIES and PDT_AI_RETRY_BUDGET on top of the default policy.
+func retryPolicy() (ai.RetryPolicy, error) {
+	policy := ai.DefaultRetryPolicy()
+	if value := setting("PDT_AI_MAX_RETRIES"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return policy, fmt.Errorf("invalid PDT_AI_MAX_RETRIES '%s': expected a non-negative number", value)
+		}
+		policy.MaxRetries = n
+	}
+	if value := setting("PDT_AI_RETRY_BUDGET"); value != "" {
+		budget, err := time.ParseDuration(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid PDT_AI_RETRY_BUDGET '%s': %w", value, err)
+		}
+		policy.Budget = budget
+	}
+	return policy, nil
+}
+
+// responseCache returns the cache configured by PDT_CACHE_DIR, PDT_CACHE_TTL and PDT_CACHE_MAX_SIZE.
+func responseCache() (*ai.Cache, error) {
+	cache := &ai.Cache{Dir: ".pdt/cache", TTL: 24 * time.Hour, MaxBytes: 100 << 20}
+	if dir := setting("PDT_CACHE_DIR"); dir != "" {
+		cache.Dir = dir
+	}
+	if value := setting("PDT_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PDT_CACHE_TTL '%s': %w", value, err)
+		}
+		cache.TTL = ttl
+	}
+	if value := setting("PDT_CACHE_MAX_SIZE"); value != "" {
+		size, err := parseByteSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PDT_CACHE_MAX_SIZE '%s': %w", value, err)
+		}
+		cache.MaxBytes = size
+	}
+	return cache, nil
+}
+
+// parseByteSize parses sizes such as "512", "64KB", "100MB" or "1GB".
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a size such as 100MB")
+	}
+	return n * multiplier, nil
+}
+
+// usageLedger returns the ledger configured by PDT_USAGE_LEDGER.
+func usageLedger() *usage.Ledger {
+	path := setting("PDT_USAGE_LEDGER")
+	if path == "" {
+		path = ".pdt/usage.jsonl"
+	}
+	return &usage.Ledger{Path: path}
+}
+
+// monthlyBudget returns the monthly spending cap from PDT_USAGE_MONTHLY_BUDGET; 0 means none.
+func monthlyBudget() (float64, error) {
+	value := setting("PDT_USAGE_MONTHLY_BUDGET")
+	if value == "" {
+		return 0, nil
+	}
+	budget, err := strconv.ParseFloat(strings.TrimPrefix(value, "$"), 64)
+	if err != nil || budget < 0 {
+		return 0, fmt.Errorf("invalid PDT_USAGE_MONTHLY_BUDGET '%s': expected an amount in US dollars", value)
+	}
+	return budget, nil
+}
+
+// newMeter wraps provider so every live call made by cmd is recorded in the usage ledger.
+func newMeter(cmd *cobra.Command, provider ai.Provider) (ai.Provider, error) {
+	prices, err := usage.LoadPrices(setting("PDT_USAGE_PRICES"))
+	if err != nil {
+		return nil, err
+	}
+	budget, err := monthlyBudget()
+	if err != nil {
+		return nil, err
+	}
+
+	meter := usage.NewMeter(provider, usageLedger(), prices)
+	meter.Command = cmd.Name()
+	if activeTaskDir, err := task.GetActiveTask(projectConfig.Paths.WorkDir()); err == nil {
+		meter.Task = filepath.Base(activeTaskDir)
+	}
+	meter.MonthlyBudget = budget
+	meter.OnAlert = func(spent float64, budget float64) {
+		color.Yellow("AI spend this month is $%.2f of the $%.2f budget.", spent, budget)
+	}
+	meter.OnUnpriced = func(target string) {
+		color.Yellow("Warning: %s has no price, so its calls don't count toward the monthly budget; add it to the PDT_USAGE_PRICES file.", target)
+	}
+	meter.OnError = func(err error) {
+		color.Yellow("Warning: could not record AI usage: %v", err)
+	}
+	return meter, nil
+}
+
+// aiRoute returns the targets that handle cmd's prompts, in the order they are tried,
+// and a description of where the first one came from. The --provider and --model
+// flags win over a PDT_AI_ROUTES entry for the command, which wins over the default
+// provider; PDT_AI_FALLBACK lists the targets to fall back to.
+func aiRoute(cmd *cobra.Command) ([]ai.Target, string, error) {
+	cfg := aiConfig()
+	primary := ai.Target{Provider: cfg.Provider, Model: cfg.Model}
+	if primary.Provider == "" {
+		primary.Provider = ai.ProviderGemini
+	}
+	source := "default provider"
+
+	routes, err := ai.ParseRoutes(setting("PDT_AI_ROUTES"))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid PDT_AI_ROUTES: %w", err)
+	}
+	arg := ""
+	if args := cmd.Flags().Args(); len(args) > 0 {
+		arg = args[0]
+	}
+	if route, key, ok := routes.Lookup(cmd.Name(), arg); ok && providerFlag == "" {
+		if route.Provider != primary.Provider && modelFlag == "" {
+			primary.Model = ""
+		}
+		primary.Provider = route.Provider
+		if route.Model != "" && modelFlag == "" {
+			primary.Model = route.Model
+		}
+		source = fmt.Sprintf("route '%s'", key)
+	} else if providerFlag != "" || modelFlag != "" {
+		source = "command-line flags"
+	}
+
+	fallbacks, err := ai.ParseTargets(setting("PDT_AI_FALLBACK"))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid PDT_AI_FALLBACK: %w", err)
+	}
+	targets := []ai.Target{primary}
+	for _, target := range fallbacks {
+		if target != primary {
+			targets = append(targets, target)
+		}
+	}
+	return targets, source, nil
+}
+
+// targetProvider returns the provider for one target, sharing the rest of the AI
+// configuration, with transient failures retried.
+func targetProvider(target ai.Target, policy ai.RetryPolicy) (ai.Provider, error) {
+	cfg := aiConfig()
+	cfg.Provider, cfg.Model = target.Provider, target.Model
+	base, err := ai.NewProvider(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("AI target '%s': %w", target, err)
+	}
+
+	retrying := ai.NewRetrying(base, policy)
+	retrying.OnRetry = func(attempt int, err *ai.Error, delay time.Duration) {
+		color.Yellow("%s hit a %s error, retrying in %v (retry %d of %d)...", err.Provider, err.Kind, delay.Round(100*time.Millisecond), attempt, policy.MaxRetries)
+	}
+	return retrying, nil
+}
+
+// newProvider returns the AI provider that handles cmd's prompts, with transient
+// failures retried, failed providers falling back to the next target, live calls
+// recorded in the usage ledger, identical prompts served from the response cache,
+// and calls recorded or replayed when PDT_AI_MODE asks for it.
+func newProvider(cmd *cobra.Command) (ai.Provider, error) {
+	targets, source, err := aiRoute(cmd)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := retryPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []ai.Provider
+	for _, target := range targets {
+		p, err := targetProvider(target, policy)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+
+	var provider ai.Provider = providers[0]
+	if len(providers) > 1 {
+		fallback := ai.NewFallback(providers[0], providers[1:]...)
+		fallback.OnFallback = func(failed ai.Provider, err error, next ai.Provider) {
+			color.Yellow("%s failed: %v", describeProvider(failed.Name(), failed.Model()), err)
+			color.Yellow("Falling back to %s...", describeProvider(next.Name(), next.Model()))
+		}
+		provider = fallback
+
+		var names []string
+		for _, target := range targets[1:] {
+			names = append(names, target.String())
+		}
+		color.Cyan("Using %s (%s), falling back to %s.", describeProvider(provider.Name(), provider.Model()), source, strings.Join(names, ", "))
+	} else {
+		color.Cyan("Using %s (%s).", describeProvider(provider.Name(), provider.Model()), source)
+	}
+
+	provider, err = newMeter(cmd, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	if !noCacheFlag && setting("PDT_CACHE_ENABLED") != "false" {
+		cache, err := responseCache()
+		if err != nil {
+			return nil, err
+		}
+		provider = ai.NewCaching(provider, cache)
+	}
+
+	cassetteDir := setting("PDT_AI_CASSETTE_DIR")
+	if cassetteDir == "" {
+		cassetteDir = ".pdt/cassettes"
+	}
+	return ai.NewRecorder(provider, setting("PDT_AI_MODE"), cassetteDir)
+}
+
+// describeProvider names a provider and its model for messages.
+func describeProvider(name string, model string) string {
+	if model == "" {
+		return name + " (default model)"
+	}
+	return name + "/" + model
+}
+
+// contextBudget returns the prompt token budget: PDT_AI_CONTEXT_BUDGET, or a default
+// derived from the provider's context window.
+func contextBudget(provider ai.Provider) (int, error) {
+	if value := setting("PDT_AI_CONTEXT_BUDGET"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid PDT_AI_CONTEXT_BUDGET '%s': expected a number of tokens", value)
+		}
+		return n, nil
+	}
+	return ai.DefaultContextBudget(provider.Name(), provider.Model()), nil
+}
+
+// complete checks a prompt against the context budget, dropping low-priority sections
+// when it doesn't fit (unless PDT_AI_TRIM_PROMPTS=false), and sends it to the
+// configured AI provider on behalf of cmd.
+func complete(cmd *cobra.Command, p *prompt.Prompt) (*ai.Response, error) {
+	return send(cmd, p, false)
+}
+
+// send is complete with an option to stream the response to the console as it is
+// generated in a live progress view.
+func send(cmd *cobra.Command, p *prompt.Prompt, stream bool) (*ai.Response, error) {
+	provider, err := newProvider(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	budget, err := contextBudget(provider)
+	if err != nil {
+		return nil, err
+	}
+	trim := setting("PDT_AI_TRIM_PROMPTS") != "false"
+	count := func(text string) int {
+		return ai.EstimateTokens(provider.Name(), text)
+	}
+	p, report, err := prompt.Fit(p, budget, trim, count)
+	if err != nil {
+		color.Yellow("Estimated prompt size by section:\n%s", report)
+		return nil, err
+	}
+	if report.Trimmed() {
+		color.Yellow("The prompt exceeded the context budget, so lower-priority sections were dropped:\n%s", report)
+	}
+
+	req := ai.Request{Prompt: p.String()}
+	if !stream {
+		resp, err := provider.Complete(cmd.Context(), req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Cached {
+			color.Cyan("Using cached AI response (run with --no-cache to regenerate).")
+		}
+		color.Cyan("Response from %s.", describeProvider(resp.Provider, resp.Model))
+		return resp, nil
+	}
+
+	progress := ui.NewProgress(color.Output, ui.Options{
+		Quiet: quietMode(),
+		Live:  isatty.IsTerminal(os.Stdout.Fd()),
+		Count: count,
+	})
+	req.OnChunk = func(c ai.Chunk) {
+		if c.Restart {
+			progress.Restart()
+			return
+		}
+		progress.Stream(c.Text)
+	}
+
+	// Route other output, such as retry notices, above the status line.
+	output := color.Output
+	color.Output = progress
+	progress.Start()
+	resp, err := provider.Complete(cmd.Context(), req)
+	progress.Stop()
+	color.Output = output
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := resp.ResponseTokens
+	if tokens == 0 {
+		tokens = progress.Tokens()
+	}
+	if resp.Cached {
+		color.Cyan("Using cached AI response (run with --no-cache to regenerate).")
+	}
+	color.Green("AI output received from %s in %v (~%d tokens, %d file blocks).", describeProvider(resp.Provider, resp.Model), progress.Elapsed().Round(100*time.Millisecond), tokens, len(progress.Files()))
+	return resp, nil
+}
+
+// reportAIError prints a failed AI call along with a hint for failures the user can fix.
+func reportAIError(message string, err error) {
+	color.Red("%s: %v", message, err)
+
+	var aiErr *ai.Error
+	if !errors.As(err, &aiErr) {
+		return
+	}
+	switch aiErr.Kind {
+	case ai.KindAuth:
+		color.Yellow("Check the credentials for %s (for HTTP providers, PDT_AI_API_KEY).", aiErr.Provider)
+	case ai.KindQuota:
+		color.Yellow("The %s quota is exhausted. Wait for it to reset or switch with --provider.", aiErr.Provider)
+	case ai.KindRateLimit:
+		color.Yellow("%s is still rate limiting requests. Try again later or raise PDT_AI_MAX_RETRIES.", aiErr.Provider)
+	case ai.KindMalformed:
+		color.Yellow("%s returned unusable output. Re-running the command usually helps.", aiErr.Provider)
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspects and clears the AI response cache.",
+	Long:  "Identical prompts are answered from an on-disk cache so re-running a command after an interruption is instant and free. Use these subcommands to inspect or clear it.",
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Shows how many responses are cached and how much space they use.",
+	Run: func(cmd *cobra.Command, args []string) {
+		cache, err := responseCache()
+		if err != nil {
+			color.Red("Error configuring the response cache: %v", err)
+			os.Exit(1)
+		}
+
+		stats, err := cache.Stats()
+		if err != nil {
+			color.Red("Error reading the response cache: %v", err)
+			os.Exit(1)
+		}
+
+		color.Cyan("Cache directory: %s", cache.Dir)
+		if stats.Entries == 0 {
+			color.Yellow("The cache is empty.")
+			return
+		}
+		color.Green("Entries: %d (%d expired)", stats.Entries, stats.Expired)
+		if cache.MaxBytes > 0 {
+			color.Green("Size: %.1f MB of %.1f MB", float64(stats.Bytes)/(1<<20), float64(cache.MaxBytes)/(1<<20))
+		} else {
+			color.Green("Size: %.1f MB", float64(stats.Bytes)/(1<<20))
+		}
+		color.Green("Oldest entry: %s", stats.Oldest.Format(time.RFC3339))
+		color.Green("Newest entry: %s", stats.Newest.Format(time.RFC3339))
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Removes every cached AI response.",
+	Run: func(cmd *cobra.Command, args []string) {
+		cache, err := responseCache()
+		if err != nil {
+			color.Red("Error configuring the response cache: %v", err)
+			os.Exit(1)
+		}
+
+		removed, err := cache.Clear()
+		if err != nil {
+			color.Red("Error clearing the response cache: %v", err)
+			os.Exit(1)
+		}
+		color.Green("Removed %d cached responses from %s.", removed, cache.Dir)
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -1,33 +1,47 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
-	"github.com/productdevtool/pdt-cli/pkg/ai"
+	"github.com/productdevtool/pdt-cli/pkg/diag"
 	"github.com/productdevtool/pdt-cli/pkg/fs"
 	"github.com/productdevtool/pdt-cli/pkg/prompt"
+	"github.com/productdevtool/pdt-cli/pkg/runner"
 	"github.com/productdevtool/pdt-cli/pkg/task"
 	"github.com/spf13/cobra"
 )
 
+// defaultFixAttempts is how many times the AI is asked to fix failing validation.
+const defaultFixAttempts = 3
+
+var (
+	fixAttemptsFlag int
+	jobsFlag        int
+)
+
 var codeCmd = &cobra.Command{
 	Use:   "code",
 	Short: "Executes the implementation plan, instructing the AI to perform the necessary coding, testing, and validation.",
 	Long:  "This command executes the implementation plan, instructing the AI to perform the necessary coding, testing, and validation.",
 	Run: func(cmd *cobra.Command, args []string) {
-		activeTaskDir, err := task.GetActiveTask()
+		activeTaskDir, err := task.GetActiveTask(projectConfig.Paths.WorkDir())
 		if err != nil {
 			color.Red("Error getting active task: %v", err)
 			os.Exit(1)
 		}
 
-		projectDescriptionPath := "docs/project-description.md"
+		projectDescriptionPath := projectConfig.Paths.ProjectDescription
 		taskPath := filepath.Join(activeTaskDir, "task.md")
 
 		// Build the master implementation prompt
@@ -38,80 +52,366 @@ var codeCmd = &cobra.Command{
 		}
 
 		color.Cyan("Generating code with AI...")
-		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-		s.Start()
-
-		aiOutput, err := ai.Executor("gemini-cli", masterPrompt)
+		result, err := generateFiles(cmd, masterPrompt)
 		if err != nil {
-			s.Stop()
-			color.Red("Error executing AI prompt: %v", err)
-			os.Exit(1)
+			reportAIError("Error executing AI prompt", err)
+			os.Exit(exitStatus(cmd))
 		}
 
-		s.Stop()
-		color.Green("AI output received. Parsing code blocks...")
-
-		codeBlocks, err := fs.ExtractCodeBlocks(aiOutput)
-		if err != nil {
-			color.Red("Error extracting code blocks from AI output: %v", err)
-			os.Exit(1)
+		if cmd.Context().Err() != nil {
+			color.Yellow("Interrupted before any files were written; the workspace is unchanged.")
+			os.Exit(exitStatus(cmd))
 		}
 
-		for _, block := range codeBlocks {
-			if block.FilePath == "" {
-				color.Yellow("Skipping code block with no file path:\n%s", block.Content)
-				continue
-			}
-
-			fullPath := filepath.Join(activeTaskDir, block.FilePath)
-			// Ensure directory exists
-			err = os.MkdirAll(filepath.Dir(fullPath), 0755)
-			if err != nil {
-				color.Red("Error creating directory for %s: %v", fullPath, err)
-				continue
-			}
-
-			// Write content to file
-			err = os.WriteFile(fullPath, []byte(block.Content), 0644)
-			if err != nil {
-				color.Red("Error writing to file %s: %v", fullPath, err)
-				continue
-			}
-			color.Green("Wrote code to %s", fullPath)
+		changes := writeOperations(cmd, activeTaskDir, result.Operations, "code")
+		if dryRunFlag {
+			color.Yellow("Dry run: skipping automated validation.")
+			return
 		}
 
 		color.Green("Code generation complete.")
 
 		// Automated Validation (Task 4.2)
-		validationCommands, err := fs.GetValidationCommands()
+		validationCommands, err := projectConfig.ValidationCommands()
 		if err != nil {
 			color.Red("Error getting validation commands: %v", err)
 			os.Exit(1)
 		}
 
-		if len(validationCommands) > 0 {
+		if len(validationCommands) == 0 {
+			color.Yellow("No automated validation commands found in pdt.yaml or %s.", projectDescriptionPath)
+			return
+		}
+
+		maxAttempts, err := fixAttempts(cmd)
+		if err != nil {
+			color.Red("%v", err)
+			os.Exit(1)
+		}
+		jobs, err := validationJobs(cmd, validationCommands)
+		if err != nil {
+			color.Red("%v", err)
+			os.Exit(1)
+		}
+		files := changedFiles(nil, changes)
+		var attempts []string
+		previous := ""
+		for {
 			color.Cyan("Running automated validation...")
-			for _, valCmd := range validationCommands {
-				color.Cyan("Executing: %s", valCmd)
-				cmdParts := strings.Fields(valCmd)
-				valExecCmd := exec.Command(cmdParts[0], cmdParts[1:]...)
-				valExecCmd.Stdout = os.Stdout
-				valExecCmd.Stderr = os.Stderr
-
-				if err := valExecCmd.Run(); err != nil {
-					color.Red("Validation command failed: %v", err)
-					// TODO: Implement AI re-prompting and retry logic here
-					color.Yellow("Automated validation failed. Please review the output and fix the issues.")
+			failures, passed := runValidation(cmd, validationCommands, jobs)
+			if passed {
+				printFixSummary(attempts)
+				color.Green("Automated validation passed.")
+				return
+			}
+			if cmd.Context().Err() != nil {
+				os.Exit(exitStatus(cmd))
+			}
+
+			failed, output, parsed := describeFailures(failures)
+			printFailures(parsed)
+			signature := failed + "\n" + errorSignature(output)
+			switch {
+			case len(attempts) >= maxAttempts:
+				if maxAttempts > 0 {
+					color.Red("Validation still fails after %d fix attempts.", maxAttempts)
+				}
+			case signature == previous:
+				color.Red("The last fix didn't change the error, so pdt is giving up.")
+			default:
+				previous = signature
+				color.Yellow("Validation failed; asking the AI to fix it (attempt %d of %d)...", len(attempts)+1, maxAttempts)
+				repairPrompt, err := prompt.ValidationRepairPrompt(projectDescriptionPath, taskPath, prompt.ValidationFailure{
+					Command: failed, Output: output, Failures: parsed, Files: files, Attempts: attempts,
+				})
+				if err != nil {
+					color.Red("Error building the validation repair prompt: %v", err)
 					os.Exit(1)
 				}
+				result, err := generateFiles(cmd, repairPrompt)
+				if err != nil {
+					reportAIError("Error asking the AI to fix the validation failure", err)
+					os.Exit(exitStatus(cmd))
+				}
+				if cmd.Context().Err() != nil {
+					os.Exit(exitStatus(cmd))
+				}
+				fixed := writeOperations(cmd, activeTaskDir, result.Operations, "code")
+				files = changedFiles(files, fixed)
+				attempts = append(attempts, describeAttempt(failed, output, parsed, fixed))
+				continue
 			}
-			color.Green("Automated validation passed.")
-		} else {
-			color.Yellow("No automated validation commands found in project-description.md.")
+
+			printFixSummary(attempts)
+			undo := "run `pdt undo` to roll back the generated files"
+			if len(attempts) > 0 {
+				undo = fmt.Sprintf("run `pdt undo` %d times to roll back the generated files and the fixes", len(attempts)+1)
+			}
+			color.Yellow("Automated validation failed. Please review the output and fix the issues, or %s.", undo)
+			os.Exit(exitStatus(cmd))
 		}
 	},
 }
 
 func init() {
+	addGenerationFlags(codeCmd)
+	codeCmd.Flags().IntVar(&fixAttemptsFlag, "fix-attempts", defaultFixAttempts, "How many times to ask the AI to fix failing validation, 0 to never; defaults to $PDT_VALIDATION_FIX_ATTEMPTS")
+	codeCmd.Flags().IntVarP(&jobsFlag, "jobs", "j", 0, "How many validation commands to run at once; defaults to $PDT_VALIDATION_JOBS, or up to 4 depending on the CPUs for steps in pdt.yaml or that use needs, and 1 otherwise")
 	rootCmd.AddCommand(codeCmd)
 }
+
+// fixAttempts returns the --fix-attempts flag, then PDT_VALIDATION_FIX_ATTEMPTS.
+func fixAttempts(cmd *cobra.Command) (int, error) {
+	value := setting("PDT_VALIDATION_FIX_ATTEMPTS")
+	if cmd.Flags().Changed("fix-attempts") || value == "" {
+		return fixAttemptsFlag, nil
+	}
+	attempts, err := strconv.Atoi(value)
+	if err != nil || attempts < 0 {
+		return 0, fmt.Errorf("invalid PDT_VALIDATION_FIX_ATTEMPTS '%s': expected a number of attempts", value)
+	}
+	return attempts, nil
+}
+
+// validationJobs returns the --jobs flag, then PDT_VALIDATION_JOBS, then the number
+// of CPUs up to 4. Without either, a list from the project description that doesn't
+// use needs runs one at a time, as its steps may rely on the ones before them.
+func validationJobs(cmd *cobra.Command, commands []runner.Command) (int, error) {
+	if cmd.Flags().Changed("jobs") {
+		if jobsFlag < 1 {
+			return 0, fmt.Errorf("invalid --jobs %d: expected at least 1", jobsFlag)
+		}
+		return jobsFlag, nil
+	}
+	if value := setting("PDT_VALIDATION_JOBS"); value != "" {
+		jobs, err := strconv.Atoi(value)
+		if err != nil || jobs < 1 {
+			return 0, fmt.Errorf("invalid PDT_VALIDATION_JOBS '%s': expected a number of commands", value)
+		}
+		return jobs, nil
+	}
+	if projectConfig.Validation.Steps == nil && !runner.HasNeeds(commands) {
+		return 1, nil
+	}
+	jobs := runtime.NumCPU()
+	if jobs > 4 {
+		jobs = 4
+	}
+	return jobs, nil
+}
+
+// runValidation runs the validation commands, jobs at a time, showing their output
+// as they run, then prints a summary table and saves the report. It returns the
+// commands that failed and whether validation passed; a command that continues on
+// error is reported but doesn't count as a failure.
+func runValidation(cmd *cobra.Command, commands []runner.Command, jobs int) ([]runner.Result, bool) {
+	r := &runner.Runner{Stdout: os.Stdout, Stderr: os.Stderr}
+	r.OnStart = func(c runner.Command) {
+		if c.Name != "" {
+			color.Cyan("Executing %s: %s", c.Name, c)
+		} else {
+			color.Cyan("Executing: %s", c)
+		}
+	}
+	r.OnDone = func(result runner.Result) {
+		label := result.Command.Label()
+		switch result.Status() {
+		case runner.StatusIgnored:
+			color.Yellow("Validation command %s failed, continuing: %v", label, result.Err)
+		case runner.StatusFailed:
+			color.Red("Validation command %s failed: %v", label, result.Err)
+		case runner.StatusSkipped:
+			color.Yellow("Validation command %s %v", label, result.Err)
+		}
+	}
+
+	results, err := r.RunAll(cmd.Context(), commands, jobs)
+	if err != nil {
+		color.Red("Error in the automated validation commands: %v", err)
+		os.Exit(1)
+	}
+	printValidationSummary(results)
+	report := runner.NewReport(results)
+	if path, err := saveValidationReport(report); err != nil {
+		color.Yellow("Could not save the validation report: %v", err)
+	} else {
+		color.Cyan("Validation report saved to %s", path)
+	}
+
+	var failures []runner.Result
+	for _, result := range results {
+		if result.Status() == runner.StatusFailed {
+			failures = append(failures, result)
+		}
+	}
+	return failures, report.Passed
+}
+
+// printValidationSummary prints a table of the validation commands and how they went.
+func printValidationSummary(results []runner.Result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tSTATUS\tEXIT\tDURATION")
+	for _, result := range results {
+		exit, duration := "-", "-"
+		if !result.Skipped {
+			exit = strconv.Itoa(result.ExitCode)
+			duration = result.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Command.Label(), result.Status(), exit, duration)
+	}
+	w.Flush()
+}
+
+// saveValidationReport writes report as JSON to PDT_VALIDATION_REPORT, or
+// .pdt/validation.json, and returns the path.
+func saveValidationReport(report runner.Report) (string, error) {
+	path := filepath.Join(".pdt", "validation.json")
+	if value := setting("PDT_VALIDATION_REPORT"); value != "" {
+		path = value
+	}
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data.Bytes(), 0644)
+}
+
+// describeFailures names the failed commands and collects their output and the
+// failures parsed from it for the repair prompt.
+func describeFailures(failures []runner.Result) (string, string, []diag.Failure) {
+	var parsed []diag.Failure
+	for _, failure := range failures {
+		parsed = append(parsed, parseFailures(failure)...)
+	}
+	if len(failures) == 1 {
+		return failures[0].Command.Run, fmt.Sprintf("%s\n%v\n", failures[0].Output, failures[0].Err), parsed
+	}
+	var names []string
+	var output strings.Builder
+	for _, failure := range failures {
+		names = append(names, failure.Command.Run)
+		fmt.Fprintf(&output, "$ %s\n%s\n%v\n\n", failure.Command.Run, failure.Output, failure.Err)
+	}
+	return strings.Join(names, "`, `"), output.String(), parsed
+}
+
+// parseFailures parses the failures a validation command reported in its results
+// file, if it has one, or its output. File paths are made relative to the project.
+func parseFailures(result runner.Result) []diag.Failure {
+	c := result.Command
+	var failures []diag.Failure
+	if c.Results != "" {
+		if content, err := os.ReadFile(filepath.Join(c.Dir, c.Results)); err == nil {
+			failures = diag.Parse(string(content))
+		} else {
+			color.Yellow("Could not read the results of %s: %v", c.Label(), err)
+		}
+	}
+	if len(failures) == 0 {
+		failures = diag.Parse(result.Output)
+	}
+	for i, f := range failures {
+		if f.File != "" && c.Dir != "" && !filepath.IsAbs(f.File) {
+			failures[i].File = filepath.Join(c.Dir, f.File)
+		}
+	}
+	return failures
+}
+
+// maxListedFailures is how many parsed failures are printed after validation fails.
+const maxListedFailures = 20
+
+// printFailures lists the failures parsed from the validation output.
+func printFailures(failures []diag.Failure) {
+	if len(failures) == 0 {
+		return
+	}
+	color.Red("Failures:")
+	for i, f := range failures {
+		if i == maxListedFailures {
+			color.Red("  ... and %d more", len(failures)-maxListedFailures)
+			break
+		}
+		color.New(color.FgRed).Println("  " + f.String())
+	}
+}
+
+var (
+	durationPattern = regexp.MustCompile(`\b\d+(\.\d+)?(ns|µs|us|ms|s|m|h)\b`)
+	addressPattern  = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+)
+
+// errorSignature strips timings and memory addresses from a command's output so two
+// runs that fail the same way compare equal.
+func errorSignature(output string) string {
+	output = durationPattern.ReplaceAllString(output, "")
+	output = addressPattern.ReplaceAllString(output, "0x")
+	return strings.TrimSpace(output)
+}
+
+// changedFiles adds the files that changes wrote to files, without duplicates.
+func changedFiles(files []string, changes []change) []string {
+	for _, c := range changes {
+		path := c.Path
+		switch c.Op.Action {
+		case fs.ActionDelete:
+			continue
+		case fs.ActionRename:
+			path = c.To
+		}
+		seen := false
+		for _, file := range files {
+			seen = seen || file == path
+		}
+		if !seen {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// describeAttempt summarises one fix attempt: the failure and the files changed.
+func describeAttempt(failed string, output string, failures []diag.Failure, changes []change) string {
+	reason := ""
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			reason = line
+			break
+		}
+	}
+	if len(failures) > 0 {
+		reason = failures[0].String()
+		if len(failures) > 1 {
+			reason += fmt.Sprintf(" and %d more", len(failures)-1)
+		}
+	}
+	if len(reason) > 100 {
+		reason = reason[:100] + "..."
+	}
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+	changed := "changed no files"
+	if len(paths) > 0 {
+		changed = "changed " + strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("`%s` failed (%s); the fix %s", failed, reason, changed)
+}
+
+// printFixSummary lists the fix attempts made, if any.
+func printFixSummary(attempts []string) {
+	if len(attempts) == 0 {
+		return
+	}
+	color.Cyan("Fix attempts:")
+	for i, attempt := range attempts {
+		color.Cyan("  %d. %s", i+1, attempt)
+	}
+}
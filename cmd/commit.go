@@ -2,13 +2,12 @@ package cmd
 
 import (
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
-	"github.com/productdevtool/pdt-cli/pkg/ai"
+	"github.com/productdevtool/pdt-cli/pkg/proc"
 	"github.com/productdevtool/pdt-cli/pkg/prompt"
 	"github.com/productdevtool/pdt-cli/pkg/task"
 	"github.com/spf13/cobra"
@@ -19,20 +18,20 @@ var commitCmd = &cobra.Command{
 	Short: "Finalizes the work by reviewing, committing, and cleaning up the completed task.",
 	Long:  "This command finalizes the work by reviewing, committing, and cleaning up the completed task.",
 	Run: func(cmd *cobra.Command, args []string) {
-		activeTaskDir, err := task.GetActiveTask()
+		activeTaskDir, err := task.GetActiveTask(projectConfig.Paths.WorkDir())
 		if err != nil {
 			color.Red("Error getting active task: %v", err)
 			os.Exit(1)
 		}
 
 		color.Cyan("Displaying git diff for review...")
-		gitDiffCmd := exec.Command("git", "diff")
+		gitDiffCmd := proc.Command(cmd.Context(), "git", "diff")
 		gitDiffCmd.Stdout = os.Stdout
 		gitDiffCmd.Stderr = os.Stderr
 
 		if err := gitDiffCmd.Run(); err != nil {
 			color.Red("Error running git diff: %v", err)
-			os.Exit(1)
+			os.Exit(exitStatus(cmd))
 		}
 
 		confirm := false
@@ -53,14 +52,14 @@ var commitCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		aiCommitMsg, err := ai.Executor("gemini-cli", commitPrompt)
+		resp, err := complete(cmd, commitPrompt)
 		if err != nil {
-			color.Red("Error generating AI commit message: %v", err)
-			os.Exit(1)
+			reportAIError("Error generating AI commit message", err)
+			os.Exit(exitStatus(cmd))
 		}
 
 		// Clean up AI output for commit message
-		aiCommitMsg = strings.TrimSpace(aiCommitMsg)
+		aiCommitMsg := strings.TrimSpace(resp.Text)
 		if strings.HasPrefix(aiCommitMsg, "```") {
 			aiCommitMsg = strings.TrimPrefix(aiCommitMsg, "```")
 			aiCommitMsg = strings.TrimSuffix(aiCommitMsg, "```")
@@ -70,27 +69,27 @@ var commitCmd = &cobra.Command{
 		color.Green("Using AI-generated commit message:\n%s", aiCommitMsg)
 
 		// Commit the changes
-		gitAddCmd := exec.Command("git", "add", ".")
+		gitAddCmd := proc.Command(cmd.Context(), "git", "add", ".")
 		gitAddCmd.Stdout = os.Stdout
 		gitAddCmd.Stderr = os.Stderr
 		if err := gitAddCmd.Run(); err != nil {
 			color.Red("Error adding files to git: %v", err)
-			os.Exit(1)
+			os.Exit(exitStatus(cmd))
 		}
 
-		gitCommitCmd := exec.Command("git", "commit", "-m", aiCommitMsg)
+		gitCommitCmd := proc.Command(cmd.Context(), "git", "commit", "-m", aiCommitMsg)
 		gitCommitCmd.Stdout = os.Stdout
 		gitCommitCmd.Stderr = os.Stderr
 		if err := gitCommitCmd.Run(); err != nil {
 			color.Red("Error committing changes: %v", err)
-			os.Exit(1)
+			os.Exit(exitStatus(cmd))
 		}
 
 		color.Green("Changes committed successfully for task %s.", filepath.Base(activeTaskDir))
 
 		// Implement Task Cleanup (Task 4.4)
 		color.Cyan("Cleaning up task workspace...")
-		doneDir := "docs/todos/done"
+		doneDir := projectConfig.Paths.DoneDir()
 		oldTaskPath := filepath.Join(activeTaskDir, "task.md")
 		newTaskPath := filepath.Join(doneDir, filepath.Base(activeTaskDir) + ".md")
 
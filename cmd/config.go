@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/productdevtool/pdt-cli/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configGlobalFlag bool
+	configAllFlag    bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Shows and changes pdt's settings.",
+	Long:  "Settings come from, in increasing order of precedence: pdt's defaults, your own ~/.config/pdt/config.yaml, the project's pdt.yaml, PDT_* environment variables and command-line flags. Use these subcommands to see each effective value and which of them it came from, or to change a file.",
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Prints the effective value of a setting, such as ai.model.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		origin, err := projectConfig.Resolve(args[0], os.Getenv, givenFlags())
+		if err != nil {
+			color.Red("Error reading the setting: %v", err)
+			os.Exit(1)
+		}
+		if !origin.Set {
+			if origin.Value != "" {
+				color.Yellow("%s is not set; pdt uses %s.", args[0], origin.Value)
+			} else {
+				color.Yellow("%s is not set.", args[0])
+			}
+			os.Exit(1)
+		}
+		fmt.Println(origin.Value)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Sets a setting in the project's pdt.yaml, or with --global in your own configuration.",
+	Long:  "Sets a setting in the project's configuration file, creating pdt.yaml if the project has none, or with --global in ~/.config/pdt/config.yaml. Lists such as write.deny are given as comma-separated items.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, value := args[0], args[1]
+		path := projectConfig.Path
+		if path == "" {
+			path = config.FileNames[0]
+		}
+		if configGlobalFlag {
+			var err error
+			path, err = config.UserPath()
+			if err != nil {
+				color.Red("Error finding your configuration directory: %v", err)
+				os.Exit(1)
+			}
+		}
+
+		if err := config.Set(path, key, value); err != nil {
+			color.Red("Error setting %s:\n%v", key, err)
+			os.Exit(1)
+		}
+		color.Green("Set %s to %s in %s.", key, value, displayPath(path))
+
+		// Tell when the new value doesn't take effect because a higher layer sets it.
+		cfg, err := config.Load(".")
+		if err != nil {
+			return
+		}
+		origin, err := cfg.Resolve(key, os.Getenv, givenFlags())
+		if err == nil && origin.Where != path && origin.Source != config.SourceDefault {
+			color.Yellow("%s still comes from %s, which takes precedence.", key, describeOrigin(origin))
+		}
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the effective settings and where each comes from.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := givenFlags()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+		for _, key := range configKeys() {
+			origin, err := projectConfig.Resolve(key, os.Getenv, flags)
+			if err != nil {
+				color.Red("Error reading %s: %v", key, err)
+				os.Exit(1)
+			}
+			if !origin.Set && !configAllFlag {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", key, originValue(origin), describeOrigin(origin))
+		}
+		w.Flush()
+	},
+}
+
+var configExplainCmd = &cobra.Command{
+	Use:   "explain [key...]",
+	Short: "Shows the value every layer gives a setting, and which one wins.",
+	Long:  "Shows the value each layer gives the settings: flags, environment variables, the project's pdt.yaml, your own configuration and the defaults, from the highest precedence to the lowest. Without keys it explains every setting that isn't left at its default.",
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := givenFlags()
+		keys := args
+		if len(keys) == 0 {
+			for _, key := range configKeys() {
+				if origin, err := projectConfig.Resolve(key, os.Getenv, flags); err == nil && origin.Set && origin.Source != config.SourceDefault {
+					keys = append(keys, key)
+				}
+			}
+			if len(keys) == 0 {
+				color.Yellow("Every setting is at its default.")
+				return
+			}
+		}
+
+		for i, key := range keys {
+			origins, err := projectConfig.Explain(key, os.Getenv, flags)
+			if err != nil {
+				color.Red("Error reading the setting: %v", err)
+				os.Exit(1)
+			}
+			if i > 0 {
+				fmt.Println()
+			}
+			winner := -1
+			for j, o := range origins {
+				if o.Set {
+					winner = j
+					break
+				}
+			}
+			if winner < 0 {
+				color.Cyan("%s is not set", key)
+			} else {
+				color.Cyan("%s = %s", key, origins[winner].Value)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for j, o := range origins {
+				line := fmt.Sprintf("  %s\t%s\t%s", o.Source, displayPath(o.Where), originValue(o))
+				if j == winner {
+					line += "\t<- used"
+				} else if winner >= 0 && j > winner && o.Set {
+					line += "\t(overridden)"
+				}
+				fmt.Fprintln(w, line)
+			}
+			w.Flush()
+		}
+	},
+}
+
+func init() {
+	configSetCmd.Flags().BoolVar(&configGlobalFlag, "global", false, "Change your own configuration instead of the project's")
+	configListCmd.Flags().BoolVar(&configAllFlag, "all", false, "Also list the settings nothing sets")
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configExplainCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// configKeys returns the keys pdt lists: the known settings and the per-command
+// timeouts the configuration sets.
+func configKeys() []string {
+	var keys []string
+	for _, s := range config.Settings {
+		keys = append(keys, s.Key)
+		if s.Key == "timeout" {
+			var timeouts []string
+			for command := range projectConfig.Timeouts {
+				timeouts = append(timeouts, "timeouts."+command)
+			}
+			sort.Strings(timeouts)
+			keys = append(keys, timeouts...)
+		}
+	}
+	return keys
+}
+
+// originValue returns the value a layer gives a setting, or what applies when it
+// doesn't set it.
+func originValue(o config.Origin) string {
+	switch {
+	case o.Set:
+		return o.Value
+	case o.Value != "":
+		return "(" + o.Value + ")"
+	default:
+		return "(not set)"
+	}
+}
+
+// describeOrigin names the layer a value came from.
+func describeOrigin(o config.Origin) string {
+	if o.Where == "" {
+		return o.Source
+	}
+	return fmt.Sprintf("%s (%s)", o.Source, displayPath(o.Where))
+}
+
+// displayPath shortens a path in the home directory to start with ~.
+func displayPath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || !strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return path
+	}
+	return "~" + strings.TrimPrefix(path, home)
+}
@@ -2,13 +2,8 @@ package cmd
 
 import (
 	"os"
-	"path/filepath"
-	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
-	"github.com/productdevtool/pdt-cli/pkg/ai"
-	"github.com/productdevtool/pdt-cli/pkg/fs"
 	"github.com/productdevtool/pdt-cli/pkg/prompt"
 	"github.com/spf13/cobra"
 )
@@ -19,8 +14,11 @@ var docCmd = &cobra.Command{
 	Long:  "This command provides the AI with the feature's spec file and the file paths of the implemented code.",
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		specFile := args[0]
-		codePaths := args[1:]
+		specFile := specPath(args[0])
+		var codePaths []string
+		for _, path := range args[1:] {
+			codePaths = append(codePaths, inputPath(path))
+		}
 
 		// Check if spec file exists
 		if _, err := os.Stat(specFile); os.IsNotExist(err) {
@@ -36,53 +34,25 @@ var docCmd = &cobra.Command{
 		}
 
 		color.Cyan("Generating documentation with AI...")
-		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-		s.Start()
-
-		aiOutput, err := ai.Executor("gemini-cli", docPrompt)
+		result, err := generateFiles(cmd, docPrompt)
 		if err != nil {
-			s.Stop()
-			color.Red("Error executing AI prompt: %v", err)
-			os.Exit(1)
+			reportAIError("Error executing AI prompt", err)
+			os.Exit(exitStatus(cmd))
 		}
 
-		s.Stop()
-		color.Green("AI output:\n%s", aiOutput)
-
-		codeBlocks, err := fs.ExtractCodeBlocks(aiOutput)
-		if err != nil {
-			color.Red("Error extracting code blocks from AI output: %v", err)
-			os.Exit(1)
+		if cmd.Context().Err() != nil {
+			color.Yellow("Interrupted before any files were written; the workspace is unchanged.")
+			os.Exit(exitStatus(cmd))
 		}
 
-		for _, block := range codeBlocks {
-			if block.FilePath == "" {
-				color.Yellow("Skipping code block with no file path:\n%s", block.Content)
-				continue
-			}
-
-			// Assuming documentation files go into a 'docs/handbook' directory
-			fullPath := filepath.Join("docs/handbook", block.FilePath)
-			// Ensure directory exists
-			err = os.MkdirAll(filepath.Dir(fullPath), 0755)
-			if err != nil {
-				color.Red("Error creating directory for %s: %v", fullPath, err)
-				continue
-			}
-
-			// Write content to file
-			err = os.WriteFile(fullPath, []byte(block.Content), 0644)
-			if err != nil {
-				color.Red("Error writing to file %s: %v", fullPath, err)
-				continue
-			}
-			color.Green("Wrote documentation to %s", fullPath)
-		}
+		// Documentation files go into the handbook directory, docs/handbook by default
+		writeOperations(cmd, projectConfig.Paths.Handbook, result.Operations, "documentation")
 
 		color.Green("Documentation generation complete.")
 	},
 }
 
 func init() {
+	addGenerationFlags(docCmd)
 	rootCmd.AddCommand(docCmd)
 }
\ No newline at end of file
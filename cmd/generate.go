@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/productdevtool/pdt-cli/pkg/fs"
+	"github.com/productdevtool/pdt-cli/pkg/journal"
+	"github.com/productdevtool/pdt-cli/pkg/prompt"
+	"github.com/spf13/cobra"
+)
+
+// maxStructuredRepairs is how many times the AI is asked to fix invalid JSON output
+// before falling back to the markdown parser.
+const maxStructuredRepairs = 2
+
+// maxHunkRepairs is how many times the AI is asked to redo edits that don't apply.
+const maxHunkRepairs = 2
+
+// journalKeep is how many generations `pdt undo` can roll back.
+const journalKeep = 20
+
+var (
+	structuredFlag  bool
+	quietFlag       bool
+	dryRunFlag      bool
+	interactiveFlag bool
+)
+
+// addGenerationFlags registers the flags shared by commands that write AI-generated files.
+func addGenerationFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&structuredFlag, "structured", false, "Ask the AI for validated JSON file operations instead of markdown code blocks; defaults to $PDT_AI_STRUCTURED")
+	cmd.Flags().BoolVarP(&quietFlag, "quiet", "q", false, "Don't stream the AI's output; only list the files it generates. Always on when $CI is set")
+	cmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Show a diff of each file the AI would write without writing anything")
+	cmd.Flags().BoolVarP(&interactiveFlag, "interactive", "i", false, "Review each file the AI would write and accept, reject or edit it")
+	cmd.MarkFlagsMutuallyExclusive("dry-run", "interactive")
+	cmd.PreRunE = checkReviewFlags
+}
+
+// quietMode reports whether streamed AI output should be hidden, as it is in CI.
+func quietMode() bool {
+	return quietFlag || os.Getenv("CI") != ""
+}
+
+// generation is the AI's response and the file operations parsed from it.
+type generation struct {
+	Text       string
+	Operations []fs.FileOperation
+}
+
+// generateFiles sends p to the AI and parses the file operations it asks for. In
+// structured mode invalid JSON is sent back for repair, and the markdown parser is
+// only used if the AI still can't produce a valid document.
+func generateFiles(cmd *cobra.Command, p *prompt.Prompt) (*generation, error) {
+	if !structuredFlag && setting("PDT_AI_STRUCTURED") != "true" {
+		resp, err := send(cmd, p, true)
+		if err != nil {
+			return nil, err
+		}
+		return parseMarkdownOutput(resp.Text)
+	}
+
+	resp, err := send(cmd, prompt.WithStructuredOutput(p), true)
+	if err != nil {
+		return nil, err
+	}
+	text := resp.Text
+	ops, parseErr := fs.ParseStructuredOutput(text)
+
+	for attempt := 1; parseErr != nil && attempt <= maxStructuredRepairs; attempt++ {
+		color.Yellow("The AI returned invalid structured output, asking it to repair it (attempt %d of %d)...", attempt, maxStructuredRepairs)
+		resp, err = send(cmd, prompt.StructuredOutputRepairPrompt(text, parseErr), true)
+		if err != nil {
+			return nil, err
+		}
+		text = resp.Text
+		ops, parseErr = fs.ParseStructuredOutput(text)
+	}
+
+	if parseErr != nil {
+		color.Yellow("Falling back to markdown code blocks: %v", parseErr)
+		return parseMarkdownOutput(text)
+	}
+	return &generation{Text: text, Operations: ops}, nil
+}
+
+func parseMarkdownOutput(text string) (*generation, error) {
+	codeBlocks, warnings := fs.ParseCodeBlocks(text)
+	ops, _, opWarnings := fs.OperationsFromBlocks(codeBlocks)
+	for _, warning := range append(warnings, opWarnings...) {
+		color.Yellow("AI output %s", warning)
+	}
+	return &generation{Text: text, Operations: ops}, nil
+}
+
+// writeSandbox returns the sandbox for AI-generated writes: the current directory,
+// minus the default deny list and PDT_WRITE_DENY, limited to PDT_WRITE_ALLOW when set.
+func writeSandbox() (*fs.Sandbox, error) {
+	sandbox, err := fs.NewSandbox(".")
+	if err != nil {
+		return nil, err
+	}
+	sandbox.SetRules(splitList(setting("PDT_WRITE_ALLOW")), splitList(setting("PDT_WRITE_DENY")))
+	return sandbox, nil
+}
+
+// writeJournal returns the journal that records AI-generated writes under root so
+// that `pdt undo` can roll them back.
+func writeJournal(root string) *journal.Journal {
+	dir := filepath.Join(root, ".pdt", "journal")
+	if value := setting("PDT_JOURNAL_DIR"); value != "" {
+		dir = value
+	}
+	return &journal.Journal{Dir: dir, Root: root, Keep: journalKeep}
+}
+
+// specPath returns the spec file a command was given, looking for it in the specs
+// directory when it doesn't exist as given.
+func specPath(name string) string {
+	path := inputPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) && !filepath.IsAbs(name) {
+		candidate := filepath.Join(projectConfig.Paths.Specs, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return path
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// change is a file operation that has been prepared but not made yet.
+type change struct {
+	Op fs.FileOperation
+	// Path is the file's path relative to the current directory, and FullPath the
+	// absolute path the sandbox resolved it to.
+	Path     string
+	FullPath string
+	// To and FullTo are the destination of a rename.
+	To       string
+	FullTo   string
+	Exists   bool
+	Original string
+	// Content is what the file will hold; empty for a delete.
+	Content string
+	// OldMode and Mode are the file's permissions before and after a chmod.
+	OldMode os.FileMode
+	Mode    os.FileMode
+}
+
+// writeOperations writes the generated files under base, refusing any the sandbox
+// blocks. Patches are applied to the current content of their file; edits that don't
+// apply are sent back to the AI, and any still rejected after that are reported and
+// left out. With --dry-run the changes are only shown, and with --interactive each
+// one is reviewed first. The changes are made together, or not at all, and recorded
+// in the journal for `pdt undo`. It returns the changes made.
+func writeOperations(cmd *cobra.Command, base string, ops []fs.FileOperation, noun string) []change {
+	sandbox, err := writeSandbox()
+	if err != nil {
+		color.Red("Error setting up the write sandbox: %v", err)
+		os.Exit(1)
+	}
+
+	var blocked []error
+	resolve := func(name string) (string, []byte, bool) {
+		fullPath, content, err := sandbox.ReadFile(base, name)
+		if err != nil {
+			var blockedErr *fs.BlockedError
+			if errors.As(err, &blockedErr) {
+				blocked = append(blocked, err)
+				color.Red("Blocked: %v", err)
+			} else {
+				color.Red("Error reading %s: %v", filepath.Join(base, name), err)
+			}
+			return "", nil, false
+		}
+		return fullPath, content, true
+	}
+
+	var changes []change
+	for _, op := range ops {
+		fullPath, original, ok := resolve(op.Path)
+		if !ok {
+			continue
+		}
+		info, statErr := os.Stat(fullPath)
+		c := change{Op: op, Path: filepath.Join(base, op.Path), FullPath: fullPath, Exists: statErr == nil, Original: string(original), Content: op.Content}
+		if !c.Exists && op.NeedsExistingFile() {
+			color.Yellow("Skipping %s of %s, which doesn't exist.", op.Action, c.Path)
+			continue
+		}
+
+		switch op.Action {
+		case fs.ActionPatch:
+			patched, ok := applyPatch(cmd, fullPath, c.Original, op)
+			if !ok {
+				continue
+			}
+			c.Content = patched
+		case fs.ActionDelete:
+			c.Content = ""
+		case fs.ActionRename:
+			fullTo, _, ok := resolve(op.To)
+			if !ok {
+				continue
+			}
+			if _, err := os.Stat(fullTo); err == nil {
+				color.Yellow("Skipping the rename of %s to %s, which already exists.", c.Path, filepath.Join(base, op.To))
+				continue
+			}
+			c.To, c.FullTo, c.Content = filepath.Join(base, op.To), fullTo, c.Original
+		case fs.ActionChmod:
+			mode, err := fs.ParseMode(op.Mode)
+			if err != nil {
+				color.Red("Error changing the mode of %s: %v", c.Path, err)
+				continue
+			}
+			c.OldMode, c.Mode, c.Content = info.Mode().Perm(), mode, c.Original
+		}
+		changes = append(changes, c)
+	}
+
+	switch {
+	case dryRunFlag:
+		for _, c := range changes {
+			printChange(c)
+		}
+		color.Yellow("Dry run: %d changes would be made; nothing was written.", len(changes))
+		changes = nil
+	case interactiveFlag:
+		changes = reviewChanges(changes)
+	}
+
+	tx := writeJournal(sandbox.Root()).Begin(cmd.Name())
+	for _, c := range changes {
+		var err error
+		switch c.Op.Action {
+		case fs.ActionDelete:
+			err = tx.Delete(c.FullPath)
+		case fs.ActionRename:
+			err = tx.Rename(c.FullPath, c.FullTo)
+		case fs.ActionChmod:
+			err = tx.Chmod(c.FullPath, c.Mode)
+		default:
+			err = tx.Stage(c.FullPath, []byte(c.Content))
+		}
+		if err != nil {
+			tx.Discard()
+			color.Red("Error preparing %s: %v", c.Path, err)
+			color.Red("No files were written; the workspace is unchanged.")
+			os.Exit(1)
+		}
+	}
+	gen, err := tx.Commit()
+	if err != nil {
+		color.Red("%v", err)
+		color.Red("No files were written; the workspace is unchanged.")
+		os.Exit(1)
+	}
+	for _, c := range changes {
+		switch c.Op.Action {
+		case fs.ActionPatch:
+			color.Green("Patched %s in %s", noun, c.Path)
+		case fs.ActionDelete:
+			color.Green("Deleted %s", c.Path)
+		case fs.ActionRename:
+			color.Green("Renamed %s to %s", c.Path, c.To)
+		case fs.ActionChmod:
+			color.Green("Changed the mode of %s to %04o", c.Path, c.Mode)
+		default:
+			color.Green("Wrote %s to %s", noun, c.Path)
+		}
+	}
+	if gen != nil {
+		color.Cyan("Run `pdt undo` to roll back these changes (generation %s).", gen.ID)
+	}
+
+	if len(blocked) > 0 {
+		color.Yellow("%d of %d files were not written because they are outside the project or protected. "+
+			"Adjust PDT_WRITE_ALLOW or PDT_WRITE_DENY if that was intended.", len(blocked), len(ops))
+	}
+	return changes
+}
+
+// applyPatch applies a patch operation to the original content of the file at
+// fullPath, asking the AI to redo rejected hunks. It reports false when nothing
+// should be written.
+func applyPatch(cmd *cobra.Command, fullPath string, original string, op fs.FileOperation) (string, bool) {
+	content, rejected, err := fs.ApplyPatch(original, op.Content)
+	if err != nil {
+		color.Red("Error applying edit to %s: %v", fullPath, err)
+		return "", false
+	}
+
+	for attempt := 1; len(rejected) > 0 && attempt <= maxHunkRepairs && cmd.Context().Err() == nil; attempt++ {
+		color.Yellow("%d edits to %s did not apply, asking the AI to redo them (attempt %d of %d)...", len(rejected), op.Path, attempt, maxHunkRepairs)
+		var failed []string
+		for _, r := range rejected {
+			failed = append(failed, r.String())
+		}
+		resp, err := send(cmd, prompt.HunkRepairPrompt(op.Path, content, failed), true)
+		if err != nil {
+			reportAIError("Error asking the AI to redo the edits", err)
+			break
+		}
+
+		blocks, _ := fs.ParseCodeBlocks(resp.Text)
+		var retried []fs.RejectedHunk
+		for _, block := range blocks {
+			var repaired []fs.RejectedHunk
+			content, repaired, err = fs.ApplyPatch(content, block.Content)
+			if err != nil {
+				color.Yellow("Ignoring a redone edit that can't be parsed: %v", err)
+				continue
+			}
+			retried = append(retried, repaired...)
+		}
+		if len(blocks) == 0 {
+			color.Yellow("The AI's reply contained no edits.")
+			retried = rejected
+		}
+		rejected = retried
+	}
+
+	if len(rejected) > 0 {
+		color.Red("%d edits to %s were rejected and not applied:", len(rejected), fullPath)
+		for _, r := range rejected {
+			color.Red("%s", r)
+		}
+		if original == content {
+			return "", false
+		}
+	}
+	return content, true
+}
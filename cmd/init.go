@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/productdevtool/pdt-cli/pkg/config"
+	"github.com/productdevtool/pdt-cli/pkg/proc"
+	"github.com/productdevtool/pdt-cli/pkg/prompt"
+	"github.com/productdevtool/pdt-cli/pkg/scaffold"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initYesFlag bool
+	initAIFlag  bool
+)
+
+// maxListedFiles limits the project files sent to the AI to draft the description.
+const maxListedFiles = 500
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Sets a project up for pdt: its configuration, docs and templates.",
+	Long:  "Detects the project's stack (go.mod, package.json, pyproject.toml, Cargo.toml), proposes its build, deploy and validation commands, and creates pdt.yaml, the docs tree, the templates directory and .gitignore entries for pdt's local state. Existing files are never overwritten. The AI is only asked to draft the project description if you want it to.",
+	Args:  cobra.NoArgs,
+	// A new project inside a repository, like a service in a monorepo, is set up where
+	// init runs rather than at the repository root.
+	Annotations: map[string]string{noRootSearch: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := os.Getwd()
+		if err != nil {
+			color.Red("Error finding the project directory: %v", err)
+			os.Exit(1)
+		}
+		color.Cyan("Setting up pdt in %s", root)
+		interactive := !initYesFlag && isatty.IsTerminal(os.Stdin.Fd())
+
+		stacks, err := scaffold.Detect(".")
+		if err != nil {
+			color.Red("Error detecting the project's stack: %v", err)
+			os.Exit(1)
+		}
+		if len(stacks) == 0 {
+			color.Yellow("No go.mod, package.json, pyproject.toml or Cargo.toml found; fill in the commands in %s yourself.", config.FileNames[0])
+		}
+		for _, s := range stacks {
+			color.Green("Detected %s (%s)", s.Name, s.Marker)
+		}
+
+		options := scaffold.Propose(filepath.Base(root), stacks)
+		if projectConfig.Path != "" {
+			color.Yellow("%s already exists; only missing files will be added.", projectConfig.Path)
+		} else if interactive {
+			if err := askCommands(&options); err != nil {
+				color.Red("Error reading your answers: %v", err)
+				os.Exit(1)
+			}
+		}
+
+		var files []scaffold.File
+		for _, f := range scaffold.Files(options, projectConfig.Paths) {
+			// A project configured in .pdt/config.yaml doesn't need a pdt.yaml too.
+			if projectConfig.Path != "" && f.Path == config.FileNames[0] {
+				continue
+			}
+			files = append(files, f)
+		}
+		created, existing, err := scaffold.Write(".", files)
+		for _, path := range created {
+			color.Green("Created %s", path)
+		}
+		for _, path := range existing {
+			color.Yellow("Kept the existing %s", path)
+		}
+		if err != nil {
+			color.Red("Error creating the project files: %v", err)
+			os.Exit(1)
+		}
+
+		added, err := scaffold.AddToGitignore(".gitignore", scaffold.GitignoreEntries(projectConfig, os.Getenv))
+		if err != nil {
+			color.Red("Error updating .gitignore: %v", err)
+			os.Exit(1)
+		}
+		if len(added) > 0 {
+			color.Green("Added %s to .gitignore", strings.Join(added, ", "))
+		}
+
+		description := projectConfig.Paths.ProjectDescription
+		draft := initAIFlag
+		if !contains(created, description) {
+			if initAIFlag {
+				color.Yellow("%s already exists, so the AI won't draft it.", description)
+			}
+			draft = false
+		} else if interactive && !initAIFlag {
+			err := survey.AskOne(&survey.Confirm{
+				Message: color.CyanString("Ask the AI to draft %s from the code?", description),
+			}, &draft)
+			if err != nil {
+				color.Red("Error reading your answer: %v", err)
+				os.Exit(1)
+			}
+		}
+		if draft {
+			if err := draftProjectDescription(cmd, description); err != nil {
+				reportAIError("Error drafting the project description", err)
+				color.Yellow("The skeleton in %s is still there to fill in.", description)
+				os.Exit(exitStatus(cmd))
+			}
+			color.Green("Drafted %s; review it before you start.", description)
+		}
+
+		color.Green("pdt is set up. Describe the project in %s, add tasks to %s and run pdt todo.", description, projectConfig.Paths.Todo)
+	},
+}
+
+func init() {
+	initCmd.Flags().BoolVarP(&initYesFlag, "yes", "y", false, "Accept the proposed commands without asking")
+	initCmd.Flags().BoolVar(&initAIFlag, "ai", false, "Ask the AI to draft the project description without asking first")
+	rootCmd.AddCommand(initCmd)
+}
+
+// askCommands lets the user edit the proposed build and deploy commands and choose
+// the validation steps.
+func askCommands(o *scaffold.Options) error {
+	questions := []struct {
+		message string
+		value   *string
+	}{
+		{"Build command (leave empty for none):", &o.Build},
+		{"Deploy command (leave empty for none):", &o.Deploy},
+	}
+	for _, q := range questions {
+		if err := survey.AskOne(&survey.Input{Message: color.CyanString(q.message), Default: *q.value}, q.value); err != nil {
+			return err
+		}
+		*q.value = strings.TrimSpace(*q.value)
+	}
+
+	if len(o.Validation) > 0 {
+		var options []string
+		for _, step := range o.Validation {
+			options = append(options, step.Run)
+		}
+		var chosen []string
+		err := survey.AskOne(&survey.MultiSelect{
+			Message: color.CyanString("Validation commands to run after pdt code:"),
+			Options: options,
+			Default: options,
+		}, &chosen)
+		if err != nil {
+			return err
+		}
+		var steps []config.Command
+		for _, step := range o.Validation {
+			if contains(chosen, step.Run) {
+				steps = append(steps, step)
+			}
+		}
+		o.Validation = steps
+	}
+	for {
+		run := ""
+		if err := survey.AskOne(&survey.Input{Message: color.CyanString("Another validation command (leave empty to finish):")}, &run); err != nil {
+			return err
+		}
+		if run = strings.TrimSpace(run); run == "" {
+			return nil
+		}
+		o.Validation = append(o.Validation, config.Command{Run: run})
+	}
+}
+
+// draftProjectDescription asks the AI to fill in the skeleton project description at
+// path, and writes its answer there.
+func draftProjectDescription(cmd *cobra.Command, path string) error {
+	skeleton, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	color.Cyan("Drafting the project description with AI...")
+	resp, err := complete(cmd, prompt.InitialProjectDescriptionPrompt(string(skeleton), projectFiles(cmd)))
+	if err != nil {
+		return err
+	}
+	text := strings.TrimSpace(resp.Text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(strings.TrimPrefix(text, "```markdown"), "```")
+		text = strings.TrimSpace(strings.TrimSuffix(text, "```"))
+	}
+	return os.WriteFile(path, []byte(text+"\n"), 0644)
+}
+
+// projectFiles lists the project's files: those git tracks, or, outside a git
+// repository, those under the current directory apart from dependencies and pdt's
+// own state.
+func projectFiles(cmd *cobra.Command) []string {
+	var files []string
+	if out, err := proc.Command(cmd.Context(), "git", "ls-files").Output(); err == nil {
+		files = strings.Split(strings.TrimSpace(string(out)), "\n")
+	} else {
+		skip := map[string]bool{".git": true, ".pdt": true, "node_modules": true, "vendor": true, "target": true, "__pycache__": true, ".venv": true}
+		filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+			if err != nil || len(files) >= maxListedFiles {
+				return filepath.SkipDir
+			}
+			if info.IsDir() && skip[info.Name()] {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	if len(files) > maxListedFiles {
+		files = append(files[:maxListedFiles], "...")
+	}
+	return files
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/productdevtool/pdt-cli/pkg/fs"
+	"github.com/spf13/cobra"
+)
+
+const (
+	reviewAccept     = "Accept"
+	reviewReject     = "Reject"
+	reviewEdit       = "Edit"
+	reviewAcceptRest = "Accept this and all remaining files"
+	reviewRejectRest = "Reject this and all remaining files"
+)
+
+// checkReviewFlags refuses --interactive when there is no terminal to ask on, before
+// any time is spent calling the AI.
+func checkReviewFlags(cmd *cobra.Command, args []string) error {
+	if interactiveFlag && !isatty.IsTerminal(os.Stdin.Fd()) {
+		return errors.New("--interactive needs a terminal; use --dry-run to preview the changes instead")
+	}
+	return nil
+}
+
+// changeDiff returns the unified diff a change would make.
+func changeDiff(c change) string {
+	oldName, newName := "a/"+filepath.ToSlash(c.Path), "b/"+filepath.ToSlash(c.Path)
+	switch {
+	case !c.Exists:
+		oldName = "/dev/null"
+	case c.Op.Action == fs.ActionDelete:
+		newName = "/dev/null"
+	case c.Op.Action == fs.ActionRename || c.Op.Action == fs.ActionChmod:
+		return ""
+	}
+	return fs.UnifiedDiff(oldName, newName, c.Original, c.Content)
+}
+
+// printChange prints a summary line and a colored diff for a change.
+func printChange(c change) {
+	diff := changeDiff(c)
+	added, removed := fs.DiffStat(diff)
+	switch {
+	case c.Op.Action == fs.ActionRename:
+		color.New(color.Bold).Printf("%s → %s (renamed)\n\n", c.Path, c.To)
+		return
+	case c.Op.Action == fs.ActionChmod:
+		color.New(color.Bold).Printf("%s (mode %04o → %04o)\n\n", c.Path, c.OldMode, c.Mode)
+		return
+	case c.Op.Action == fs.ActionDelete:
+		color.New(color.Bold).Printf("%s (deleted, %d lines)\n", c.Path, removed)
+	case !c.Exists:
+		color.New(color.Bold).Printf("%s (new file, %d lines)\n", c.Path, added)
+	case diff == "":
+		color.New(color.Bold).Printf("%s (unchanged)\n", c.Path)
+		return
+	default:
+		color.New(color.Bold).Printf("%s (+%d -%d)\n", c.Path, added, removed)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- "):
+			color.New(color.Bold).Println(line)
+		case strings.HasPrefix(line, "@@"):
+			color.New(color.FgCyan).Println(line)
+		case strings.HasPrefix(line, "+"):
+			color.New(color.FgGreen).Println(line)
+		case strings.HasPrefix(line, "-"):
+			color.New(color.FgRed).Println(line)
+		default:
+			fmt.Fprintln(color.Output, line)
+		}
+	}
+	fmt.Fprintln(color.Output)
+}
+
+// reviewChanges shows each change and asks whether to accept, reject or edit it. It
+// returns the accepted changes, with any edits made during the review.
+func reviewChanges(changes []change) []change {
+	var accepted []change
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		printChange(c)
+
+		// Only new content can be edited; deletes, renames and mode changes are taken
+		// or left as they are.
+		options := []string{reviewAccept, reviewReject, reviewEdit, reviewAcceptRest, reviewRejectRest}
+		message := fmt.Sprintf("Write %s?", c.Path)
+		switch c.Op.Action {
+		case fs.ActionDelete:
+			message = fmt.Sprintf("Delete %s?", c.Path)
+		case fs.ActionRename:
+			message = fmt.Sprintf("Rename %s to %s?", c.Path, c.To)
+		case fs.ActionChmod:
+			message = fmt.Sprintf("Change the mode of %s to %04o?", c.Path, c.Mode)
+		}
+		if c.Op.Action == fs.ActionDelete || c.Op.Action == fs.ActionRename || c.Op.Action == fs.ActionChmod {
+			options = []string{reviewAccept, reviewReject, reviewAcceptRest, reviewRejectRest}
+		}
+
+		choice := ""
+		err := survey.AskOne(&survey.Select{
+			Message: color.CyanString("%s (%d of %d)", message, i+1, len(changes)),
+			Options: options,
+		}, &choice)
+		if errors.Is(err, terminal.InterruptErr) {
+			color.Yellow("Review interrupted; rejecting the remaining files.")
+			break
+		}
+		if err != nil {
+			color.Red("Error reading your choice for %s: %v", c.Path, err)
+			continue
+		}
+
+		switch choice {
+		case reviewAccept:
+			accepted = append(accepted, c)
+		case reviewEdit:
+			edited := ""
+			err := survey.AskOne(&survey.Editor{
+				Message:       color.CyanString("Edit %s", c.Path),
+				Default:       c.Content,
+				Editor:        setting("PDT_EDITOR"),
+				AppendDefault: true,
+				HideDefault:   true,
+				FileName:      "*" + filepath.Ext(c.Path),
+			}, &edited)
+			if err != nil {
+				color.Red("Error editing %s: %v", c.Path, err)
+			} else {
+				changes[i].Content = edited
+			}
+			// Show the edited change and ask again.
+			i--
+		case reviewAcceptRest:
+			accepted = append(accepted, changes[i:]...)
+			i = len(changes)
+		case reviewRejectRest:
+			i = len(changes)
+		}
+	}
+
+	color.Cyan("Accepted %d of %d files.", len(accepted), len(changes))
+	return accepted
+}
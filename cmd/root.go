@@ -1,10 +1,43 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/fatih/color"
+	"github.com/productdevtool/pdt-cli/pkg/config"
+	"github.com/productdevtool/pdt-cli/pkg/proc"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// noRootSearch is the annotation of commands that work in the current directory, or
+// the --root flag, instead of the project root above it.
+const noRootSearch = "pdt:no-root-search"
+
+var (
+	providerFlag string
+	modelFlag    string
+	timeoutFlag  time.Duration
+	noCacheFlag  bool
+	rootFlag     string
+
+	// startDir is the directory pdt was started in, before it changed to the project
+	// root.
+	startDir string
+
+	cancelTimeout context.CancelFunc = func() {}
+
+	// projectConfig is the project configuration, and configEnv its settings keyed by
+	// the environment variables that override them.
+	projectConfig = config.Default()
+	configEnv     = map[string]string{}
 )
 
 var rootCmd = &cobra.Command{
@@ -12,12 +45,152 @@ var rootCmd = &cobra.Command{
 	Short: "pdt is the command center for the AI-native founder",
 	Long:  `A longer description that spans multiple lines and likely contains examples and usage of using your application. For example: ...`,
 	Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := enterRoot(cmd); err != nil {
+			color.Red("Error finding the project root: %v", err)
+			os.Exit(1)
+		}
+		cfg, err := config.Load(".")
+		if err != nil {
+			color.Red("Error reading the project configuration:\n%v", err)
+			os.Exit(1)
+		}
+		projectConfig, configEnv = cfg, cfg.Env()
+
+		switch value := setting("PDT_COLOR"); value {
+		case "", "auto":
+		case "always":
+			color.NoColor = false
+		case "never":
+			color.NoColor = true
+		default:
+			return fmt.Errorf("invalid PDT_COLOR '%s': expected auto, always or never", value)
+		}
+
+		timeout, err := commandTimeout(cmd)
+		if err != nil {
+			return err
+		}
+		if timeout > 0 {
+			var ctx context.Context
+			ctx, cancelTimeout = context.WithTimeout(cmd.Context(), timeout)
+			cmd.SetContext(ctx)
+		}
+		return nil
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		cancelTimeout()
+	},
 }
 
 func Execute() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := proc.NotifyContext(context.Background())
+	defer stop()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&providerFlag, "provider", "", "AI provider to use (gemini-cli, command, openai); defaults to $PDT_AI_PROVIDER")
+	rootCmd.PersistentFlags().StringVar(&modelFlag, "model", "", "AI model to request; defaults to $PDT_AI_MODEL")
+	rootCmd.PersistentFlags().BoolVar(&noCacheFlag, "no-cache", false, "Always call the AI instead of reusing cached responses")
+	rootCmd.PersistentFlags().StringVar(&rootFlag, "root", "", "Project directory to work in; defaults to the nearest directory above the current one with a pdt.yaml, .pdt/config.yaml or .git")
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", 0, "Abort the command after this long, e.g. 10m; defaults to $PDT_TIMEOUTS_<COMMAND> or $PDT_TIMEOUT")
+}
+
+// enterRoot changes to the project root, the --root flag or the directory FindRoot
+// finds, so pdt's paths work from anywhere in the project. Commands annotated with
+// noRootSearch, like init, stay in the current directory instead. Paths given on the
+// command line are resolved with inputPath.
+func enterRoot(cmd *cobra.Command) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	startDir = dir
+	root, err := config.Root(dir, rootFlag, cmd.Annotations[noRootSearch] == "")
+	if err != nil {
+		return err
+	}
+	return os.Chdir(root)
+}
+
+// inputPath resolves a path given on the command line, relative to the directory pdt
+// was started in, to a path relative to the project root.
+func inputPath(path string) string {
+	if filepath.IsAbs(path) || startDir == "" {
+		return path
+	}
+	path = filepath.Join(startDir, path)
+	root, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
+
+// commandTimeout returns the timeout for cmd: the --timeout flag, then a per-command
+// PDT_TIMEOUTS_<NAME> variable, then PDT_TIMEOUT. Zero means no timeout.
+func commandTimeout(cmd *cobra.Command) (time.Duration, error) {
+	if timeoutFlag > 0 {
+		return timeoutFlag, nil
+	}
+	for _, name := range []string{"PDT_TIMEOUTS_" + strings.ToUpper(cmd.Name()), "PDT_TIMEOUT"} {
+		value := setting(name)
+		if value == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s '%s': %w", name, value, err)
+		}
+		return timeout, nil
+	}
+	return 0, nil
+}
+
+// setting returns the environment variable name, or when it isn't set, the value the
+// configuration file gives the same setting.
+func setting(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return configEnv[name]
+}
+
+// givenFlags returns the values of the pdt-wide flags given on the command line, by
+// name, as the settings they override take them.
+func givenFlags() map[string]string {
+	flags := map[string]string{}
+	rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		flags[f.Name] = f.Value.String()
+		if f.Name == "no-cache" {
+			// --no-cache turns cache.enabled off.
+			flags[f.Name] = strconv.FormatBool(!noCacheFlag)
+		}
+	})
+	return flags
+}
+
+// exitStatus returns the process exit status for a command that failed: 130 when pdt
+// was interrupted, 124 when the command timed out, and 1 otherwise.
+func exitStatus(cmd *cobra.Command) int {
+	ctx := cmd.Context()
+	if proc.InterruptedBy(ctx) != nil {
+		return 130
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return 124
+	}
+	return 1
+}
@@ -7,7 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/fatih/color"
-	"github.com/productdevtool/pdt-cli/pkg/ai"
+	"github.com/productdevtool/pdt-cli/pkg/proc"
 	"github.com/productdevtool/pdt-cli/pkg/prompt"
 	"github.com/productdevtool/pdt-cli/pkg/task"
 	"github.com/spf13/cobra"
@@ -18,13 +18,13 @@ var specCmd = &cobra.Command{
 	Short: "Refines a task into a detailed plan using AI.",
 	Long:  "This command transforms a high-level todo item into a detailed, actionable technical plan. It focuses on defining the *what* and the *how*",
 	Run: func(cmd *cobra.Command, args []string) {
-		activeTaskDir, err := task.GetActiveTask()
+		activeTaskDir, err := task.GetActiveTask(projectConfig.Paths.WorkDir())
 		if err != nil {
 			color.Red("Error getting active task: %v", err)
 			os.Exit(1)
 		}
 
-		projectDescriptionPath := "docs/project-description.md"
+		projectDescriptionPath := projectConfig.Paths.ProjectDescription
 		taskPath := filepath.Join(activeTaskDir, "task.md")
 
 		// Build the refinement prompt
@@ -35,14 +35,14 @@ var specCmd = &cobra.Command{
 		}
 
 		color.Cyan("Generating detailed specification with AI...")
-		aiOutput, err := ai.Executor("gemini-cli", refinementPrompt)
+		resp, err := complete(cmd, refinementPrompt)
 		if err != nil {
-			color.Red("Error executing AI prompt: %v", err)
-			os.Exit(1)
+			reportAIError("Error executing AI prompt", err)
+			os.Exit(exitStatus(cmd))
 		}
 
 		// Update the task.md with the new, detailed plan
-		err = ioutil.WriteFile(taskPath, []byte(aiOutput), 0644)
+		err = ioutil.WriteFile(taskPath, []byte(resp.Text), 0644)
 		if err != nil {
 			color.Red("Error writing AI output to task.md: %v", err)
 			os.Exit(1)
@@ -52,17 +52,21 @@ var specCmd = &cobra.Command{
 
 		// Git integration: add and commit the refined task.md
 		color.Cyan("Committing refined specification...")
-		_, err = ai.Executor("git", "add", taskPath)
-		if err != nil {
+		gitAddCmd := proc.Command(cmd.Context(), "git", "add", taskPath)
+		gitAddCmd.Stdout = os.Stdout
+		gitAddCmd.Stderr = os.Stderr
+		if err := gitAddCmd.Run(); err != nil {
 			color.Red("Error adding task.md to git: %v", err)
-			os.Exit(1)
+			os.Exit(exitStatus(cmd))
 		}
 
 		commitMsg := fmt.Sprintf("feat: Refine spec for %s", filepath.Base(activeTaskDir))
-		_, err = ai.Executor("git", "commit", "-m", commitMsg)
-		if err != nil {
+		gitCommitCmd := proc.Command(cmd.Context(), "git", "commit", "-m", commitMsg)
+		gitCommitCmd.Stdout = os.Stdout
+		gitCommitCmd.Stderr = os.Stderr
+		if err := gitCommitCmd.Run(); err != nil {
 			color.Red("Error committing task.md: %v", err)
-			os.Exit(1)
+			os.Exit(exitStatus(cmd))
 		}
 
 		color.Green("Refined specification committed successfully.")
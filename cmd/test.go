@@ -2,13 +2,8 @@ package cmd
 
 import (
 	"os"
-	"path/filepath"
-	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
-	"github.com/productdevtool/pdt-cli/pkg/ai"
-	"github.com/productdevtool/pdt-cli/pkg/fs"
 	"github.com/productdevtool/pdt-cli/pkg/prompt"
 	"github.com/spf13/cobra"
 )
@@ -19,7 +14,7 @@ var testCmd = &cobra.Command{
 	Long:  "This is useful for generating tests for legacy code that doesn't have a task.md or for adding more tests to an existing feature.",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		specFile := args[0]
+		specFile := specPath(args[0])
 
 		// Check if spec file exists
 		if _, err := os.Stat(specFile); os.IsNotExist(err) {
@@ -35,52 +30,25 @@ var testCmd = &cobra.Command{
 		}
 
 		color.Cyan("Generating tests with AI...")
-		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-		s.Start()
-
-		aiOutput, err := ai.Executor("gemini-cli", testPrompt)
+		result, err := generateFiles(cmd, testPrompt)
 		if err != nil {
-			s.Stop()
-			color.Red("Error executing AI prompt: %v", err)
-			os.Exit(1)
+			reportAIError("Error executing AI prompt", err)
+			os.Exit(exitStatus(cmd))
 		}
 
-		s.Stop()
-		color.Green("AI output:\n%s", aiOutput)
-		
-		codeBlocks, err := fs.ExtractCodeBlocks(aiOutput)
-		if err != nil {
-			color.Red("Error extracting code blocks from AI output: %v", err)
-			os.Exit(1)
+		if cmd.Context().Err() != nil {
+			color.Yellow("Interrupted before any files were written; the workspace is unchanged.")
+			os.Exit(exitStatus(cmd))
 		}
 
-		for _, block := range codeBlocks {
-			if block.FilePath == "" {
-				color.Yellow("Skipping code block with no file path:\n%s", block.Content)
-				continue
-			}
-
-			fullPath := block.FilePath // For tests, assume path is relative to current dir
-			// Ensure directory exists
-			err = os.MkdirAll(filepath.Dir(fullPath), 0755)
-			if err != nil {
-				color.Red("Error creating directory for %s: %v", fullPath, err)
-				continue
-			}
-
-			// Write content to file
-			err = os.WriteFile(fullPath, []byte(block.Content), 0644)
-			if err != nil {
-				color.Red("Error writing to file %s: %v", fullPath, err)
-				continue
-			}
-			color.Green("Wrote test code to %s", fullPath)
-		}
+		// For tests, assume paths are relative to the current directory
+		writeOperations(cmd, ".", result.Operations, "test code")
 
 		color.Green("Test generation complete.")
 	},
 }
 
 func init() {
+	addGenerationFlags(testCmd)
 	rootCmd.AddCommand(testCmd)
 }
\ No newline at end of file
@@ -3,14 +3,13 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
-	"github.com/productdevtool/pdt-cli/pkg/ai"
 	"github.com/productdevtool/pdt-cli/pkg/fs"
-	"github.com/productdevtool/pdt-cli/pkg/prompt"
 	"github.com/spf13/cobra"
 )
 
@@ -24,14 +23,15 @@ var todoCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		tasks, err := fs.ReadTodoFile("docs/todo.md")
+		paths := projectConfig.Paths
+		tasks, err := fs.ReadTodoFile(paths.Todo)
 		if err != nil {
 			color.Red("Error reading todo file: %v", err)
 			os.Exit(1)
 		}
 
 		if len(tasks) == 0 {
-			color.Yellow("No tasks found in docs/todo.md. Add some tasks and try again.")
+			color.Yellow("No tasks found in %s. Add some tasks and try again.", paths.Todo)
 			return
 		}
 
@@ -56,53 +56,30 @@ func init() {
 }
 
 func initWorkspace() error {
-	if err := fs.CreateDirs([]string{"docs", "docs/todos/work", "docs/todos/done"}); err != nil {
-		return err
-	}
+	paths := projectConfig.Paths
 
-	// Check for project-description.md and generate if not found
-	projectDescExists, err := fs.Exists("docs/project-description.md")
+	// Check for project-description.md, which pdt init creates
+	projectDescExists, err := fs.Exists(paths.ProjectDescription)
 	if err != nil {
 		return err
 	}
 
 	if !projectDescExists {
-		color.Cyan("Generating initial project description...")
-		initialPrompt := prompt.InitialProjectDescriptionPrompt()
-		aiOutput, err := ai.Executor("gemini-cli", initialPrompt)
-		if err != nil {
-			return fmt.Errorf("failed to generate project description: %w", err)
-		}
-
-		// Write the AI output to the file
-		err = os.WriteFile("docs/project-description.md", []byte(aiOutput), 0644)
-		if err != nil {
-			return fmt.Errorf("failed to write project description to file: %w", err)
-		}
-
-		color.Green("Initial project description generated in docs/project-description.md.")
-
-		confirmEdit := false
-		promptConfirmEdit := &survey.Confirm{
-			Message: color.CyanString("Do you want to review and edit the generated project-description.md?"),
-		}
-		survey.AskOne(promptConfirmEdit, &confirmEdit)
+		return fmt.Errorf("%s not found; run `pdt init` to set up the project", paths.ProjectDescription)
+	}
 
-		if confirmEdit {
-			// TODO: Implement logic to open editor for project-description.md
-			color.Yellow("Please manually edit docs/project-description.md and then run pdt todo again.")
-			os.Exit(0)
-		}
+	if err := fs.CreateDirs([]string{filepath.Dir(paths.Todo), paths.WorkDir(), paths.DoneDir()}); err != nil {
+		return err
 	}
 
 	// Ensure docs/todo.md exists
-	todoExists, err := fs.Exists("docs/todo.md")
+	todoExists, err := fs.Exists(paths.Todo)
 	if err != nil {
 		return err
 	}
 
 	if !todoExists {
-		_, err := os.Create("docs/todo.md")
+		_, err := os.Create(paths.Todo)
 		if err != nil {
 			return err
 		}
@@ -114,7 +91,7 @@ func initWorkspace() error {
 func initializeTaskWorkspace(task string, allTasks []string) error {
 	timestamp := time.Now().Format("2006-01-02-15-04-05")
 	taskName := strings.ToLower(strings.ReplaceAll(task, " ", "-"))
-	taskDir := fmt.Sprintf("docs/todos/work/%s-%s", timestamp, taskName)
+	taskDir := filepath.Join(projectConfig.Paths.WorkDir(), fmt.Sprintf("%s-%s", timestamp, taskName))
 
 	if err := os.MkdirAll(taskDir, 0755); err != nil {
 		return err
@@ -138,7 +115,7 @@ func initializeTaskWorkspace(task string, allTasks []string) error {
 		}
 	}
 
-	if err := fs.RewriteTodoFile("docs/todo.md", remainingTasks); err != nil {
+	if err := fs.RewriteTodoFile(projectConfig.Paths.Todo, remainingTasks); err != nil {
 		return err
 	}
 
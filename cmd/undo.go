@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/productdevtool/pdt-cli/pkg/journal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	undoList  bool
+	undoForce bool
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [generation]",
+	Short: "Rolls back the files written by the last AI generation, or a named one.",
+	Long:  "Every time pdt writes AI-generated files, the previous content is saved in .pdt/journal. This command restores it and removes files the generation created. Use --list to see the generations that can be undone.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sandbox, err := writeSandbox()
+		if err != nil {
+			color.Red("Error finding the project root: %v", err)
+			os.Exit(1)
+		}
+		j := writeJournal(sandbox.Root())
+
+		if undoList {
+			gens, err := j.Generations()
+			if err != nil {
+				color.Red("Error reading the journal: %v", err)
+				os.Exit(1)
+			}
+			if len(gens) == 0 {
+				color.Yellow("No generations recorded in %s.", j.Dir)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "GENERATION\tTIME\tCOMMAND\tFILES\tSTATUS")
+			for _, gen := range gens {
+				status := ""
+				if gen.Undone != nil {
+					status = "undone"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", gen.ID, gen.Time.Local().Format(time.RFC822), gen.Command, len(gen.Files), status)
+			}
+			w.Flush()
+			return
+		}
+
+		var gen *journal.Generation
+		if len(args) == 1 {
+			gen, err = j.Load(args[0])
+		} else {
+			gen, err = j.Latest()
+		}
+		if err != nil {
+			color.Red("Error reading the journal: %v", err)
+			os.Exit(1)
+		}
+		if gen == nil {
+			color.Yellow("Nothing to undo.")
+			return
+		}
+
+		color.Cyan("Undoing generation %s (pdt %s, %s)...", gen.ID, gen.Command, gen.Time.Local().Format(time.RFC822))
+		if err := j.Undo(gen, undoForce); err != nil {
+			var modified *journal.ModifiedError
+			if errors.As(err, &modified) {
+				color.Red("Not undoing: %v.", err)
+				color.Yellow("Pass --force to discard those changes and restore the files anyway.")
+			} else {
+				color.Red("Error undoing generation %s: %v", gen.ID, err)
+			}
+			os.Exit(1)
+		}
+		for _, file := range gen.Files {
+			if file.Existed {
+				color.Green("Restored %s", file.Path)
+			} else {
+				color.Green("Removed %s", file.Path)
+			}
+		}
+		color.Green("Generation %s undone.", gen.ID)
+	},
+}
+
+func init() {
+	undoCmd.Flags().BoolVar(&undoList, "list", false, "List the recorded generations instead of undoing one")
+	undoCmd.Flags().BoolVar(&undoForce, "force", false, "Undo even if the files were changed after the generation wrote them")
+	rootCmd.AddCommand(undoCmd)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/productdevtool/pdt-cli/pkg/usage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	usageBy   string
+	usageDays int
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Summarises AI calls, tokens and estimated cost from the local usage ledger.",
+	Long:  "Every AI call is recorded in .pdt/usage.jsonl with its command, task, provider, model, token counts, latency and estimated cost. This command summarises that ledger by day, command, task or model.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ledger := usageLedger()
+		since := time.Now().AddDate(0, 0, -usageDays)
+		records, err := ledger.Load(since)
+		if err != nil {
+			color.Red("Error reading usage ledger: %v", err)
+			os.Exit(1)
+		}
+
+		if len(records) == 0 {
+			color.Yellow("No AI usage recorded in the last %d days.", usageDays)
+		} else {
+			summaries, err := usage.Summarize(records, usageBy)
+			if err != nil {
+				color.Red("Error summarising usage: %v", err)
+				os.Exit(1)
+			}
+
+			color.Cyan("AI usage for the last %d days by %s:", usageDays, usageBy)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tCALLS\tPROMPT TOKENS\tRESPONSE TOKENS\tAVG LATENCY\tCOST")
+			var total usage.Summary
+			for _, s := range summaries {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%v\t$%.4f\n", s.Key, s.Calls, s.PromptTokens, s.ResponseTokens, averageLatency(s), s.Cost)
+				total.Calls += s.Calls
+				total.PromptTokens += s.PromptTokens
+				total.ResponseTokens += s.ResponseTokens
+				total.Latency += s.Latency
+				total.Cost += s.Cost
+			}
+			fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%v\t$%.4f\n", total.Calls, total.PromptTokens, total.ResponseTokens, averageLatency(total), total.Cost)
+			w.Flush()
+		}
+
+		budget, err := monthlyBudget()
+		if err != nil {
+			color.Red("%v", err)
+			os.Exit(1)
+		}
+		if budget > 0 {
+			spent, err := ledger.MonthToDate(time.Now())
+			if err != nil {
+				color.Red("Error reading usage ledger: %v", err)
+				os.Exit(1)
+			}
+			month, err := ledger.Load(time.Date(time.Now().Year(), time.Now().Month(), 1, 0, 0, 0, 0, time.Local))
+			if err != nil {
+				color.Red("Error reading usage ledger: %v", err)
+				os.Exit(1)
+			}
+			if unpriced := usage.Unpriced(month); len(unpriced) > 0 {
+				color.Yellow("No price for %s, so those calls don't count toward the budget; add them to the PDT_USAGE_PRICES file.", strings.Join(unpriced, ", "))
+			}
+			if spent >= budget {
+				color.Red("Monthly budget reached: $%.2f of $%.2f spent. AI commands will refuse to run until next month.", spent, budget)
+			} else {
+				color.Green("Monthly budget: $%.2f of $%.2f spent.", spent, budget)
+			}
+		}
+	},
+}
+
+func averageLatency(s usage.Summary) time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return (s.Latency / time.Duration(s.Calls)).Round(100 * time.Millisecond)
+}
+
+func init() {
+	usageCmd.Flags().StringVar(&usageBy, "by", usage.ByDay, "Group usage by day, command, task or model")
+	usageCmd.Flags().IntVar(&usageDays, "days", 30, "Number of days of history to include")
+	rootCmd.AddCommand(usageCmd)
+}
@@ -2,13 +2,8 @@ package cmd
 
 import (
 	"os"
-	"path/filepath"
-	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
-	"github.com/productdevtool/pdt-cli/pkg/ai"
-	"github.com/productdevtool/pdt-cli/pkg/fs"
 	"github.com/productdevtool/pdt-cli/pkg/prompt"
 	"github.com/spf13/cobra"
 )
@@ -26,53 +21,25 @@ var writeCmd = &cobra.Command{
 		contentPrompt := prompt.ContentGenerationPrompt(contentType, topic)
 
 		color.Cyan("Generating %s content about '%s' with AI...", contentType, topic)
-		s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-		s.Start()
-
-		aiOutput, err := ai.Executor("gemini-cli", contentPrompt)
+		result, err := generateFiles(cmd, contentPrompt)
 		if err != nil {
-			s.Stop()
-			color.Red("Error executing AI prompt: %v", err)
-			os.Exit(1)
+			reportAIError("Error executing AI prompt", err)
+			os.Exit(exitStatus(cmd))
 		}
 
-		s.Stop()
-		color.Green("AI output:\n%s", aiOutput)
-
-		codeBlocks, err := fs.ExtractCodeBlocks(aiOutput)
-		if err != nil {
-			color.Red("Error extracting code blocks from AI output: %v", err)
-			os.Exit(1)
+		if cmd.Context().Err() != nil {
+			color.Yellow("Interrupted before any files were written; the workspace is unchanged.")
+			os.Exit(exitStatus(cmd))
 		}
 
-		for _, block := range codeBlocks {
-			if block.FilePath == "" {
-				color.Yellow("Skipping code block with no file path:\n%s", block.Content)
-				continue
-			}
-
-			// Assuming content files go into a 'content' directory
-			fullPath := filepath.Join("content", block.FilePath)
-			// Ensure directory exists
-			err = os.MkdirAll(filepath.Dir(fullPath), 0755)
-			if err != nil {
-				color.Red("Error creating directory for %s: %v", fullPath, err)
-				continue
-			}
-
-			// Write content to file
-			err = os.WriteFile(fullPath, []byte(block.Content), 0644)
-			if err != nil {
-				color.Red("Error writing to file %s: %v", fullPath, err)
-				continue
-			}
-			color.Green("Wrote content to %s", fullPath)
-		}
+		// Content files go into the content directory, content by default
+		writeOperations(cmd, projectConfig.Paths.Content, result.Operations, "content")
 
 		color.Green("Content generation complete.")
 	},
 }
 
 func init() {
+	addGenerationFlags(writeCmd)
 	rootCmd.AddCommand(writeCmd)
 }
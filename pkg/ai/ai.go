@@ -1,53 +1,137 @@
 package ai
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"time"
 )
 
-// Executor executes a command and returns its stdout as a string.
-// It also streams stdout and stderr to the console.
-func Executor(command string, args ...string) (string, error) {
-	var cmd *exec.Cmd
+// Provider names accepted by NewProvider.
+const (
+	ProviderGemini  = "gemini-cli"
+	ProviderCommand = "command"
+	ProviderOpenAI  = "openai"
+)
 
-	// Get the directory of the currently running executable
-	ex, err := os.Executable()
-	if err != nil {
-		return "", fmt.Errorf("failed to get executable path: %w", err)
-	}
-	exDir := filepath.Dir(ex)
-
-	// Construct the path to the command in the same directory
-	localCmdPath := filepath.Join(exDir, command)
-
-	// Check if the command exists at the local path
-	if _, err := os.Stat(localCmdPath); err == nil {
-		// If it exists, use this path
-		cmd = exec.Command(localCmdPath, args...)
-	} else {
-		// Otherwise, look for the command in the system's PATH
-		cmd = exec.Command(command, args...)
-	}
+// Request is a single prompt sent to a Provider.
+type Request struct {
+	Prompt string `json:"prompt"`
+	// Model overrides the provider's configured model when set.
+	Model string `json:"model,omitempty"`
+	// OnChunk, when set, receives the response text as it is generated. Providers
+	// that can't stream, and responses served from a cache or cassette, deliver
+	// the whole text as one chunk.
+	OnChunk func(Chunk) `json:"-"`
+}
 
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
-	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+// Chunk is a piece of a streamed response.
+type Chunk struct {
+	Text string
+	// Restart is set when a failed call is about to be retried; text received so
+	// far should be discarded.
+	Restart bool
+}
 
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("error starting command '%s': %w", command, err)
+// emit passes c to the request's chunk callback, if it has one.
+func emit(req Request, c Chunk) {
+	if req.OnChunk != nil {
+		req.OnChunk(c)
 	}
+}
+
+// Response is the text returned by a Provider together with metadata about the call.
+type Response struct {
+	Text     string
+	Provider string
+	Model    string
+	Duration time.Duration
+	// PromptTokens and ResponseTokens are only set by backends that report usage.
+	PromptTokens   int
+	ResponseTokens int
+	// Cached is set when the response came from the response cache.
+	Cached bool
+}
+
+// Provider is implemented by every AI backend pdt can send prompts to.
+type Provider interface {
+	// Name identifies the provider, e.g. "gemini-cli".
+	Name() string
+	// Model returns the model the provider uses when a Request doesn't name one.
+	Model() string
+	// Complete sends the prompt and returns the generated text.
+	Complete(ctx context.Context, req Request) (*Response, error)
+}
 
-	if err := cmd.Wait(); err != nil {
-		// For gemini-cli, consider parsing stderr for more specific error messages
-		return "", fmt.Errorf("command '%s' finished with error: %w\nStderr: %s", command, err, stderrBuf.String())
+// PromptTooLargeError is returned when a prompt exceeds what a provider can accept.
+type PromptTooLargeError struct {
+	Provider string
+	Size     int
+	Limit    int
+	Reason   string
+}
+
+func (e *PromptTooLargeError) Error() string {
+	return fmt.Sprintf("prompt is %d bytes but %s accepts at most %d bytes %s; shorten the inputs or raise the provider's limit", e.Size, e.Provider, e.Limit, e.Reason)
+}
+
+// Config selects and configures a Provider.
+type Config struct {
+	Provider string
+	Model    string
+	// Command is the command line used by the "command" provider.
+	Command string
+	// BaseURL and APIKey are used by the "openai" provider.
+	BaseURL string
+	APIKey  string
+	// Input is how CLI providers receive the prompt: stdin, arg or file.
+	Input string
+	// PromptFileFlag is the flag CLI providers use to receive a prompt file.
+	PromptFileFlag string
+	// MaxPromptBytes rejects larger prompts before they are sent; 0 means unlimited.
+	MaxPromptBytes int
+}
+
+// NewProvider returns the Provider described by cfg. An empty provider name selects gemini-cli.
+func NewProvider(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case "", ProviderGemini:
+		return configureCLI(NewGeminiCLI(cfg.Model), cfg)
+	case ProviderCommand:
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("the '%s' provider requires a command to run", ProviderCommand)
+		}
+		p, err := NewCommandProvider(cfg.Command, cfg.Model)
+		if err != nil {
+			return nil, err
+		}
+		return configureCLI(p, cfg)
+	case ProviderOpenAI:
+		p := NewOpenAI(cfg.BaseURL, cfg.APIKey, cfg.Model)
+		p.SetMaxPromptBytes(cfg.MaxPromptBytes)
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider '%s' (expected %s, %s or %s)", cfg.Provider, ProviderGemini, ProviderCommand, ProviderOpenAI)
 	}
+}
 
-	// Further refinement for gemini-cli output might involve parsing JSON or other structured data
-	// based on its specific output formats. For now, we return the raw string.
-	return stdoutBuf.String(), nil
+func configureCLI(p *CLIProvider, cfg Config) (Provider, error) {
+	if cfg.Input != "" || cfg.PromptFileFlag != "" {
+		mode := InputMode(cfg.Input)
+		if mode == "" {
+			mode = p.input
+		}
+		if err := p.SetInput(mode, cfg.PromptFileFlag); err != nil {
+			return nil, err
+		}
+	}
+	p.SetMaxPromptBytes(cfg.MaxPromptBytes)
+	return p, nil
 }
 
+// modelFor returns the model a request will actually use on p.
+func modelFor(p Provider, req Request) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return p.Model()
+}
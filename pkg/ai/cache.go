@@ -0,0 +1,243 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache is an on-disk, content-addressed store of AI responses.
+type Cache struct {
+	Dir string
+	// TTL is how long entries stay valid; 0 keeps them forever.
+	TTL time.Duration
+	// MaxBytes caps the cache size, evicting least recently used entries; 0 means unlimited.
+	MaxBytes int64
+}
+
+// CacheStats summarises the contents of a Cache.
+type CacheStats struct {
+	Entries int
+	Expired int
+	Bytes   int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+type cacheEntry struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Response  string    `json:"response"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CacheKey identifies a request by hashing the provider, the model it resolves to
+// and every request parameter, including the prompt.
+func CacheKey(p Provider, req Request) string {
+	params, _ := json.Marshal(req)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", p.Name(), modelFor(p, req), params)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".json")
+}
+
+// Get returns the cached response for key, if present and not expired.
+func (c *Cache) Get(key string) (*Response, bool) {
+	path := c.path(key)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		os.Remove(path)
+		return nil, false
+	}
+	if c.expired(entry.CreatedAt) {
+		os.Remove(path)
+		return nil, false
+	}
+
+	// Touch the entry so size-based eviction drops the least recently used first.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return &Response{Text: entry.Response, Provider: entry.Provider, Model: entry.Model, Cached: true}, true
+}
+
+// Put stores a response under key and trims the cache to its size limit.
+func (c *Cache) Put(key string, resp *Response) error {
+	data, err := json.Marshal(cacheEntry{
+		Provider:  resp.Provider,
+		Model:     resp.Model,
+		Response:  resp.Text,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating cache directory: %w", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	return c.evict()
+}
+
+// Clear removes every entry and returns how many were removed.
+func (c *Cache) Clear() (int, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.RemoveAll(c.Dir); err != nil {
+		return 0, fmt.Errorf("error removing cache directory: %w", err)
+	}
+	return len(files), nil
+}
+
+// Stats reports the number, size and age of cached entries.
+func (c *Cache) Stats() (CacheStats, error) {
+	var stats CacheStats
+	files, err := c.files()
+	if err != nil {
+		return stats, err
+	}
+
+	for _, file := range files {
+		stats.Entries++
+		stats.Bytes += file.size
+		// Get touches entries for eviction, so their age comes from CreatedAt, as in Get.
+		created := createdAt(file)
+		if c.expired(created) {
+			stats.Expired++
+		}
+		if stats.Oldest.IsZero() || created.Before(stats.Oldest) {
+			stats.Oldest = created
+		}
+		if created.After(stats.Newest) {
+			stats.Newest = created
+		}
+	}
+	return stats, nil
+}
+
+// createdAt returns when the entry in file was cached, or its modification time when
+// it can't be read.
+func createdAt(file cacheFile) time.Time {
+	data, err := ioutil.ReadFile(file.path)
+	if err != nil {
+		return file.modTime
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.CreatedAt.IsZero() {
+		return file.modTime
+	}
+	return entry.CreatedAt
+}
+
+func (c *Cache) expired(t time.Time) bool {
+	return c.TTL > 0 && time.Since(t) > c.TTL
+}
+
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (c *Cache) files() ([]cacheFile, error) {
+	var files []cacheFile
+	err := filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".json") {
+			files = append(files, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache directory: %w", err)
+	}
+	return files, nil
+}
+
+// evict removes the least recently used entries until the cache fits in MaxBytes.
+func (c *Cache) evict() error {
+	if c.MaxBytes <= 0 {
+		return nil
+	}
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, file := range files {
+		total += file.size
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, file := range files {
+		if total <= c.MaxBytes {
+			break
+		}
+		if err := os.Remove(file.path); err != nil {
+			return fmt.Errorf("error evicting cache entry: %w", err)
+		}
+		total -= file.size
+	}
+	return nil
+}
+
+// Caching wraps a Provider so identical requests are answered from a Cache.
+type Caching struct {
+	provider Provider
+	cache    *Cache
+}
+
+// NewCaching returns p wrapped with cache.
+func NewCaching(p Provider, cache *Cache) *Caching {
+	return &Caching{provider: p, cache: cache}
+}
+
+// Name returns the wrapped provider's name.
+func (c *Caching) Name() string { return c.provider.Name() }
+
+// Model returns the wrapped provider's model.
+func (c *Caching) Model() string { return c.provider.Model() }
+
+// Complete returns a cached response when there is one, and caches fresh responses.
+// Failing to write the cache never fails the request.
+func (c *Caching) Complete(ctx context.Context, req Request) (*Response, error) {
+	key := CacheKey(c.provider, req)
+	if resp, ok := c.cache.Get(key); ok {
+		emit(req, Chunk{Text: resp.Text})
+		return resp, nil
+	}
+
+	resp, err := c.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Put(key, resp)
+	return resp, nil
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCachingAnswersRepeatedPrompts(t *testing.T) {
+	live := &fakeProvider{name: "fake", model: "m1", text: "generated"}
+	caching := NewCaching(live, &Cache{Dir: t.TempDir(), TTL: time.Hour})
+
+	// Test case 1: The first call goes to the provider
+	resp, err := caching.Complete(context.Background(), Request{Prompt: "p"})
+	if err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+	if resp.Cached || live.calls != 1 {
+		t.Errorf("Expected a live response, got cached=%v after %d calls", resp.Cached, live.calls)
+	}
+
+	// Test case 2: The same prompt is answered from the cache
+	resp, err = caching.Complete(context.Background(), Request{Prompt: "p"})
+	if err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+	if !resp.Cached || resp.Text != "generated" || live.calls != 1 {
+		t.Errorf("Expected a cached 'generated', got cached=%v %q after %d calls", resp.Cached, resp.Text, live.calls)
+	}
+
+	// Test case 3: Changing a parameter misses the cache
+	if _, err := caching.Complete(context.Background(), Request{Prompt: "p", Model: "m2"}); err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+	if live.calls != 2 {
+		t.Errorf("Expected a second live call, got %d calls", live.calls)
+	}
+}
+
+func TestCacheExpiryAndEviction(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir(), TTL: time.Hour}
+	if err := cache.Put("aa11", &Response{Text: "old"}); err != nil {
+		t.Fatalf("Put returned an error: %v", err)
+	}
+
+	// Test case 1: Entries older than the TTL are dropped
+	past := time.Now().Add(-2 * time.Hour)
+	cache.TTL = time.Nanosecond
+	os.Chtimes(cache.path("aa11"), past, past)
+	if _, ok := cache.Get("aa11"); ok {
+		t.Errorf("Expected the expired entry to miss")
+	}
+
+	// Test case 2: The least recently used entry is evicted when over the size limit
+	cache.TTL = 0
+	cache.Put("bb22", &Response{Text: "first"})
+	os.Chtimes(cache.path("bb22"), past, past)
+	stats, _ := cache.Stats()
+	// Room for one more entry, whose timestamp may be a few bytes longer, but not two.
+	cache.MaxBytes = stats.Bytes + stats.Bytes/2
+	if err := cache.Put("cc33", &Response{Text: "second"}); err != nil {
+		t.Fatalf("Put returned an error: %v", err)
+	}
+	if _, ok := cache.Get("bb22"); ok {
+		t.Errorf("Expected the least recently used entry to be evicted")
+	}
+	if _, ok := cache.Get("cc33"); !ok {
+		t.Errorf("Expected the newest entry to survive eviction")
+	}
+
+	// Test case 3: Stats counts entries as expired by when they were cached, not last used
+	cache.TTL = time.Hour
+	created := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, _ := json.Marshal(cacheEntry{Response: "second", CreatedAt: created})
+	os.WriteFile(cache.path("cc33"), data, 0644)
+	now := time.Now()
+	os.Chtimes(cache.path("cc33"), now, now)
+	if stats, _ := cache.Stats(); stats.Expired != 1 || !stats.Oldest.Equal(created) {
+		t.Errorf("Expected the entry cached in 2000 to count as expired, got %+v", stats)
+	}
+
+	// Test case 4: Clear removes everything
+	removed, err := cache.Clear()
+	if err != nil || removed != 1 {
+		t.Errorf("Expected 1 entry removed, got %d (%v)", removed, err)
+	}
+}
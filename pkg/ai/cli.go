@@ -0,0 +1,338 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/productdevtool/pdt-cli/pkg/proc"
+)
+
+// InputMode controls how a CLIProvider hands the prompt to its command.
+type InputMode string
+
+const (
+	// InputStdin writes the prompt to the command's standard input.
+	InputStdin InputMode = "stdin"
+	// InputArg passes the prompt as the last command-line argument.
+	InputArg InputMode = "arg"
+	// InputFile writes the prompt to a temp file and passes its path after a flag.
+	InputFile InputMode = "file"
+)
+
+// maxArgBytes is a conservative limit for a single command-line argument.
+// Linux caps one argument at 128KiB; Windows caps the whole command line at 32K characters.
+func maxArgBytes() int {
+	if runtime.GOOS == "windows" {
+		return 30 * 1024
+	}
+	return 120 * 1024
+}
+
+// CLIProvider runs a local command-line tool to complete prompts.
+// The tool's stdout is streamed to the request's chunk callback as it is written.
+type CLIProvider struct {
+	name    string
+	command string
+	args    []string
+	model   string
+	// modelFlag is passed before the model name when a model is set, e.g. "--model".
+	modelFlag string
+	// defaultModel is the model the tool uses when none is set, which responses report
+	// so their usage can be priced.
+	defaultModel string
+	// input is the preferred way of passing the prompt.
+	input InputMode
+	// acceptsStdin reports whether the command can read the prompt from stdin,
+	// which is used as a fallback when the prompt is too long for an argument.
+	acceptsStdin bool
+	// promptFileFlag is the flag that takes a prompt file path, e.g. "--prompt-file".
+	promptFileFlag string
+	// maxPromptBytes is the largest prompt the backend accepts; 0 means unlimited.
+	maxPromptBytes int
+}
+
+// defaultGeminiModel is the model gemini-cli uses without --model.
+const defaultGeminiModel = "gemini-2.5-pro"
+
+// NewGeminiCLI returns a provider that shells out to gemini-cli.
+func NewGeminiCLI(model string) *CLIProvider {
+	return &CLIProvider{
+		name:         ProviderGemini,
+		command:      "gemini-cli",
+		model:        model,
+		modelFlag:    "--model",
+		defaultModel: defaultGeminiModel,
+		input:        InputStdin,
+		acceptsStdin: true,
+	}
+}
+
+// NewCommandProvider returns a provider for any command that reads a prompt on stdin
+// and writes the completion to stdout. A "{model}" placeholder in the command line
+// is replaced with the configured model.
+func NewCommandProvider(commandLine string, model string) (*CLIProvider, error) {
+	parts, err := splitCommandLine(commandLine)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty AI command")
+	}
+	return &CLIProvider{
+		name:         ProviderCommand,
+		command:      parts[0],
+		args:         parts[1:],
+		model:        model,
+		input:        InputStdin,
+		acceptsStdin: true,
+	}, nil
+}
+
+// SetInput changes how the prompt is passed to the command. For InputFile, fileFlag
+// names the flag that takes the prompt file path.
+func (p *CLIProvider) SetInput(mode InputMode, fileFlag string) error {
+	switch mode {
+	case InputStdin, InputArg:
+	case InputFile:
+		if fileFlag == "" {
+			return fmt.Errorf("input mode '%s' requires a prompt file flag", InputFile)
+		}
+	default:
+		return fmt.Errorf("unknown input mode '%s' (expected %s, %s or %s)", mode, InputStdin, InputArg, InputFile)
+	}
+	p.input = mode
+	if fileFlag != "" {
+		p.promptFileFlag = fileFlag
+	}
+	return nil
+}
+
+// SetMaxPromptBytes limits the size of prompts sent to the command; 0 removes the limit.
+func (p *CLIProvider) SetMaxPromptBytes(n int) { p.maxPromptBytes = n }
+
+// Name returns the provider name.
+func (p *CLIProvider) Name() string { return p.name }
+
+// Model returns the configured model, which may be empty to use the tool's default.
+func (p *CLIProvider) Model() string { return p.model }
+
+// Complete runs the command with the prompt and returns its stdout.
+// Prompts too long to pass as an argument fall back to stdin or a prompt file.
+func (p *CLIProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	mode, err := p.inputFor(req.Prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.run(ctx, req, mode)
+	if mode == InputArg && errors.Is(err, syscall.E2BIG) {
+		// The OS rejected the argument list even though it was under our estimate.
+		if fallback, ok := p.fallbackInput(); ok {
+			return p.run(ctx, req, fallback)
+		}
+		return nil, &PromptTooLargeError{Provider: p.name, Size: len(req.Prompt), Limit: maxArgBytes(), Reason: "as a command-line argument"}
+	}
+	return resp, err
+}
+
+// inputFor picks the input mode for a prompt, falling back when it won't fit in an argument.
+func (p *CLIProvider) inputFor(prompt string) (InputMode, error) {
+	if p.maxPromptBytes > 0 && len(prompt) > p.maxPromptBytes {
+		return "", &PromptTooLargeError{Provider: p.name, Size: len(prompt), Limit: p.maxPromptBytes, Reason: "(configured limit)"}
+	}
+
+	mode := p.input
+	if mode == "" {
+		mode = InputArg
+	}
+	if mode == InputArg && len(prompt) > maxArgBytes() {
+		fallback, ok := p.fallbackInput()
+		if !ok {
+			return "", &PromptTooLargeError{Provider: p.name, Size: len(prompt), Limit: maxArgBytes(), Reason: "as a command-line argument"}
+		}
+		mode = fallback
+	}
+	return mode, nil
+}
+
+func (p *CLIProvider) fallbackInput() (InputMode, bool) {
+	if p.acceptsStdin {
+		return InputStdin, true
+	}
+	if p.promptFileFlag != "" {
+		return InputFile, true
+	}
+	return "", false
+}
+
+func (p *CLIProvider) run(ctx context.Context, req Request, mode InputMode) (*Response, error) {
+	model := modelFor(p, req)
+
+	var args []string
+	for _, arg := range p.args {
+		args = append(args, strings.ReplaceAll(arg, "{model}", model))
+	}
+	if p.modelFlag != "" && model != "" {
+		args = append(args, p.modelFlag, model)
+	}
+
+	var stdin io.Reader
+	switch mode {
+	case InputArg:
+		args = append(args, req.Prompt)
+	case InputStdin:
+		stdin = strings.NewReader(req.Prompt)
+	case InputFile:
+		promptFile, err := writePromptFile(req.Prompt)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(promptFile)
+		if strings.HasSuffix(p.promptFileFlag, "=") {
+			args = append(args, p.promptFileFlag+promptFile)
+		} else {
+			args = append(args, p.promptFileFlag, promptFile)
+		}
+	}
+
+	cmd := proc.Command(ctx, resolveCommand(p.command), args...)
+	cmd.Stdin = stdin
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdout = &chunkWriter{buf: &stdoutBuf, req: req}
+	cmd.Stderr = &stderrBuf
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting command '%s': %w", p.command, err)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("command '%s' was stopped: %w", p.command, context.Cause(ctx))
+		}
+		exitCode := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+		return nil, &Error{
+			Kind:     Classify(exitCode, 0, stderrBuf.String()),
+			Provider: p.name,
+			ExitCode: exitCode,
+			Err:      fmt.Errorf("command '%s' finished with error: %w\nStderr: %s", p.command, err, stderrBuf.String()),
+		}
+	}
+	if strings.TrimSpace(stdoutBuf.String()) == "" {
+		return nil, &Error{Kind: KindMalformed, Provider: p.name, Err: fmt.Errorf("command '%s' produced no output", p.command)}
+	}
+
+	if model == "" {
+		model = p.defaultModel
+	}
+	return &Response{
+		Text:     stdoutBuf.String(),
+		Provider: p.name,
+		Model:    model,
+		Duration: time.Since(start),
+	}, nil
+}
+
+func writePromptFile(prompt string) (string, error) {
+	file, err := ioutil.TempFile("", "pdt-prompt-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("error creating prompt file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(prompt); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("error writing prompt file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// resolveCommand prefers a binary shipped next to the pdt executable over one on the PATH.
+func resolveCommand(command string) string {
+	ex, err := os.Executable()
+	if err != nil {
+		return command
+	}
+	localCmdPath := filepath.Join(filepath.Dir(ex), command)
+	if _, err := os.Stat(localCmdPath); err == nil {
+		return localCmdPath
+	}
+	return command
+}
+
+// chunkWriter collects a command's output and forwards each write as a chunk.
+type chunkWriter struct {
+	buf *bytes.Buffer
+	req Request
+}
+
+func (w *chunkWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	emit(w.req, Chunk{Text: string(data)})
+	return len(data), nil
+}
+
+// splitCommandLine splits a command line into arguments, honouring single quotes,
+// double quotes and backslash escapes.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\' && i+1 < len(runes):
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command: %s", s)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	args, err := splitCommandLine(`ollama run "llama 3" --opt='a b' plain\ space`)
+	if err != nil {
+		t.Fatalf("splitCommandLine returned an error: %v", err)
+	}
+	expected := []string{"ollama", "run", "llama 3", "--opt=a b", "plain space"}
+	if !reflect.DeepEqual(expected, args) {
+		t.Errorf("Expected %v, got %v", expected, args)
+	}
+
+	if _, err := splitCommandLine(`echo "unterminated`); err == nil {
+		t.Errorf("Expected an error for an unterminated quote")
+	}
+}
+
+func TestCLIProviderInputFallback(t *testing.T) {
+	p := NewGeminiCLI("")
+	if err := p.SetInput(InputArg, ""); err != nil {
+		t.Fatalf("SetInput returned an error: %v", err)
+	}
+
+	// Test case 1: Short prompts stay on the command line
+	mode, err := p.inputFor("hello")
+	if err != nil || mode != InputArg {
+		t.Errorf("Expected %s, got %s (%v)", InputArg, mode, err)
+	}
+
+	// Test case 2: Long prompts fall back to stdin
+	long := strings.Repeat("x", maxArgBytes()+1)
+	mode, err = p.inputFor(long)
+	if err != nil || mode != InputStdin {
+		t.Errorf("Expected %s, got %s (%v)", InputStdin, mode, err)
+	}
+
+	// Test case 3: Without stdin support or a file flag the prompt is rejected
+	p.acceptsStdin = false
+	_, err = p.inputFor(long)
+	var tooLarge *PromptTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Errorf("Expected a PromptTooLargeError, got %v", err)
+	}
+
+	// Test case 4: A configured limit applies regardless of input mode
+	p.SetMaxPromptBytes(3)
+	if _, err := p.inputFor("hello"); !errors.As(err, &tooLarge) {
+		t.Errorf("Expected a PromptTooLargeError, got %v", err)
+	}
+}
+
+func TestCommandProviderStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on cat")
+	}
+
+	p, err := NewCommandProvider("cat", "")
+	if err != nil {
+		t.Fatalf("NewCommandProvider returned an error: %v", err)
+	}
+
+	var streamed strings.Builder
+	resp, err := p.Complete(context.Background(), Request{Prompt: "echoed prompt", OnChunk: func(c Chunk) {
+		streamed.WriteString(c.Text)
+	}})
+	if err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+	if resp.Text != "echoed prompt" {
+		t.Errorf("Expected the prompt to be echoed, got %q", resp.Text)
+	}
+	if streamed.String() != resp.Text {
+		t.Errorf("Expected the output to be streamed, got %q", streamed.String())
+	}
+
+	// A tool run without a model reports the one it uses by default, so its usage can be priced
+	p.defaultModel = defaultGeminiModel
+	if resp, err := p.Complete(context.Background(), Request{Prompt: "p"}); err != nil || resp.Model != defaultGeminiModel {
+		t.Errorf("Expected the default model %s, got %+v (%v)", defaultGeminiModel, resp, err)
+	}
+}
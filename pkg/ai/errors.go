@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies why an AI call failed.
+type ErrorKind string
+
+const (
+	KindUnknown   ErrorKind = "unknown"
+	KindRateLimit ErrorKind = "rate limit"
+	KindAuth      ErrorKind = "authentication"
+	KindQuota     ErrorKind = "quota"
+	KindTimeout   ErrorKind = "timeout"
+	KindNetwork   ErrorKind = "network"
+	KindMalformed ErrorKind = "malformed output"
+)
+
+// Error is a classified provider failure. Commands can use errors.As to react to
+// the kind of failure, e.g. by pointing the user at their credentials.
+type Error struct {
+	Kind     ErrorKind
+	Provider string
+	// ExitCode is set for CLI providers, StatusCode for HTTP providers.
+	ExitCode   int
+	StatusCode int
+	// RetryAfter is the delay requested by the backend, if any.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed (%s): %v", e.Provider, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Transient reports whether the same request may succeed if retried.
+func (e *Error) Transient() bool {
+	switch e.Kind {
+	case KindRateLimit, KindTimeout, KindNetwork, KindMalformed:
+		return true
+	}
+	return false
+}
+
+// Phrases searched for in stderr or response bodies, checked in this order so that
+// e.g. "quota exceeded" isn't mistaken for an ordinary rate limit.
+var classifiers = []struct {
+	kind    ErrorKind
+	phrases []string
+}{
+	{KindQuota, []string{"insufficient_quota", "quota exceeded", "exceeded your current quota", "billing"}},
+	{KindAuth, []string{"unauthorized", "unauthenticated", "invalid api key", "api key not valid", "invalid_api_key", "permission denied", "authentication", "not logged in"}},
+	{KindRateLimit, []string{"rate limit", "rate_limit", "ratelimit", "too many requests", "resource_exhausted", "resource exhausted", "overloaded"}},
+	{KindTimeout, []string{"timed out", "timeout", "deadline exceeded", "deadline_exceeded"}},
+	{KindNetwork, []string{"connection refused", "connection reset", "no such host", "network is unreachable", "temporarily unavailable", "service unavailable", "bad gateway", "broken pipe", "tls handshake"}},
+}
+
+// Classify guesses the kind of failure from a process exit code, an HTTP status
+// code and the error output. Zero codes are ignored.
+func Classify(exitCode int, statusCode int, output string) ErrorKind {
+	lower := strings.ToLower(output)
+	for _, c := range classifiers {
+		for _, phrase := range c.phrases {
+			if strings.Contains(lower, phrase) {
+				return c.kind
+			}
+		}
+	}
+
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return KindAuth
+	case http.StatusPaymentRequired:
+		return KindQuota
+	case http.StatusTooManyRequests:
+		return KindRateLimit
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return KindTimeout
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return KindNetwork
+	}
+	if statusCode >= 500 {
+		return KindNetwork
+	}
+
+	// 124 is the conventional exit status of timeout(1).
+	if exitCode == 124 {
+		return KindTimeout
+	}
+	return KindUnknown
+}
@@ -0,0 +1,250 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4o-mini"
+)
+
+// OpenAIProvider talks to any server implementing the OpenAI chat completions API,
+// including local servers such as llama.cpp, vLLM or Ollama.
+type OpenAIProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+	// maxPromptBytes is the largest prompt sent to the server; 0 means unlimited.
+	maxPromptBytes int
+}
+
+// NewOpenAI returns a provider for an OpenAI-compatible HTTP endpoint.
+// An empty baseURL targets api.openai.com; the API key may be empty for local servers.
+func NewOpenAI(baseURL string, apiKey string, model string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+	return &OpenAIProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{},
+	}
+}
+
+// SetMaxPromptBytes limits the size of prompts sent to the server; 0 removes the limit.
+func (p *OpenAIProvider) SetMaxPromptBytes(n int) { p.maxPromptBytes = n }
+
+// Name returns the provider name.
+func (p *OpenAIProvider) Name() string { return ProviderOpenAI }
+
+// Model returns the configured model.
+func (p *OpenAIProvider) Model() string { return p.model }
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage chatUsage `json:"usage"`
+}
+
+// chatStreamEvent is one server-sent event of a streamed chat completion.
+type chatStreamEvent struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta chatMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage"`
+}
+
+// Complete posts the prompt as a single user message and returns the first choice.
+// When the request has a chunk callback the response is streamed.
+func (p *OpenAIProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	if p.maxPromptBytes > 0 && len(req.Prompt) > p.maxPromptBytes {
+		return nil, &PromptTooLargeError{Provider: ProviderOpenAI, Size: len(req.Prompt), Limit: p.maxPromptBytes, Reason: "(configured limit)"}
+	}
+
+	model := modelFor(p, req)
+	chatReq := chatRequest{
+		Model:    model,
+		Messages: []chatMessage{{Role: "user", Content: req.Prompt}},
+	}
+	if req.OnChunk != nil {
+		chatReq.Stream = true
+		chatReq.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	url := p.baseURL + "/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	start := time.Now()
+	httpResp, err := p.client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("request to %s was stopped: %w", url, context.Cause(ctx))
+		}
+		kind := KindNetwork
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			kind = KindTimeout
+		}
+		return nil, &Error{Kind: kind, Provider: ProviderOpenAI, Err: fmt.Errorf("error calling %s: %w", url, err)}
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(httpResp.Body)
+		body := strings.TrimSpace(string(respBody))
+		return nil, &Error{
+			Kind:       Classify(0, httpResp.StatusCode, body),
+			Provider:   ProviderOpenAI,
+			StatusCode: httpResp.StatusCode,
+			RetryAfter: parseRetryAfter(httpResp.Header.Get("Retry-After")),
+			Err:        fmt.Errorf("request to %s failed with %s: %s", url, httpResp.Status, body),
+		}
+	}
+
+	var resp *Response
+	if chatReq.Stream {
+		resp, err = p.readStream(ctx, url, httpResp.Body, req)
+	} else {
+		resp, err = p.readResponse(url, httpResp.Body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(resp.Text) == "" {
+		return nil, &Error{Kind: KindMalformed, Provider: ProviderOpenAI, Err: fmt.Errorf("response from %s contained no text", url)}
+	}
+	if resp.Model == "" {
+		resp.Model = model
+	}
+	resp.Duration = time.Since(start)
+	return resp, nil
+}
+
+// readResponse decodes a complete, non-streamed chat completion.
+func (p *OpenAIProvider) readResponse(url string, body io.Reader) (*Response, error) {
+	respBody, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, &Error{Kind: KindNetwork, Provider: ProviderOpenAI, Err: fmt.Errorf("error reading response from %s: %w", url, err)}
+	}
+	var parsed chatResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, &Error{Kind: KindMalformed, Provider: ProviderOpenAI, Err: fmt.Errorf("error decoding response from %s: %w", url, err)}
+	}
+	if len(parsed.Choices) == 0 {
+		return nil, &Error{Kind: KindMalformed, Provider: ProviderOpenAI, Err: fmt.Errorf("response from %s contained no choices", url)}
+	}
+	return &Response{
+		Text:           parsed.Choices[0].Message.Content,
+		Provider:       ProviderOpenAI,
+		Model:          parsed.Model,
+		PromptTokens:   parsed.Usage.PromptTokens,
+		ResponseTokens: parsed.Usage.CompletionTokens,
+	}, nil
+}
+
+// readStream reads server-sent events, passing each content delta to the request's
+// chunk callback, until the server sends [DONE] or closes the stream.
+func (p *OpenAIProvider) readStream(ctx context.Context, url string, body io.Reader, req Request) (*Response, error) {
+	resp := &Response{Provider: ProviderOpenAI}
+	var text strings.Builder
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var event chatStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, &Error{Kind: KindMalformed, Provider: ProviderOpenAI, Err: fmt.Errorf("error decoding stream event from %s: %w", url, err)}
+		}
+		if event.Model != "" {
+			resp.Model = event.Model
+		}
+		if event.Usage != nil {
+			resp.PromptTokens = event.Usage.PromptTokens
+			resp.ResponseTokens = event.Usage.CompletionTokens
+		}
+		if len(event.Choices) > 0 && event.Choices[0].Delta.Content != "" {
+			text.WriteString(event.Choices[0].Delta.Content)
+			emit(req, Chunk{Text: event.Choices[0].Delta.Content})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("request to %s was stopped: %w", url, context.Cause(ctx))
+		}
+		return nil, &Error{Kind: KindNetwork, Provider: ProviderOpenAI, Err: fmt.Errorf("error reading stream from %s: %w", url, err)}
+	}
+
+	resp.Text = text.String()
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,42 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("Expected a streaming request, got %+v (%v)", req, err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"Hello", ", ", "world"} {
+			fmt.Fprintf(w, "data: {\"model\":\"m1\",\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", delta)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":3}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	var chunks []string
+	p := NewOpenAI(server.URL, "", "m1")
+	resp, err := p.Complete(context.Background(), Request{Prompt: "hi", OnChunk: func(c Chunk) {
+		chunks = append(chunks, c.Text)
+	}})
+	if err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+	if resp.Text != "Hello, world" || strings.Join(chunks, "") != resp.Text || len(chunks) != 3 {
+		t.Errorf("Expected 'Hello, world' in 3 chunks, got %q in %v", resp.Text, chunks)
+	}
+	if resp.PromptTokens != 5 || resp.ResponseTokens != 3 {
+		t.Errorf("Expected usage 5/3, got %d/%d", resp.PromptTokens, resp.ResponseTokens)
+	}
+}
@@ -0,0 +1,111 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Record/replay modes accepted by NewRecorder.
+const (
+	ModeLive   = "live"
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// ErrNoRecording is returned in replay mode when no cassette matches a request.
+var ErrNoRecording = errors.New("no recorded response")
+
+// cassette is the on-disk form of one recorded prompt/response pair.
+type cassette struct {
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model"`
+	Prompt     string    `json:"prompt"`
+	Response   string    `json:"response"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Recorder wraps a Provider to record every response to a cassette directory,
+// or to replay previously recorded responses without calling the provider.
+type Recorder struct {
+	provider Provider
+	mode     string
+	dir      string
+}
+
+// NewRecorder returns p wrapped for the given mode. The live mode returns p unchanged.
+func NewRecorder(p Provider, mode string, dir string) (Provider, error) {
+	switch mode {
+	case "", ModeLive:
+		return p, nil
+	case ModeRecord, ModeReplay:
+		return &Recorder{provider: p, mode: mode, dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown AI mode '%s' (expected %s, %s or %s)", mode, ModeLive, ModeRecord, ModeReplay)
+	}
+}
+
+// Name returns the wrapped provider's name.
+func (r *Recorder) Name() string { return r.provider.Name() }
+
+// Model returns the wrapped provider's model.
+func (r *Recorder) Model() string { return r.provider.Model() }
+
+// Complete replays a recorded response, or calls the provider and records its response.
+func (r *Recorder) Complete(ctx context.Context, req Request) (*Response, error) {
+	key := RequestKey(r.provider, req)
+	path := filepath.Join(r.dir, key+".json")
+
+	if r.mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w for %s (%s) in %s; re-run with PDT_AI_MODE=%s to capture it", ErrNoRecording, r.provider.Name(), key, r.dir, ModeRecord)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading cassette %s: %w", path, err)
+		}
+		var c cassette
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("error decoding cassette %s: %w", path, err)
+		}
+		emit(req, Chunk{Text: c.Response})
+		return &Response{Text: c.Response, Provider: c.Provider, Model: c.Model}, nil
+	}
+
+	resp, err := r.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(cassette{
+		Provider:   r.provider.Name(),
+		Model:      modelFor(r.provider, req),
+		Prompt:     req.Prompt,
+		Response:   resp.Text,
+		RecordedAt: time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating cassette directory: %w", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("error writing cassette %s: %w", path, err)
+	}
+	return resp, nil
+}
+
+// RequestKey identifies a request by hashing the provider, the model it resolves to and the prompt.
+func RequestKey(p Provider, req Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", p.Name(), modelFor(p, req), req.Prompt)
+	return hex.EncodeToString(h.Sum(nil))
+}
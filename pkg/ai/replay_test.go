@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fakeProvider returns canned text and counts how often it is called.
+type fakeProvider struct {
+	name  string
+	model string
+	text  string
+	err   error
+	calls int
+}
+
+func (f *fakeProvider) Name() string  { return f.name }
+func (f *fakeProvider) Model() string { return f.model }
+
+func (f *fakeProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Response{Text: f.text, Provider: f.name, Model: modelFor(f, req)}, nil
+}
+
+func TestRecorderRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	live := &fakeProvider{name: "fake", model: "m1", text: "generated"}
+
+	// Test case 1: Record mode calls the provider and writes a cassette
+	recorder, err := NewRecorder(live, ModeRecord, dir)
+	if err != nil {
+		t.Fatalf("NewRecorder returned an error: %v", err)
+	}
+	resp, err := recorder.Complete(context.Background(), Request{Prompt: "write code"})
+	if err != nil {
+		t.Fatalf("Complete returned an error in record mode: %v", err)
+	}
+	if resp.Text != "generated" || live.calls != 1 {
+		t.Errorf("Expected one live call returning 'generated', got %d calls and %q", live.calls, resp.Text)
+	}
+
+	// Test case 2: Replay mode returns the recording without calling the provider
+	replayer, err := NewRecorder(live, ModeReplay, dir)
+	if err != nil {
+		t.Fatalf("NewRecorder returned an error: %v", err)
+	}
+	resp, err = replayer.Complete(context.Background(), Request{Prompt: "write code"})
+	if err != nil {
+		t.Fatalf("Complete returned an error in replay mode: %v", err)
+	}
+	if resp.Text != "generated" || live.calls != 1 {
+		t.Errorf("Expected replayed 'generated' with no new calls, got %d calls and %q", live.calls, resp.Text)
+	}
+
+	// Test case 3: A different model is a different cassette
+	_, err = replayer.Complete(context.Background(), Request{Prompt: "write code", Model: "m2"})
+	if !errors.Is(err, ErrNoRecording) {
+		t.Errorf("Expected ErrNoRecording, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how transient failures are retried.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// BaseDelay is the backoff ceiling for the first retry; it doubles each time up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Budget caps the total time spent on one request including waits; 0 means no cap.
+	Budget time.Duration
+}
+
+// DefaultRetryPolicy retries three times with backoff between 2s and 30s.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  2 * time.Second,
+		MaxDelay:   30 * time.Second,
+		Budget:     5 * time.Minute,
+	}
+}
+
+// Retrying wraps a Provider and retries transient failures with jittered exponential backoff.
+type Retrying struct {
+	provider Provider
+	policy   RetryPolicy
+	// OnRetry is called before each wait, e.g. to tell the user what is happening.
+	OnRetry func(attempt int, err *Error, delay time.Duration)
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetrying returns p wrapped with the given retry policy.
+func NewRetrying(p Provider, policy RetryPolicy) *Retrying {
+	return &Retrying{provider: p, policy: policy, sleep: sleepContext}
+}
+
+// Name returns the wrapped provider's name.
+func (r *Retrying) Name() string { return r.provider.Name() }
+
+// Model returns the wrapped provider's model.
+func (r *Retrying) Model() string { return r.provider.Model() }
+
+// Complete calls the provider, retrying failures classified as transient until the
+// retry count or time budget runs out.
+func (r *Retrying) Complete(ctx context.Context, req Request) (*Response, error) {
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		resp, err := r.provider.Complete(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+
+		var aiErr *Error
+		if !errors.As(err, &aiErr) || !aiErr.Transient() || ctx.Err() != nil {
+			return nil, err
+		}
+		if attempt >= r.policy.MaxRetries {
+			if attempt == 0 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		delay := r.backoff(attempt)
+		if aiErr.RetryAfter > delay {
+			delay = aiErr.RetryAfter
+		}
+		if r.policy.Budget > 0 && time.Since(start)+delay > r.policy.Budget {
+			return nil, fmt.Errorf("giving up after %d attempts, retry budget of %v exhausted: %w", attempt+1, r.policy.Budget, err)
+		}
+
+		emit(req, Chunk{Restart: true})
+		if r.OnRetry != nil {
+			r.OnRetry(attempt+1, aiErr, delay)
+		}
+		if err := r.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns a "full jitter" delay: a random duration up to BaseDelay*2^attempt, capped at MaxDelay.
+func (r *Retrying) backoff(attempt int) time.Duration {
+	ceiling := r.policy.BaseDelay
+	for i := 0; i < attempt && ceiling < r.policy.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	if r.policy.MaxDelay > 0 && ceiling > r.policy.MaxDelay {
+		ceiling = r.policy.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		exitCode   int
+		statusCode int
+		output     string
+		expected   ErrorKind
+	}{
+		{1, 0, "Error: 429 Too Many Requests", KindRateLimit},
+		{1, 0, "RESOURCE_EXHAUSTED: quota exceeded for project", KindQuota},
+		{1, 0, "API key not valid. Please pass a valid API key.", KindAuth},
+		{1, 0, "dial tcp: connection refused", KindNetwork},
+		{124, 0, "", KindTimeout},
+		{0, 401, "", KindAuth},
+		{0, 429, `{"error":{"type":"insufficient_quota"}}`, KindQuota},
+		{0, 503, "", KindNetwork},
+		{1, 0, "panic: something unexpected", KindUnknown},
+	}
+
+	for _, c := range cases {
+		if actual := Classify(c.exitCode, c.statusCode, c.output); actual != c.expected {
+			t.Errorf("Classify(%d, %d, %q): expected %s, got %s", c.exitCode, c.statusCode, c.output, c.expected, actual)
+		}
+	}
+}
+
+// flakyProvider fails with the given errors before succeeding.
+type flakyProvider struct {
+	fakeProvider
+	failures []error
+}
+
+func (f *flakyProvider) Complete(ctx context.Context, req Request) (*Response, error) {
+	if f.calls < len(f.failures) {
+		err := f.failures[f.calls]
+		f.calls++
+		return nil, err
+	}
+	f.calls++
+	return &Response{Text: "ok"}, nil
+}
+
+func TestRetryingRetriesTransientErrors(t *testing.T) {
+	rateLimited := &Error{Kind: KindRateLimit, Provider: "fake", Err: errors.New("429")}
+	p := &flakyProvider{failures: []error{rateLimited, rateLimited}}
+
+	r := NewRetrying(p, RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	var waits int
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		waits++
+		return nil
+	}
+
+	resp, err := r.Complete(context.Background(), Request{Prompt: "p"})
+	if err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+	if resp.Text != "ok" || p.calls != 3 || waits != 2 {
+		t.Errorf("Expected success after 3 calls and 2 waits, got %q after %d calls and %d waits", resp.Text, p.calls, waits)
+	}
+}
+
+func TestRetryingStopsOnPermanentErrors(t *testing.T) {
+	authErr := &Error{Kind: KindAuth, Provider: "fake", Err: errors.New("401")}
+	p := &flakyProvider{failures: []error{authErr}}
+
+	r := NewRetrying(p, RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})
+	r.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	_, err := r.Complete(context.Background(), Request{Prompt: "p"})
+	var aiErr *Error
+	if !errors.As(err, &aiErr) || aiErr.Kind != KindAuth {
+		t.Errorf("Expected the authentication error to be returned, got %v", err)
+	}
+	if p.calls != 1 {
+		t.Errorf("Expected 1 call, got %d", p.calls)
+	}
+}
+
+func TestRetryingGivesUp(t *testing.T) {
+	timeout := &Error{Kind: KindTimeout, Provider: "fake", Err: errors.New("timed out")}
+	p := &flakyProvider{failures: []error{timeout, timeout, timeout}}
+
+	r := NewRetrying(p, RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond})
+	r.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	_, err := r.Complete(context.Background(), Request{Prompt: "p"})
+	if !errors.Is(err, timeout) {
+		t.Errorf("Expected the last timeout to be returned, got %v", err)
+	}
+	if p.calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", p.calls)
+	}
+}
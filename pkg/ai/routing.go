@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Target is a provider and, optionally, the model to use with it.
+type Target struct {
+	Provider string
+	Model    string
+}
+
+// ParseTarget parses "provider" or "provider:model", e.g. "openai:gpt-4o-mini".
+func ParseTarget(s string) (Target, error) {
+	s = strings.TrimSpace(s)
+	provider, model := s, ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		provider, model = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	if provider == "" {
+		return Target{}, fmt.Errorf("invalid AI target '%s': expected provider or provider:model", s)
+	}
+	return Target{Provider: provider, Model: model}, nil
+}
+
+// ParseTargets parses a comma-separated list of targets.
+func ParseTargets(s string) ([]Target, error) {
+	var targets []Target
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		target, err := ParseTarget(item)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func (t Target) String() string {
+	if t.Model == "" {
+		return t.Provider
+	}
+	return t.Provider + ":" + t.Model
+}
+
+// Routes maps a command, or a command and its first argument such as "write.tweet",
+// to the target that should handle its prompts.
+type Routes map[string]Target
+
+// ParseRoutes parses a comma-separated list of key=target pairs, e.g.
+// "code=gemini-cli:gemini-2.5-pro,commit=openai:gpt-4o-mini".
+func ParseRoutes(s string) (Routes, error) {
+	routes := Routes{}
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return nil, fmt.Errorf("invalid AI route '%s': expected command=provider[:model]", strings.TrimSpace(item))
+		}
+		target, err := ParseTarget(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid AI route for '%s': %w", key, err)
+		}
+		routes[key] = target
+	}
+	return routes, nil
+}
+
+// Lookup returns the route for a command, preferring the more specific
+// "command.arg" key over "command".
+func (r Routes) Lookup(command string, arg string) (Target, string, bool) {
+	if arg != "" {
+		if target, ok := r[command+"."+arg]; ok {
+			return target, command + "." + arg, true
+		}
+	}
+	target, ok := r[command]
+	return target, command, ok
+}
+
+// Fallback tries each of its providers in order until one succeeds.
+type Fallback struct {
+	providers []Provider
+	// OnFallback is called when a provider fails and the next one is about to be tried.
+	OnFallback func(failed Provider, err error, next Provider)
+}
+
+// NewFallback returns a provider that falls back from primary to each of fallbacks in turn.
+func NewFallback(primary Provider, fallbacks ...Provider) *Fallback {
+	return &Fallback{providers: append([]Provider{primary}, fallbacks...)}
+}
+
+// Name returns the primary provider's name.
+func (f *Fallback) Name() string { return f.providers[0].Name() }
+
+// Model returns the primary provider's model.
+func (f *Fallback) Model() string { return f.providers[0].Model() }
+
+// Complete sends the request to each provider in turn and returns the first success.
+// A cancelled or timed-out context stops the chain. The error of the last provider
+// is returned when all of them fail.
+func (f *Fallback) Complete(ctx context.Context, req Request) (*Response, error) {
+	var err error
+	for i, p := range f.providers {
+		var resp *Response
+		resp, err = p.Complete(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil || i == len(f.providers)-1 {
+			break
+		}
+		emit(req, Chunk{Restart: true})
+		if f.OnFallback != nil {
+			f.OnFallback(p, err, f.providers[i+1])
+		}
+	}
+	if len(f.providers) > 1 && ctx.Err() == nil {
+		return nil, fmt.Errorf("all %d AI providers failed, the last with: %w", len(f.providers), err)
+	}
+	return nil, err
+}
@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes("code=gemini-cli:gemini-2.5-pro, commit=openai:gpt-4o-mini,write.tweet=openai")
+	if err != nil {
+		t.Fatalf("ParseRoutes returned an error: %v", err)
+	}
+
+	// Test case 1: A command route
+	target, key, ok := routes.Lookup("code", "")
+	if !ok || key != "code" || target != (Target{Provider: ProviderGemini, Model: "gemini-2.5-pro"}) {
+		t.Errorf("Expected the code route, got %v from '%s' (%v)", target, key, ok)
+	}
+
+	// Test case 2: A command and argument route wins, and falls back to nothing
+	target, key, ok = routes.Lookup("write", "tweet")
+	if !ok || key != "write.tweet" || target.String() != "openai" {
+		t.Errorf("Expected the write.tweet route, got %v from '%s' (%v)", target, key, ok)
+	}
+	if _, _, ok := routes.Lookup("write", "blog"); ok {
+		t.Errorf("Expected no route for write blog")
+	}
+
+	// Test case 3: Malformed routes are rejected
+	for _, value := range []string{"code", "=openai", "code=:model"} {
+		if _, err := ParseRoutes(value); err == nil {
+			t.Errorf("Expected an error for '%s'", value)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	failing := &fakeProvider{name: "primary", err: &Error{Kind: KindQuota, Provider: "primary", Err: errors.New("quota")}}
+	working := &fakeProvider{name: "secondary", text: "ok"}
+
+	// Test case 1: A failure falls back to the next provider
+	fallback := NewFallback(failing, working)
+	var fellBack string
+	fallback.OnFallback = func(failed Provider, err error, next Provider) {
+		fellBack = failed.Name() + "->" + next.Name()
+	}
+	var restarted bool
+	resp, err := fallback.Complete(context.Background(), Request{Prompt: "p", OnChunk: func(c Chunk) {
+		restarted = restarted || c.Restart
+	}})
+	if err != nil || resp.Provider != "secondary" {
+		t.Fatalf("Expected the secondary provider to answer, got %v (%v)", resp, err)
+	}
+	if fellBack != "primary->secondary" || !restarted {
+		t.Errorf("Expected the fallback to be reported and the stream restarted, got '%s' (%v)", fellBack, restarted)
+	}
+
+	// Test case 2: When every provider fails the last error is kept
+	fallback = NewFallback(failing, failing)
+	_, err = fallback.Complete(context.Background(), Request{Prompt: "p"})
+	var aiErr *Error
+	if !errors.As(err, &aiErr) || aiErr.Kind != KindQuota {
+		t.Errorf("Expected the quota error, got %v", err)
+	}
+
+	// Test case 3: A cancelled context stops the chain
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	working.calls = 0
+	NewFallback(failing, working).Complete(ctx, Request{Prompt: "p"})
+	if working.calls != 0 {
+		t.Errorf("Expected no fallback after cancellation, got %d calls", working.calls)
+	}
+}
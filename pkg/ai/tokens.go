@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken is the average number of characters per token for each provider's
+// tokenizer on a mix of prose and code. Unknown providers use a conservative ratio.
+var charsPerToken = map[string]float64{
+	ProviderGemini: 4.0,
+	ProviderOpenAI: 3.6,
+}
+
+const defaultCharsPerToken = 3.2
+
+// EstimateTokens approximates how many tokens text uses with the given provider.
+// It errs on the high side so that budget checks fail safe.
+func EstimateTokens(provider string, text string) int {
+	if text == "" {
+		return 0
+	}
+	ratio, ok := charsPerToken[provider]
+	if !ok {
+		ratio = defaultCharsPerToken
+	}
+	byChars := int(float64(utf8.RuneCountInString(text))/ratio) + 1
+	// Whitespace-separated words rarely take less than one token each.
+	byWords := len(strings.Fields(text))
+	if byWords > byChars {
+		return byWords
+	}
+	return byChars
+}
+
+// ContextWindow returns the context window, in tokens, of a provider and model.
+func ContextWindow(provider string, model string) int {
+	switch provider {
+	case ProviderGemini:
+		return 1000000
+	case ProviderOpenAI:
+		switch {
+		case strings.HasPrefix(model, "gpt-4.1"):
+			return 1000000
+		case strings.HasPrefix(model, "gpt-4o"), strings.HasPrefix(model, "gpt-4-turbo"), strings.HasPrefix(model, "o1"), strings.HasPrefix(model, "o3"), strings.HasPrefix(model, "o4"):
+			return 128000
+		case strings.HasPrefix(model, "gpt-3.5"):
+			return 16000
+		}
+		return 32000
+	}
+	// Local models behind the command provider often run with small contexts.
+	return 8192
+}
+
+// DefaultContextBudget is the number of prompt tokens allowed for a provider and
+// model, leaving room in the context window for the response.
+func DefaultContextBudget(provider string, model string) int {
+	window := ContextWindow(provider, model)
+	reserve := window / 4
+	if reserve > 16000 {
+		reserve = 16000
+	}
+	return window - reserve
+}
@@ -0,0 +1,661 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/productdevtool/pdt-cli/pkg/ai"
+	"github.com/productdevtool/pdt-cli/pkg/fs"
+	"github.com/productdevtool/pdt-cli/pkg/runner"
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the files pdt reads the project configuration from, relative to the
+// project root, in order of preference.
+var FileNames = []string{"pdt.yaml", filepath.Join(".pdt", "config.yaml")}
+
+// Config is the project configuration.
+type Config struct {
+	AI    AI    `yaml:"ai,omitempty"`
+	Cache Cache `yaml:"cache,omitempty"`
+	Usage Usage `yaml:"usage,omitempty"`
+	// Timeout limits how long any command runs, and Timeouts overrides it per command.
+	Timeout  time.Duration            `yaml:"timeout,omitempty"`
+	Timeouts map[string]time.Duration `yaml:"timeouts,omitempty"`
+	// Commands are the project's named commands, such as build and deploy.
+	Commands   map[string]Command `yaml:"commands,omitempty"`
+	Validation Validation         `yaml:"validation,omitempty"`
+	Paths      Paths              `yaml:"paths,omitempty"`
+	Write      Write              `yaml:"write,omitempty"`
+	UI         UI                 `yaml:"ui,omitempty"`
+
+	// Path is the project file the configuration was read from; it is empty when the
+	// project has no configuration file.
+	Path string `yaml:"-"`
+	// Layers are the files that were read, the user's before the project's.
+	Layers []Layer `yaml:"-"`
+}
+
+// AI configures the AI provider. API keys are deliberately not part of it; they are
+// only read from the environment.
+type AI struct {
+	Provider       string            `yaml:"provider,omitempty"`
+	Model          string            `yaml:"model,omitempty"`
+	Command        string            `yaml:"command,omitempty"`
+	BaseURL        string            `yaml:"base_url,omitempty"`
+	Input          string            `yaml:"input,omitempty"`
+	PromptFileFlag string            `yaml:"prompt_file_flag,omitempty"`
+	MaxPromptBytes int               `yaml:"max_prompt_bytes,omitempty"`
+	MaxRetries     *int              `yaml:"max_retries,omitempty"`
+	RetryBudget    time.Duration     `yaml:"retry_budget,omitempty"`
+	Structured     *bool             `yaml:"structured,omitempty"`
+	ContextBudget  int               `yaml:"context_budget,omitempty"`
+	TrimPrompts    *bool             `yaml:"trim_prompts,omitempty"`
+	Routes         map[string]string `yaml:"routes,omitempty"`
+	Fallback       []string          `yaml:"fallback,omitempty"`
+	Mode           string            `yaml:"mode,omitempty"`
+	CassetteDir    string            `yaml:"cassette_dir,omitempty"`
+}
+
+// Cache configures the AI response cache.
+type Cache struct {
+	Enabled *bool         `yaml:"enabled,omitempty"`
+	Dir     string        `yaml:"dir,omitempty"`
+	TTL     time.Duration `yaml:"ttl,omitempty"`
+	MaxSize string        `yaml:"max_size,omitempty"`
+}
+
+// Usage configures token and cost tracking.
+type Usage struct {
+	Ledger        string  `yaml:"ledger,omitempty"`
+	MonthlyBudget float64 `yaml:"monthly_budget,omitempty"`
+	Prices        string  `yaml:"prices,omitempty"`
+}
+
+// Command is a command line and how to run it. In YAML it is either the command line
+// on its own or a mapping with run and the other settings.
+type Command struct {
+	Run             string            `yaml:"run"`
+	Name            string            `yaml:"name,omitempty"`
+	Cwd             string            `yaml:"cwd,omitempty"`
+	Env             map[string]string `yaml:"env,omitempty"`
+	Timeout         time.Duration     `yaml:"timeout,omitempty"`
+	ContinueOnError bool              `yaml:"continue_on_error,omitempty"`
+	Needs           []string          `yaml:"needs,omitempty"`
+	Results         string            `yaml:"results,omitempty"`
+}
+
+// UnmarshalYAML accepts a bare command line as well as a mapping.
+func (c *Command) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = Command{Run: node.Value}
+		return nil
+	}
+	type plain Command
+	return node.Decode((*plain)(c))
+}
+
+// Runner returns the command as the runner runs it.
+func (c Command) Runner() runner.Command {
+	command := runner.Command{
+		Run:             c.Run,
+		Dir:             c.Cwd,
+		Timeout:         c.Timeout,
+		ContinueOnError: c.ContinueOnError,
+		Name:            c.Name,
+		Needs:           c.Needs,
+		Results:         c.Results,
+	}
+	keys := make([]string, 0, len(c.Env))
+	for key := range c.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		command.Env = append(command.Env, key+"="+c.Env[key])
+	}
+	return command
+}
+
+// Validation configures the automated validation `pdt code` runs.
+type Validation struct {
+	// Jobs is how many steps run at once; 0 leaves it to the number of CPUs.
+	Jobs        int       `yaml:"jobs,omitempty"`
+	FixAttempts *int      `yaml:"fix_attempts,omitempty"`
+	Report      string    `yaml:"report,omitempty"`
+	Steps       []Command `yaml:"steps,omitempty"`
+}
+
+// Paths are where pdt keeps its documents, relative to the project root.
+type Paths struct {
+	ProjectDescription string `yaml:"project_description,omitempty"`
+	Todo               string `yaml:"todo,omitempty"`
+	// Todos holds the work and done directories of tasks.
+	Todos     string `yaml:"todos,omitempty"`
+	Specs     string `yaml:"specs,omitempty"`
+	Templates string `yaml:"templates,omitempty"`
+	Handbook  string `yaml:"handbook,omitempty"`
+	Content   string `yaml:"content,omitempty"`
+}
+
+// WorkDir returns the directory of the task in progress.
+func (p Paths) WorkDir() string { return filepath.Join(p.Todos, "work") }
+
+// DoneDir returns the directory finished tasks are moved to.
+func (p Paths) DoneDir() string { return filepath.Join(p.Todos, "done") }
+
+// Write configures where AI-generated files may be written.
+type Write struct {
+	Allow      []string `yaml:"allow,omitempty"`
+	Deny       []string `yaml:"deny,omitempty"`
+	JournalDir string   `yaml:"journal_dir,omitempty"`
+}
+
+// UI configures how pdt interacts with the person running it.
+type UI struct {
+	// Editor is the command that edits files, such as "code --wait"; it defaults to
+	// $VISUAL or $EDITOR.
+	Editor string `yaml:"editor,omitempty"`
+	// Color is auto, always or never.
+	Color string `yaml:"color,omitempty"`
+}
+
+// Default returns the configuration of a project without a configuration file.
+func Default() *Config {
+	c := &Config{}
+	c.setDefaults()
+	return c
+}
+
+// setDefaults fills in the paths that aren't set.
+func (c *Config) setDefaults() {
+	defaults := []struct {
+		value    *string
+		fallback string
+	}{
+		{&c.Paths.ProjectDescription, "docs/project-description.md"},
+		{&c.Paths.Todo, "docs/todo.md"},
+		{&c.Paths.Todos, "docs/todos"},
+		{&c.Paths.Specs, "docs/specs"},
+		{&c.Paths.Templates, "pdt_templates"},
+		{&c.Paths.Handbook, "docs/handbook"},
+		{&c.Paths.Content, "content"},
+	}
+	for _, d := range defaults {
+		if *d.value == "" {
+			*d.value = d.fallback
+		}
+	}
+}
+
+// FindRoot returns the project root for dir: the nearest of dir and its parents that
+// has one of FileNames or a .git entry. It returns dir when none has.
+func FindRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	markers := append([]string{".git"}, FileNames...)
+	for candidate := dir; ; {
+		for _, name := range markers {
+			if _, err := os.Stat(filepath.Join(candidate, name)); err == nil {
+				return candidate, nil
+			}
+		}
+		parent := filepath.Dir(candidate)
+		if parent == candidate {
+			return dir, nil
+		}
+		candidate = parent
+	}
+}
+
+// Root returns the directory pdt works in when started in dir: explicit when it is
+// given, dir itself when search is false, as pdt init sets up the directory it runs
+// in, and FindRoot(dir) otherwise.
+func Root(dir, explicit string, search bool) (string, error) {
+	switch {
+	case explicit != "":
+		info, err := os.Stat(explicit)
+		if err != nil {
+			return "", err
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("%s is not a directory", explicit)
+		}
+		return explicit, nil
+	case !search:
+		return filepath.Abs(dir)
+	default:
+		return FindRoot(dir)
+	}
+}
+
+// Load reads the user's configuration file and the first of FileNames that exists in
+// root, the project's settings overriding the user's key by key. It returns the
+// defaults when there is neither.
+func Load(root string) (*Config, error) {
+	c := &Config{}
+	if path, err := UserPath(); err == nil {
+		if _, err := c.load(SourceUser, path, path); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range FileNames {
+		path := filepath.Join(root, name)
+		found, err := c.load(SourceProject, path, name)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			c.Path = path
+			break
+		}
+	}
+	c.setDefaults()
+	return c, nil
+}
+
+// load reads the file at path, if it exists, over the settings already loaded and
+// adds it to the layers. Errors refer to the file as name.
+func (c *Config) load(source string, path string, name string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	layer, err := parse(data)
+	if err != nil {
+		if errs, ok := err.(Errors); ok {
+			for _, e := range errs {
+				e.File = name
+			}
+			return false, errs
+		}
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	c.Layers = append(c.Layers, Layer{Source: source, Path: path, Config: layer})
+	return true, nil
+}
+
+// Parse parses and validates a configuration file. Problems are returned as Errors
+// that name the key they concern.
+func Parse(data []byte) (*Config, error) {
+	c, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	c.setDefaults()
+	return c, nil
+}
+
+// parse is Parse without the defaults, so the configuration only holds what the file
+// sets.
+func parse(data []byte) (*Config, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if len(document.Content) > 0 {
+		var errs Errors
+		lines := map[string]int{}
+		check(document.Content[0], reflect.TypeOf(Config{}), "", lines, &errs)
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		if err := document.Content[0].Decode(c); err != nil {
+			return nil, err
+		}
+		if errs := c.validate(); len(errs) > 0 {
+			for _, e := range errs {
+				e.Line = lineOf(e.Key, lines)
+			}
+			return nil, errs
+		}
+	}
+	return c, nil
+}
+
+// Error is a problem with one key of a configuration file.
+type Error struct {
+	File string
+	// Line is 0 when the problem isn't tied to a line, such as a missing key.
+	Line    int
+	Key     string
+	Message string
+}
+
+func (e *Error) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, e.Line)
+		if e.File == "" {
+			location = fmt.Sprintf("line %d", e.Line)
+		}
+	}
+	var parts []string
+	for _, part := range []string{location, e.Key, e.Message} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ": ")
+}
+
+// Errors lists every problem found in a configuration file.
+type Errors []*Error
+
+func (errs Errors) Error() string {
+	var lines []string
+	for _, e := range errs {
+		lines = append(lines, e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (errs *Errors) add(node *yaml.Node, key string, format string, args ...interface{}) {
+	e := &Error{Key: key, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		e.Line = node.Line
+	}
+	*errs = append(*errs, e)
+}
+
+const apiKeyMessage = "API keys don't belong in a file that may be committed; set PDT_AI_API_KEY in the environment instead"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	commandType  = reflect.TypeOf(Command{})
+)
+
+// check compares a YAML node with the type it is decoded into, reporting unknown keys
+// and values of the wrong kind with their key and line. It records the line of each
+// key in lines.
+func check(node *yaml.Node, t reflect.Type, key string, lines map[string]int, errs *Errors) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if _, ok := lines[key]; !ok {
+		lines[key] = node.Line
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+	switch {
+	case t == durationType:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!str" {
+			errs.add(node, key, "expected a duration such as 30s, 5m or 1h30m")
+		} else if _, err := time.ParseDuration(node.Value); err != nil {
+			errs.add(node, key, "invalid duration %q; expected something like 30s, 5m or 1h30m", node.Value)
+		}
+		return
+	case t == commandType && node.Kind == yaml.ScalarNode:
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		check(node, t.Elem(), key, lines, errs)
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			errs.add(node, key, "expected a mapping of keys to values")
+			return
+		}
+		fields := map[string]reflect.Type{}
+		var names []string
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+				names = append(names, name)
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i], node.Content[i+1]
+			path := join(key, name.Value)
+			fieldType, ok := fields[name.Value]
+			switch {
+			case path == "ai.api_key":
+				errs.add(name, path, apiKeyMessage)
+			case !ok:
+				errs.add(name, path, "unknown key; expected one of %s", strings.Join(names, ", "))
+			default:
+				lines[path] = name.Line
+				check(value, fieldType, path, lines, errs)
+			}
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			errs.add(node, key, "expected a mapping of keys to values")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			lines[join(key, node.Content[i].Value)] = node.Content[i].Line
+			check(node.Content[i+1], t.Elem(), join(key, node.Content[i].Value), lines, errs)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			errs.add(node, key, "expected a list")
+			return
+		}
+		for i, item := range node.Content {
+			check(item, t.Elem(), fmt.Sprintf("%s[%d]", key, i), lines, errs)
+		}
+	default:
+		if node.Kind != yaml.ScalarNode || node.Decode(reflect.New(t).Interface()) != nil {
+			errs.add(node, key, "expected %s", kindName(t))
+		}
+	}
+}
+
+// lineOf returns the line of key, or of the closest enclosing key that has one.
+func lineOf(key string, lines map[string]int) int {
+	for key != "" {
+		if line, ok := lines[key]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return 0
+}
+
+// join appends name to a dotted key.
+func join(key string, name string) string {
+	if key == "" {
+		return name
+	}
+	return key + "." + name
+}
+
+// kindName describes the values a type accepts.
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int64:
+		return "a whole number"
+	case reflect.Float64:
+		return "a number"
+	default:
+		return "a " + t.Kind().String()
+	}
+}
+
+var sizePattern = regexp.MustCompile(`(?i)^\d+\s*(B|KB|MB|GB)?$`)
+
+// validate checks the values that decoded but don't make sense.
+func (c *Config) validate() Errors {
+	var errs Errors
+	invalid := func(key string, format string, args ...interface{}) {
+		errs.add(nil, key, format, args...)
+	}
+
+	switch c.AI.Provider {
+	case "", ai.ProviderGemini, ai.ProviderCommand, ai.ProviderOpenAI:
+	default:
+		invalid("ai.provider", "unknown provider %q; expected %s, %s or %s", c.AI.Provider, ai.ProviderGemini, ai.ProviderCommand, ai.ProviderOpenAI)
+	}
+	switch ai.InputMode(c.AI.Input) {
+	case "", ai.InputStdin, ai.InputArg, ai.InputFile:
+	default:
+		invalid("ai.input", "unknown input %q; expected %s, %s or %s", c.AI.Input, ai.InputStdin, ai.InputArg, ai.InputFile)
+	}
+	switch c.AI.Mode {
+	case "", ai.ModeLive, ai.ModeRecord, ai.ModeReplay:
+	default:
+		invalid("ai.mode", "unknown mode %q; expected %s, %s or %s", c.AI.Mode, ai.ModeLive, ai.ModeRecord, ai.ModeReplay)
+	}
+	for command, target := range c.AI.Routes {
+		if _, err := ai.ParseTarget(target); err != nil {
+			invalid("ai.routes."+command, "%v", err)
+		}
+	}
+	for i, target := range c.AI.Fallback {
+		if _, err := ai.ParseTarget(target); err != nil {
+			invalid(fmt.Sprintf("ai.fallback[%d]", i), "%v", err)
+		}
+	}
+	if c.AI.MaxRetries != nil && *c.AI.MaxRetries < 0 {
+		invalid("ai.max_retries", "must not be negative")
+	}
+	if c.AI.MaxPromptBytes < 0 {
+		invalid("ai.max_prompt_bytes", "must not be negative")
+	}
+	if c.AI.ContextBudget < 0 {
+		invalid("ai.context_budget", "must not be negative")
+	}
+	if c.Cache.MaxSize != "" && !sizePattern.MatchString(strings.TrimSpace(c.Cache.MaxSize)) {
+		invalid("cache.max_size", "invalid size %q; expected a size such as 100MB", c.Cache.MaxSize)
+	}
+	if c.Usage.MonthlyBudget < 0 {
+		invalid("usage.monthly_budget", "must not be negative")
+	}
+	if c.Validation.Jobs < 0 {
+		invalid("validation.jobs", "must not be negative; leave it out or use 0 for the default")
+	}
+	if c.Validation.FixAttempts != nil && *c.Validation.FixAttempts < 0 {
+		invalid("validation.fix_attempts", "must not be negative")
+	}
+	switch c.UI.Color {
+	case "", "auto", "always", "never":
+	default:
+		invalid("ui.color", "unknown setting %q; expected auto, always or never", c.UI.Color)
+	}
+
+	names := sortedKeys(c.Commands)
+	for _, name := range names {
+		errs = append(errs, checkCommand("commands."+name, c.Commands[name], nil)...)
+	}
+	steps := map[string]bool{}
+	for _, step := range c.Validation.Steps {
+		if step.Name != "" {
+			steps[step.Name] = true
+		}
+	}
+	seen := map[string]bool{}
+	for i, step := range c.Validation.Steps {
+		key := fmt.Sprintf("validation.steps[%d]", i)
+		if step.Name != "" && seen[step.Name] {
+			invalid(key+".name", "another step is already named %q", step.Name)
+		}
+		seen[step.Name] = true
+		errs = append(errs, checkCommand(key, step, steps)...)
+	}
+	return errs
+}
+
+// checkCommand checks a command; steps, when not nil, are the names its needs may use.
+func checkCommand(key string, c Command, steps map[string]bool) Errors {
+	var errs Errors
+	if strings.TrimSpace(c.Run) == "" {
+		errs.add(nil, key+".run", "missing the command line to run")
+	}
+	for name := range c.Env {
+		if name == "" || strings.Contains(name, "=") {
+			errs.add(nil, key+".env", "invalid variable name %q", name)
+		}
+	}
+	for i, need := range c.Needs {
+		if steps == nil {
+			errs.add(nil, key+".needs", "only validation steps can need other steps")
+			break
+		}
+		if !steps[need] {
+			errs.add(nil, fmt.Sprintf("%s.needs[%d]", key, i), "no validation step is named %q", need)
+		}
+	}
+	return errs
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]Command) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Command returns the named project command from the configuration file, or, when
+// the file has no commands, from the Commands section of the project description.
+func (c *Config) Command(name string) (runner.Command, error) {
+	if c.Commands == nil {
+		return fs.GetProjectCommand(c.Paths.ProjectDescription, name)
+	}
+	command, ok := c.Commands[name]
+	if !ok {
+		return runner.Command{}, fmt.Errorf("command '%s' not found in %s", name, filepath.Base(c.Path))
+	}
+	return command.Runner(), nil
+}
+
+// ValidationCommands returns the validation steps from the configuration file, or,
+// when the file has none, from the Automated Validation section of the project
+// description.
+func (c *Config) ValidationCommands() ([]runner.Command, error) {
+	if c.Validation.Steps == nil {
+		return fs.GetValidationCommands(c.Paths.ProjectDescription)
+	}
+	var commands []runner.Command
+	for _, step := range c.Validation.Steps {
+		commands = append(commands, step.Runner())
+	}
+	return commands, nil
+}
+
+// Env returns the settings the configuration sets as the PDT_* environment variables
+// that hold the same settings, which take precedence over the files.
+func (c *Config) Env() map[string]string {
+	env := map[string]string{}
+	keys := []string{}
+	for _, s := range Settings {
+		keys = append(keys, s.Key)
+	}
+	for command := range c.Timeouts {
+		keys = append(keys, "timeouts."+command)
+	}
+	for _, key := range keys {
+		s, _ := Lookup(key)
+		if value, ok := c.Value(key); ok && s.Env != "" {
+			env[s.Env] = value
+		}
+	}
+	return env
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/productdevtool/pdt-cli/pkg/runner"
+)
+
+const example = `
+ai:
+  provider: openai
+  model: gpt-4o-mini
+  routes:
+    commit: command
+  fallback: [gemini-cli]
+  max_retries: 0
+timeout: 30m
+timeouts:
+  code: 1h
+commands:
+  build: go build ./...
+  deploy:
+    run: ./scripts/deploy.sh production
+    timeout: 15m
+validation:
+  jobs: 2
+  steps:
+    - run: go vet ./...
+      name: vet
+    - run: go test ./...
+      needs: [vet]
+      env:
+        CGO_ENABLED: "0"
+      results: report.xml
+paths:
+  handbook: handbook
+write:
+  deny: [migrations/**]
+`
+
+func TestParse(t *testing.T) {
+	// Test case 1: A complete file is read into the typed configuration
+	c, err := Parse([]byte(example))
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if c.AI.Provider != "openai" || c.Timeouts["code"] != time.Hour || c.Validation.Jobs != 2 {
+		t.Errorf("Expected the AI, timeout and validation settings, got %+v", c)
+	}
+	if c.AI.MaxRetries == nil || *c.AI.MaxRetries != 0 {
+		t.Errorf("Expected max_retries 0 to be kept, got %v", c.AI.MaxRetries)
+	}
+	if c.Paths.Handbook != "handbook" || c.Paths.ProjectDescription != "docs/project-description.md" {
+		t.Errorf("Expected the handbook path and default paths, got %+v", c.Paths)
+	}
+
+	// Test case 2: Commands may be a bare command line or a mapping
+	build, err := c.Command("build")
+	if err != nil || build.Run != "go build ./..." {
+		t.Errorf("Expected the build command, got %+v (%v)", build, err)
+	}
+	deploy, _ := c.Command("deploy")
+	if deploy.Timeout != 15*time.Minute {
+		t.Errorf("Expected the deploy timeout, got %v", deploy.Timeout)
+	}
+	steps, err := c.ValidationCommands()
+	if err != nil {
+		t.Fatalf("ValidationCommands returned an error: %v", err)
+	}
+	expected := []runner.Command{
+		{Run: "go vet ./...", Name: "vet"},
+		{Run: "go test ./...", Env: []string{"CGO_ENABLED=0"}, Needs: []string{"vet"}, Results: "report.xml"},
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, steps)
+	}
+
+	// Test case 3: Settings are exposed as the environment variables that override them
+	env := c.Env()
+	for name, value := range map[string]string{
+		"PDT_AI_PROVIDER":     "openai",
+		"PDT_AI_ROUTES":       "commit=command",
+		"PDT_AI_MAX_RETRIES":  "0",
+		"PDT_TIMEOUT":         "30m0s",
+		"PDT_TIMEOUTS_CODE":   "1h0m0s",
+		"PDT_VALIDATION_JOBS": "2",
+		"PDT_WRITE_DENY":      "migrations/**",
+	} {
+		if env[name] != value {
+			t.Errorf("Expected %s=%s, got %q", name, value, env[name])
+		}
+	}
+	if _, ok := env["PDT_CACHE_ENABLED"]; ok {
+		t.Errorf("Expected settings the file doesn't set to be left out, got %v", env)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		yaml     string
+		expected string
+	}{
+		{"ai:\n  provdier: openai\n", "line 2: ai.provdier: unknown key; expected one of provider,"},
+		{"timeout: soon\n", `line 1: timeout: invalid duration "soon"`},
+		{"timeout: 300\n", "line 1: timeout: expected a duration"},
+		{"validation:\n  jobs: many\n", "line 2: validation.jobs: expected a whole number"},
+		{"validation:\n  jobs: -2\n", "line 2: validation.jobs: must not be negative"},
+		{"write:\n  deny: migrations\n", "line 2: write.deny: expected a list"},
+		{"ai:\n  api_key: sk-123\n", "line 2: ai.api_key: API keys don't belong"},
+		{"ai:\n  provider: gpt\n", `line 2: ai.provider: unknown provider "gpt"`},
+		{"commands:\n  build:\n    cwd: web\n", "line 2: commands.build.run: missing the command line"},
+		{"validation:\n  steps:\n    - go test\n    - run: go vet\n      needs: [lint]\n", `line 5: validation.steps[1].needs[0]: no validation step is named "lint"`},
+	}
+
+	// Test case: Each problem names the key, and the line where it is known
+	for _, test := range tests {
+		_, err := Parse([]byte(test.yaml))
+		var errs Errors
+		if !errors.As(err, &errs) || !strings.HasPrefix(errs[0].Error(), test.expected) {
+			t.Errorf("Expected an error starting with %q for %q, got %v", test.expected, test.yaml, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PDT_USER_CONFIG", filepath.Join(dir, "user.yaml"))
+	os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	os.WriteFile(filepath.Join(dir, "docs", "project-description.md"), []byte("## Commands\n- build: `make`\n\n## Automated Validation\n- `make test`\n"), 0644)
+
+	// Test case 1: Without a file, the defaults and the project description are used
+	c, err := Load(dir)
+	if err != nil || c.Path != "" {
+		t.Fatalf("Expected the defaults, got %+v (%v)", c, err)
+	}
+	c.Paths.ProjectDescription = filepath.Join(dir, c.Paths.ProjectDescription)
+	if build, err := c.Command("build"); err != nil || build.Run != "make" {
+		t.Errorf("Expected the build command from the project description, got %+v (%v)", build, err)
+	}
+
+	// Test case 2: .pdt/config.yaml is read, and sections it leaves out fall back to the project description
+	os.MkdirAll(filepath.Join(dir, ".pdt"), 0755)
+	os.WriteFile(filepath.Join(dir, ".pdt", "config.yaml"), []byte("commands:\n  build: go build ./...\n"), 0644)
+	c, err = Load(dir)
+	if err != nil || c.Path != filepath.Join(dir, ".pdt", "config.yaml") {
+		t.Fatalf("Expected .pdt/config.yaml to be loaded, got %+v (%v)", c, err)
+	}
+	c.Paths.ProjectDescription = filepath.Join(dir, c.Paths.ProjectDescription)
+	if build, _ := c.Command("build"); build.Run != "go build ./..." {
+		t.Errorf("Expected the build command from the file, got %+v", build)
+	}
+	if steps, err := c.ValidationCommands(); err != nil || len(steps) != 1 || steps[0].Run != "make test" {
+		t.Errorf("Expected the validation commands from the project description, got %+v (%v)", steps, err)
+	}
+
+	// Test case 3: pdt.yaml wins, and its errors name the file
+	os.WriteFile(filepath.Join(dir, "pdt.yaml"), []byte("cache:\n  ttl: forever\n"), 0644)
+	if _, err := Load(dir); err == nil || !strings.HasPrefix(err.Error(), "pdt.yaml:2: cache.ttl:") {
+		t.Errorf("Expected an error pointing at pdt.yaml:2, got %v", err)
+	}
+}
+
+func TestFindRoot(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	os.MkdirAll(filepath.Join(dir, "services", "api", "internal"), 0755)
+	os.WriteFile(filepath.Join(dir, "services", "api", "pdt.yaml"), []byte("ai:\n  provider: openai\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "web", "src"), 0755)
+
+	tests := []struct {
+		dir      string
+		expected string
+	}{
+		// Test case 1: The nearest pdt.yaml marks the root, so services in a monorepo are projects of their own
+		{filepath.Join(dir, "services", "api", "internal"), filepath.Join(dir, "services", "api")},
+		// Test case 2: Otherwise the repository root is used
+		{filepath.Join(dir, "web", "src"), dir},
+		{dir, dir},
+	}
+	for _, test := range tests {
+		root, err := FindRoot(test.dir)
+		if err != nil || root != test.expected {
+			t.Errorf("Expected %s for %s, got %s (%v)", test.expected, test.dir, root, err)
+		}
+	}
+
+	// Test case 3: Without any marker the directory itself is the root
+	lone := t.TempDir()
+	if root, _ := FindRoot(lone); root != lone {
+		t.Errorf("Expected %s, got %s", lone, root)
+	}
+}
+
+func TestRoot(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	service := filepath.Join(dir, "services", "api")
+	os.MkdirAll(service, 0755)
+	os.WriteFile(filepath.Join(dir, "README.md"), nil, 0644)
+
+	tests := []struct {
+		explicit string
+		search   bool
+		expected string
+	}{
+		// Test case 1: Commands look for the project root above the directory
+		{"", true, dir},
+		// Test case 2: pdt init sets up the directory it runs in, not the repository root
+		{"", false, service},
+		// Test case 3: The --root flag wins either way
+		{dir, false, dir},
+		{service, true, service},
+	}
+	for _, test := range tests {
+		root, err := Root(service, test.explicit, test.search)
+		if err != nil || root != test.expected {
+			t.Errorf("Expected %s for root %q and search %v, got %s (%v)", test.expected, test.explicit, test.search, root, err)
+		}
+	}
+
+	// Test case 4: A --root that isn't a directory is refused
+	if _, err := Root(service, filepath.Join(dir, "README.md"), true); err == nil {
+		t.Errorf("Expected an error for a file given as the root")
+	}
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of a setting, from the lowest precedence to the highest.
+const (
+	SourceDefault = "default"
+	SourceUser    = "user"
+	SourceProject = "project"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting is a configuration key and the other ways of setting it.
+type Setting struct {
+	Key string
+	// Env is the environment variable that overrides the key, and Flag the pdt flag
+	// that overrides both.
+	Env  string
+	Flag string
+	// Default is the value used when nothing sets the key. Fallback describes what pdt
+	// does instead when there is no single default value.
+	Default  string
+	Fallback string
+}
+
+// Settings are the keys pdt lists and explains. Per-command timeouts are looked up as
+// timeouts.<command>.
+var Settings = []Setting{
+	{Key: "ai.provider", Env: "PDT_AI_PROVIDER", Flag: "provider", Default: "gemini-cli"},
+	{Key: "ai.model", Env: "PDT_AI_MODEL", Flag: "model", Fallback: "the provider's default"},
+	{Key: "ai.command", Env: "PDT_AI_COMMAND"},
+	{Key: "ai.base_url", Env: "PDT_AI_BASE_URL", Fallback: "https://api.openai.com/v1 for openai"},
+	{Key: "ai.input", Env: "PDT_AI_INPUT", Default: "stdin"},
+	{Key: "ai.prompt_file_flag", Env: "PDT_AI_PROMPT_FILE_FLAG"},
+	{Key: "ai.max_prompt_bytes", Env: "PDT_AI_MAX_PROMPT_BYTES"},
+	{Key: "ai.max_retries", Env: "PDT_AI_MAX_RETRIES", Default: "3"},
+	{Key: "ai.retry_budget", Env: "PDT_AI_RETRY_BUDGET", Default: "5m0s"},
+	{Key: "ai.structured", Env: "PDT_AI_STRUCTURED", Default: "false"},
+	{Key: "ai.context_budget", Env: "PDT_AI_CONTEXT_BUDGET", Fallback: "derived from the model's context window"},
+	{Key: "ai.trim_prompts", Env: "PDT_AI_TRIM_PROMPTS", Default: "true"},
+	{Key: "ai.routes", Env: "PDT_AI_ROUTES"},
+	{Key: "ai.fallback", Env: "PDT_AI_FALLBACK"},
+	{Key: "ai.mode", Env: "PDT_AI_MODE", Default: "live"},
+	{Key: "ai.cassette_dir", Env: "PDT_AI_CASSETTE_DIR", Default: ".pdt/cassettes"},
+	{Key: "cache.enabled", Env: "PDT_CACHE_ENABLED", Flag: "no-cache", Default: "true"},
+	{Key: "cache.dir", Env: "PDT_CACHE_DIR", Default: ".pdt/cache"},
+	{Key: "cache.ttl", Env: "PDT_CACHE_TTL", Default: "24h0m0s"},
+	{Key: "cache.max_size", Env: "PDT_CACHE_MAX_SIZE", Default: "100MB"},
+	{Key: "usage.ledger", Env: "PDT_USAGE_LEDGER", Default: ".pdt/usage.jsonl"},
+	{Key: "usage.monthly_budget", Env: "PDT_USAGE_MONTHLY_BUDGET"},
+	{Key: "usage.prices", Env: "PDT_USAGE_PRICES"},
+	{Key: "timeout", Env: "PDT_TIMEOUT", Flag: "timeout"},
+	{Key: "validation.jobs", Env: "PDT_VALIDATION_JOBS", Fallback: "the number of CPUs, up to 4, or 1 for a project description list without needs"},
+	{Key: "validation.fix_attempts", Env: "PDT_VALIDATION_FIX_ATTEMPTS", Default: "3"},
+	{Key: "validation.report", Env: "PDT_VALIDATION_REPORT", Default: ".pdt/validation.json"},
+	{Key: "paths.project_description"},
+	{Key: "paths.todo"},
+	{Key: "paths.todos"},
+	{Key: "paths.specs"},
+	{Key: "paths.templates"},
+	{Key: "paths.handbook"},
+	{Key: "paths.content"},
+	{Key: "write.allow", Env: "PDT_WRITE_ALLOW", Fallback: "everything in the project"},
+	{Key: "write.deny", Env: "PDT_WRITE_DENY"},
+	{Key: "write.journal_dir", Env: "PDT_JOURNAL_DIR", Default: ".pdt/journal"},
+	{Key: "ui.editor", Env: "PDT_EDITOR", Fallback: "$VISUAL or $EDITOR"},
+	{Key: "ui.color", Env: "PDT_COLOR", Default: "auto"},
+}
+
+// Lookup returns the setting for key. Keys that aren't in Settings, such as
+// commands.build, are found when the configuration has them.
+func Lookup(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			if s.Default == "" {
+				s.Default, _ = Default().Value(key)
+			}
+			return s, true
+		}
+	}
+	if _, ok := typeAt(key); !ok {
+		return Setting{}, false
+	}
+	s := Setting{Key: key}
+	if strings.HasPrefix(key, "timeouts.") {
+		s.Env = "PDT_TIMEOUTS_" + strings.ToUpper(strings.TrimPrefix(key, "timeouts."))
+		s.Flag = "timeout"
+	}
+	return s, true
+}
+
+// UserPath returns the user's own configuration file: $PDT_USER_CONFIG, or
+// pdt/config.yaml in $XDG_CONFIG_HOME, which defaults to ~/.config.
+func UserPath() (string, error) {
+	if path := os.Getenv("PDT_USER_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "pdt", "config.yaml"), nil
+}
+
+// Layer is a configuration file that was read.
+type Layer struct {
+	Source string
+	Path   string
+	// Config holds only what the file sets.
+	Config *Config
+}
+
+// Origin is the value one layer gives a setting.
+type Origin struct {
+	Source string
+	// Where is the file, environment variable or flag the value comes from.
+	Where string
+	Value string
+	// Set is false when the layer doesn't set the key; the default layer's Value then
+	// holds the setting's Fallback.
+	Set bool
+}
+
+// Explain returns the value each layer gives key, from the highest precedence to the
+// lowest; the first that is set is the effective value. getenv reads the environment,
+// and flags holds the values of the pdt flags that were given, by name.
+func (c *Config) Explain(key string, getenv func(string) string, flags map[string]string) ([]Origin, error) {
+	s, ok := Lookup(key)
+	if !ok {
+		return nil, unknownKey(key)
+	}
+	var origins []Origin
+	if s.Flag != "" {
+		value, set := flags[s.Flag]
+		origins = append(origins, Origin{Source: SourceFlag, Where: "--" + s.Flag, Value: value, Set: set})
+	}
+	if s.Env != "" {
+		value := getenv(s.Env)
+		origins = append(origins, Origin{Source: SourceEnv, Where: s.Env, Value: value, Set: value != ""})
+	}
+	for i := len(c.Layers) - 1; i >= 0; i-- {
+		layer := c.Layers[i]
+		value, set := layer.Config.Value(key)
+		origins = append(origins, Origin{Source: layer.Source, Where: layer.Path, Value: value, Set: set})
+	}
+	if s.Default != "" {
+		origins = append(origins, Origin{Source: SourceDefault, Value: s.Default, Set: true})
+	} else {
+		origins = append(origins, Origin{Source: SourceDefault, Value: s.Fallback})
+	}
+	return origins, nil
+}
+
+// Resolve returns the effective value of key, as Explain finds it. When nothing sets
+// the key, it returns the default layer, which isn't set.
+func (c *Config) Resolve(key string, getenv func(string) string, flags map[string]string) (Origin, error) {
+	origins, err := c.Explain(key, getenv, flags)
+	if err != nil {
+		return Origin{}, err
+	}
+	for _, o := range origins {
+		if o.Set {
+			return o, nil
+		}
+	}
+	return origins[len(origins)-1], nil
+}
+
+// Value returns the value the configuration gives key, written the way the environment
+// variable for it would be, and whether the configuration sets it.
+func (c *Config) Value(key string) (string, bool) {
+	v := reflect.ValueOf(*c)
+	for _, name := range strings.Split(key, ".") {
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			i := fieldIndex(v.Type(), name)
+			if i < 0 {
+				return "", false
+			}
+			v = v.Field(i)
+		case reflect.Map:
+			v = v.MapIndex(reflect.ValueOf(name))
+			if !v.IsValid() {
+				return "", false
+			}
+		default:
+			return "", false
+		}
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false
+		}
+		return text(v.Elem()), true
+	}
+	if v.IsZero() {
+		return "", false
+	}
+	return text(v), true
+}
+
+// text formats a value: lists and mappings of plain values are comma-separated, and
+// anything else is written as YAML.
+func text(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.Kind() == reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case v.Kind() == reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case v.Kind() == reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, v.Index(i).String())
+		}
+		return strings.Join(items, ",")
+	case v.Kind() == reflect.Map && v.Type().Elem() != commandType:
+		var items []string
+		for _, key := range v.MapKeys() {
+			items = append(items, key.String()+"="+text(v.MapIndex(key)))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	data, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// fieldIndex returns the index of the field of t named name in YAML, or -1.
+func fieldIndex(t reflect.Type, name string) int {
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == name && tag != "-" {
+			return i
+		}
+	}
+	return -1
+}
+
+// typeAt returns the type of the value at key.
+func typeAt(key string) (reflect.Type, bool) {
+	t := reflect.TypeOf(Config{})
+	for _, name := range strings.Split(key, ".") {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			i := fieldIndex(t, name)
+			if i < 0 {
+				return nil, false
+			}
+			t = t.Field(i).Type
+		case reflect.Map:
+			if name == "" {
+				return nil, false
+			}
+			t = t.Elem()
+		default:
+			return nil, false
+		}
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t, true
+}
+
+func unknownKey(key string) error {
+	if key == "ai.api_key" {
+		return &Error{Key: key, Message: apiKeyMessage}
+	}
+	return &Error{Key: key, Message: "unknown key; run `pdt config list --all` to see the settings"}
+}
+
+// Set sets key to value in the configuration file at path, creating the file if needed
+// and keeping its comments. A list is given as comma-separated items. The file is only
+// written when the result is a valid configuration.
+func Set(path string, key string, value string) error {
+	t, ok := typeAt(key)
+	if !ok {
+		return unknownKey(key)
+	}
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	switch {
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		node = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+			}
+		}
+	case t == durationType || t.Kind() == reflect.String || t == commandType:
+		node.Tag = "!!str"
+	case t.Kind() == reflect.Struct || t.Kind() == reflect.Map || t.Kind() == reflect.Slice:
+		return &Error{Key: key, Message: fmt.Sprintf("holds several settings; set them one at a time, or edit %s", filepath.Base(path))}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if len(document.Content) == 0 {
+		document = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if err := setNode(document.Content[0], strings.Split(key, "."), node); err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return err
+	}
+	if _, err := parse(out.Bytes()); err != nil {
+		if errs, ok := err.(Errors); ok {
+			for _, e := range errs {
+				e.File = filepath.Base(path)
+			}
+		}
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// setNode sets the value at the path of names below mapping to value, adding the
+// mappings on the way that don't exist yet.
+func setNode(mapping *yaml.Node, names []string, value *yaml.Node) error {
+	if mapping.Kind == yaml.ScalarNode && mapping.Tag != "!!null" && len(names) > 0 {
+		// A command given as a bare command line becomes a mapping with run.
+		*mapping = yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "run"},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: mapping.Value, LineComment: mapping.LineComment},
+		}}
+	}
+	if mapping.Kind == yaml.ScalarNode && mapping.Tag == "!!null" {
+		*mapping = yaml.Node{Kind: yaml.MappingNode}
+	}
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping of keys to values", mapping.Line)
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != names[0] {
+			continue
+		}
+		if len(names) > 1 {
+			return setNode(mapping.Content[i+1], names[1:], value)
+		}
+		old := mapping.Content[i+1]
+		value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		mapping.Content[i+1] = value
+		return nil
+	}
+	child := value
+	if len(names) > 1 {
+		child = &yaml.Node{Kind: yaml.MappingNode}
+		if err := setNode(child, names[1:], value); err != nil {
+			return err
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: names[0]}, child)
+	return nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLayers(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.yaml")
+	t.Setenv("PDT_USER_CONFIG", user)
+	os.WriteFile(user, []byte("ai:\n  provider: openai\n  model: gpt-4o-mini\nui:\n  editor: vim\ntimeouts:\n  code: 1h\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "pdt.yaml"), []byte("ai:\n  model: gpt-4o\ntimeouts:\n  commit: 5m\n"), 0644)
+
+	// Test case 1: The project overrides the user's settings key by key
+	c, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	if c.AI.Provider != "openai" || c.AI.Model != "gpt-4o" || c.UI.Editor != "vim" {
+		t.Errorf("Expected the user's provider and editor with the project's model, got %+v %+v", c.AI, c.UI)
+	}
+	if len(c.Timeouts) != 2 || len(c.Layers) != 2 || c.Layers[0].Source != SourceUser {
+		t.Errorf("Expected the timeouts of both files and a user and a project layer, got %v and %+v", c.Timeouts, c.Layers)
+	}
+
+	// Test case 2: Explain lists every layer, highest first, with flags and the environment on top
+	env := map[string]string{"PDT_AI_MODEL": "gpt-4.1"}
+	origins, err := c.Explain("ai.model", func(name string) string { return env[name] }, map[string]string{})
+	if err != nil {
+		t.Fatalf("Explain returned an error: %v", err)
+	}
+	var sources, values []string
+	for _, o := range origins {
+		sources = append(sources, o.Source)
+		values = append(values, o.Value)
+	}
+	if !reflect.DeepEqual(sources, []string{SourceFlag, SourceEnv, SourceProject, SourceUser, SourceDefault}) {
+		t.Errorf("Expected flag, env, project, user and default, got %v", sources)
+	}
+	if !reflect.DeepEqual(values, []string{"", "gpt-4.1", "gpt-4o", "gpt-4o-mini", "the provider's default"}) || origins[4].Set {
+		t.Errorf("Expected each layer's model and the provider's default as the fallback, got %v", values)
+	}
+
+	// Test case 3: Resolve picks the highest layer that sets the key
+	tests := []struct {
+		key    string
+		flags  map[string]string
+		source string
+		value  string
+	}{
+		{"ai.model", nil, SourceEnv, "gpt-4.1"},
+		{"ai.model", map[string]string{"model": "o3"}, SourceFlag, "o3"},
+		{"ai.provider", nil, SourceUser, "openai"},
+		{"timeouts.commit", nil, SourceProject, "5m0s"},
+		{"cache.ttl", nil, SourceDefault, "24h0m0s"},
+		{"paths.todo", nil, SourceDefault, "docs/todo.md"},
+	}
+	for _, test := range tests {
+		o, err := c.Resolve(test.key, func(name string) string { return env[name] }, test.flags)
+		if err != nil || o.Source != test.source || o.Value != test.value {
+			t.Errorf("Expected %s=%s from %s, got %+v (%v)", test.key, test.value, test.source, o, err)
+		}
+	}
+
+	// Test case 4: Unknown keys are refused
+	if _, err := c.Explain("ai.provdier", os.Getenv, nil); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("Expected an unknown key error, got %v", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pdt.yaml")
+	os.WriteFile(path, []byte("# Shared settings\nai:\n  provider: openai # the team's account\ncommands:\n  build: make\n"), 0644)
+
+	// Test case 1: Values are set in place, keeping comments, and new keys are added
+	for _, set := range [][2]string{{"ai.provider", "command"}, {"write.deny", "migrations/**, vendor/**"}, {"commands.build.cwd", "web"}, {"validation.jobs", "2"}} {
+		if err := Set(path, set[0], set[1]); err != nil {
+			t.Fatalf("Set %s returned an error: %v", set[0], err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	for _, expected := range []string{"# Shared settings", "provider: command # the team's account", "deny: [migrations/**, vendor/**]"} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected the file to contain %q, got:\n%s", expected, data)
+		}
+	}
+	c, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Expected a valid file, got %v", err)
+	}
+	if c.Commands["build"].Run != "make" || c.Commands["build"].Cwd != "web" || c.Validation.Jobs != 2 {
+		t.Errorf("Expected the build command in web and 2 jobs, got %+v %+v", c.Commands, c.Validation)
+	}
+
+	// Test case 2: Invalid values, unknown keys and whole sections are refused, leaving the file alone
+	for _, set := range [][2]string{{"validation.jobs", "many"}, {"ai.provdier", "openai"}, {"ai.routes", "commit=openai"}, {"ai.api_key", "sk-123"}} {
+		if err := Set(path, set[0], set[1]); err == nil {
+			t.Errorf("Expected an error setting %s to %s", set[0], set[1])
+		}
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(data) {
+		t.Errorf("Expected the file to be unchanged, got:\n%s", after)
+	}
+
+	// Test case 3: A missing file is created
+	path = filepath.Join(t.TempDir(), "pdt", "config.yaml")
+	if err := Set(path, "ai.routes.commit", "openai:gpt-4o-mini"); err != nil {
+		t.Fatalf("Set returned an error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "ai:\n  routes:\n    commit: openai:gpt-4o-mini\n" {
+		t.Errorf("Expected a new file with the route, got %q", data)
+	}
+}
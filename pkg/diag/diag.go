@@ -0,0 +1,424 @@
+package diag
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Failure is one problem reported in a validation command's output: a failed test,
+// a compiler error or a lint error.
+type Failure struct {
+	// File is the file the failure points at, as the tool wrote it; it may be empty.
+	File   string
+	Line   int
+	Column int
+	// Test is the name of the failed test, if the failure is a test.
+	Test    string
+	Message string
+}
+
+// Location returns file:line:col, leaving out the parts that are unknown.
+func (f Failure) Location() string {
+	switch {
+	case f.File == "":
+		return ""
+	case f.Line == 0:
+		return f.File
+	case f.Column == 0:
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	}
+}
+
+func (f Failure) String() string {
+	location := f.Location()
+	switch {
+	case f.Test != "" && location != "":
+		return fmt.Sprintf("%s (%s): %s", f.Test, location, f.Message)
+	case f.Test != "":
+		return fmt.Sprintf("%s: %s", f.Test, f.Message)
+	case location != "":
+		return fmt.Sprintf("%s: %s", location, f.Message)
+	default:
+		return f.Message
+	}
+}
+
+// Files returns the files the failures point at, in order and without duplicates.
+func Files(failures []Failure) []string {
+	var files []string
+	seen := map[string]bool{}
+	for _, f := range failures {
+		if f.File != "" && !seen[f.File] {
+			seen[f.File] = true
+			files = append(files, f.File)
+		}
+	}
+	return files
+}
+
+// Parse finds the failures in a command's output or results file. It understands
+// eslint's JSON format, JUnit XML, go test output (plain or -json), TAP, pytest's
+// summary and file:line:col diagnostics from compilers, type checkers and linters.
+// It returns nil when it finds nothing it recognises.
+func Parse(output string) []Failure {
+	trimmed := strings.TrimSpace(output)
+	if failures, ok := parseESLint(trimmed); ok {
+		return failures
+	}
+	if failures, ok := parseJUnit(trimmed); ok {
+		return failures
+	}
+
+	text := goTestJSONText(output)
+	var failures []Failure
+	failures = append(failures, parseGoTest(text)...)
+	failures = append(failures, parseTAP(text)...)
+	failures = append(failures, parsePytest(text)...)
+	failures = append(failures, parseDiagnostics(text)...)
+	return dedupe(failures)
+}
+
+// dedupe drops failures that are reported more than once.
+func dedupe(failures []Failure) []Failure {
+	var unique []Failure
+	seen := map[Failure]bool{}
+	for _, f := range failures {
+		if !seen[f] {
+			seen[f] = true
+			unique = append(unique, f)
+		}
+	}
+	return unique
+}
+
+// eslintFile is one file in eslint's JSON output.
+type eslintFile struct {
+	FilePath *string `json:"filePath"`
+	Messages []struct {
+		RuleID   string `json:"ruleId"`
+		Severity int    `json:"severity"`
+		Message  string `json:"message"`
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+	} `json:"messages"`
+}
+
+// parseESLint parses `eslint -f json` output. Warnings are only reported when there
+// are no errors, since they only fail a run with --max-warnings.
+func parseESLint(output string) ([]Failure, bool) {
+	if !strings.HasPrefix(output, "[") {
+		return nil, false
+	}
+	var files []eslintFile
+	if err := json.Unmarshal([]byte(output), &files); err != nil || len(files) == 0 || files[0].FilePath == nil {
+		return nil, false
+	}
+	var errors, warnings []Failure
+	for _, file := range files {
+		for _, m := range file.Messages {
+			message := m.Message
+			if m.RuleID != "" {
+				message += " (" + m.RuleID + ")"
+			}
+			f := Failure{File: *file.FilePath, Line: m.Line, Column: m.Column, Message: message}
+			if m.Severity >= 2 {
+				errors = append(errors, f)
+			} else {
+				warnings = append(warnings, f)
+			}
+		}
+	}
+	if len(errors) > 0 {
+		return errors, true
+	}
+	return warnings, true
+}
+
+// junitCase is a <testcase> element of a JUnit XML report.
+type junitCase struct {
+	Name      string         `xml:"name,attr"`
+	Classname string         `xml:"classname,attr"`
+	File      string         `xml:"file,attr"`
+	Line      int            `xml:"line,attr"`
+	Failures  []junitProblem `xml:"failure"`
+	Errors    []junitProblem `xml:"error"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+var locationPattern = regexp.MustCompile(`([^\s:()"']+\.[A-Za-z0-9]+):(\d+)`)
+
+// parseJUnit parses the test cases that failed or errored in a JUnit XML report,
+// which may be preceded by other output.
+func parseJUnit(output string) ([]Failure, bool) {
+	start := strings.Index(output, "<testsuite")
+	if start < 0 {
+		return nil, false
+	}
+	if i := strings.Index(output, "<?xml"); i >= 0 && i < start {
+		start = i
+	}
+
+	var failures []Failure
+	decoder := xml.NewDecoder(strings.NewReader(output[start:]))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return failures, len(failures) > 0
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Local != "testcase" {
+			continue
+		}
+		var c junitCase
+		if err := decoder.DecodeElement(&c, &element); err != nil {
+			return failures, len(failures) > 0
+		}
+		name := c.Name
+		if c.Classname != "" {
+			name = c.Classname + "." + c.Name
+		}
+		for _, problem := range append(c.Failures, c.Errors...) {
+			f := Failure{File: c.File, Line: c.Line, Test: name, Message: strings.TrimSpace(problem.Message)}
+			text := strings.TrimSpace(problem.Text)
+			if f.Message == "" {
+				f.Message = firstLine(text)
+			}
+			if f.File == "" {
+				if m := locationPattern.FindStringSubmatch(text); m != nil {
+					f.File = m[1]
+					f.Line, _ = strconv.Atoi(m[2])
+				}
+			}
+			failures = append(failures, f)
+		}
+	}
+	return failures, true
+}
+
+// goTestEvent is a line of `go test -json` output.
+type goTestEvent struct {
+	Action string
+	Output string
+}
+
+// goTestJSONText turns `go test -json` output back into the text go test would have
+// printed. Output that isn't go test JSON is returned unchanged.
+func goTestJSONText(output string) string {
+	var text strings.Builder
+	events := 0
+	for _, line := range strings.Split(output, "\n") {
+		var event goTestEvent
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "{") || json.Unmarshal([]byte(trimmed), &event) != nil || event.Action == "" {
+			text.WriteString(line + "\n")
+			continue
+		}
+		events++
+		text.WriteString(event.Output)
+	}
+	if events == 0 {
+		return output
+	}
+	return text.String()
+}
+
+var (
+	goRunPattern      = regexp.MustCompile(`^=== (?:RUN|CONT|NAME)\s+(\S+)`)
+	goResultPattern   = regexp.MustCompile(`^\s*--- (FAIL|PASS|SKIP): (\S+)`)
+	goLocationPattern = regexp.MustCompile(`^\s+([^\s:]+\.go):(\d+): (.*)$`)
+)
+
+// parseGoTest parses the tests go test reports as failed and the file:line messages
+// they logged, which come before the --- FAIL line with -v and after it without. A
+// test that failed only because a subtest failed isn't reported itself.
+func parseGoTest(text string) []Failure {
+	logged := map[string][]Failure{}
+	var failed []string
+	current := ""
+	for _, line := range lines(text) {
+		if m := goRunPattern.FindStringSubmatch(line); m != nil {
+			current = m[1]
+			continue
+		}
+		if m := goResultPattern.FindStringSubmatch(line); m != nil {
+			current = ""
+			if m[1] == "FAIL" {
+				current = m[2]
+				failed = append(failed, m[2])
+			}
+			continue
+		}
+		if m := goLocationPattern.FindStringSubmatch(line); m != nil && current != "" {
+			number, _ := strconv.Atoi(m[2])
+			logged[current] = append(logged[current], Failure{File: m[1], Line: number, Test: current, Message: strings.TrimSpace(m[3])})
+		} else if line == "PASS" || line == "FAIL" || strings.HasPrefix(line, "FAIL\t") || strings.HasPrefix(line, "ok  \t") {
+			current = ""
+		}
+	}
+
+	var failures []Failure
+	for _, name := range failed {
+		if len(logged[name]) > 0 {
+			failures = append(failures, logged[name]...)
+			continue
+		}
+		parent := false
+		for _, other := range failed {
+			parent = parent || strings.HasPrefix(other, name+"/")
+		}
+		if !parent {
+			failures = append(failures, Failure{Test: name, Message: "test failed"})
+		}
+	}
+	return failures
+}
+
+var (
+	tapNotOkPattern = regexp.MustCompile(`^\s*not ok\s+\d*\s*(?:-\s*)?(.*)$`)
+	tapDirective    = regexp.MustCompile(`(?i)\s#\s*(TODO|SKIP)\b`)
+	tapFieldPattern = regexp.MustCompile(`^\s+(message|at|file|line|column):\s*(.*)$`)
+)
+
+// parseTAP parses the failed tests in TAP output, with the message and location from
+// their YAML diagnostics block if there is one. TODO and SKIP tests are ignored.
+func parseTAP(text string) []Failure {
+	var failures []Failure
+	all := lines(text)
+	for i := 0; i < len(all); i++ {
+		m := tapNotOkPattern.FindStringSubmatch(all[i])
+		if m == nil || tapDirective.MatchString(all[i]) {
+			continue
+		}
+		f := Failure{Test: strings.TrimSpace(m[1]), Message: "test failed"}
+		if i+1 < len(all) && strings.TrimSpace(all[i+1]) == "---" {
+			for i += 2; i < len(all) && strings.TrimSpace(all[i]) != "..."; i++ {
+				field := tapFieldPattern.FindStringSubmatch(all[i])
+				if field == nil {
+					continue
+				}
+				value := strings.Trim(strings.TrimSpace(field[2]), `"'`)
+				switch field[1] {
+				case "message":
+					if value != "" && value != "|" && value != ">" {
+						f.Message = value
+					}
+				case "at":
+					if location := locationPattern.FindStringSubmatch(value); location != nil {
+						f.File = location[1]
+						f.Line, _ = strconv.Atoi(location[2])
+					}
+				case "file":
+					f.File = value
+				case "line":
+					f.Line, _ = strconv.Atoi(value)
+				case "column":
+					f.Column, _ = strconv.Atoi(value)
+				}
+			}
+		}
+		failures = append(failures, f)
+	}
+	return failures
+}
+
+var pytestPattern = regexp.MustCompile(`^(?:FAILED|ERROR) ([^\s:]+)::(\S+)(?: - (.*))?$`)
+
+// parsePytest parses the short test summary pytest prints at the end of a run.
+func parsePytest(text string) []Failure {
+	var failures []Failure
+	for _, line := range lines(text) {
+		m := pytestPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		message := strings.TrimSpace(m[3])
+		if message == "" {
+			message = "test failed"
+		}
+		failures = append(failures, Failure{File: m[1], Test: m[2], Message: message})
+	}
+	return failures
+}
+
+var (
+	// diagnosticPattern matches file:line[:col]: message, as printed by Go, GCC, Clang,
+	// mypy, flake8 and most linters' unix formats.
+	diagnosticPattern = regexp.MustCompile(`^(?:vet: )?([^\s:()]+\.[A-Za-z0-9]+):(\d+)(?::(\d+))?:\s*(.+)$`)
+	// tscPattern matches TypeScript's file(line,col): error TS1234: message.
+	tscPattern = regexp.MustCompile(`^([^\s()]+)\((\d+),(\d+)\): error (.+)$`)
+	// rustPattern matches the --> file:line:col line under a Rust error.
+	rustPattern    = regexp.MustCompile(`^\s*--> ([^\s:]+):(\d+):(\d+)$`)
+	rustError      = regexp.MustCompile(`^error(\[\w+\])?: (.+)$`)
+	severityPrefix = regexp.MustCompile(`^(?:fatal )?error(?:\[\w+\])?:\s*`)
+	ignoredPrefix  = regexp.MustCompile(`^(warning|note|info|hint)\b`)
+)
+
+// parseDiagnostics parses compiler-style diagnostics, ignoring warnings and notes.
+func parseDiagnostics(text string) []Failure {
+	var failures []Failure
+	rustMessage := ""
+	for _, line := range lines(text) {
+		if m := rustError.FindStringSubmatch(line); m != nil {
+			rustMessage = m[2]
+			continue
+		}
+		if m := rustPattern.FindStringSubmatch(line); m != nil && rustMessage != "" {
+			failures = append(failures, failure(m[1], m[2], m[3], rustMessage))
+			rustMessage = ""
+			continue
+		}
+		if m := tscPattern.FindStringSubmatch(line); m != nil {
+			failures = append(failures, failure(m[1], m[2], m[3], m[4]))
+			continue
+		}
+		if m := diagnosticPattern.FindStringSubmatch(line); m != nil && !ignoredPrefix.MatchString(m[4]) {
+			failures = append(failures, failure(m[1], m[2], m[3], severityPrefix.ReplaceAllString(m[4], "")))
+		}
+	}
+	return failures
+}
+
+// failure builds a Failure from the strings a pattern matched.
+func failure(file, line, column, message string) Failure {
+	f := Failure{File: file, Message: strings.TrimSpace(message)}
+	f.Line, _ = strconv.Atoi(line)
+	f.Column, _ = strconv.Atoi(column)
+	return f
+}
+
+// lines splits text into lines without their line endings.
+func lines(text string) []string {
+	var all []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		all = append(all, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return all
+}
+
+// firstLine returns the first non-empty line of text.
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
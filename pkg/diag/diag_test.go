@@ -0,0 +1,129 @@
+package diag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected []Failure
+	}{
+		{
+			name: "go test",
+			output: "--- FAIL: TestAdd (0.00s)\n" +
+				"    --- FAIL: TestAdd/negative (0.00s)\n" +
+				"        add_test.go:12: Expected -1, got 1\n" +
+				"--- FAIL: TestPanics (0.00s)\n" +
+				"FAIL\n" +
+				"FAIL\texample.com/calc\t0.005s\n",
+			expected: []Failure{
+				{File: "add_test.go", Line: 12, Test: "TestAdd/negative", Message: "Expected -1, got 1"},
+				{Test: "TestPanics", Message: "test failed"},
+			},
+		},
+		{
+			name: "go test -json",
+			output: `{"Action":"run","Test":"TestSub"}` + "\n" +
+				`{"Action":"output","Test":"TestSub","Output":"=== RUN   TestSub\n"}` + "\n" +
+				`{"Action":"output","Test":"TestSub","Output":"    sub_test.go:8: Expected 2, got 3\n"}` + "\n" +
+				`{"Action":"output","Test":"TestSub","Output":"--- FAIL: TestSub (0.00s)\n"}` + "\n" +
+				`{"Action":"fail","Test":"TestSub"}` + "\n",
+			expected: []Failure{{File: "sub_test.go", Line: 8, Test: "TestSub", Message: "Expected 2, got 3"}},
+		},
+		{
+			name: "compiler diagnostics",
+			output: "# example.com/calc\n" +
+				"./calc.go:7:2: undefined: total\n" +
+				"src/app.ts(3,5): error TS2322: Type 'string' is not assignable to type 'number'.\n" +
+				"main.c:4:1: warning: unused variable\n" +
+				"error[E0308]: mismatched types\n" +
+				"  --> src/main.rs:4:5\n",
+			expected: []Failure{
+				{File: "./calc.go", Line: 7, Column: 2, Message: "undefined: total"},
+				{File: "src/app.ts", Line: 3, Column: 5, Message: "TS2322: Type 'string' is not assignable to type 'number'."},
+				{File: "src/main.rs", Line: 4, Column: 5, Message: "mismatched types"},
+			},
+		},
+		{
+			name: "TAP",
+			output: "TAP version 13\n" +
+				"ok 1 - adds\n" +
+				"not ok 2 - subtracts\n" +
+				"  ---\n" +
+				"  message: 'should be equal'\n" +
+				"  at: test/math.js:14:3\n" +
+				"  ...\n" +
+				"not ok 3 - divides # TODO\n" +
+				"1..3\n",
+			expected: []Failure{{File: "test/math.js", Line: 14, Test: "subtracts", Message: "should be equal"}},
+		},
+		{
+			name: "pytest",
+			output: "=========================== short test summary info ============================\n" +
+				"FAILED tests/test_api.py::test_create - AssertionError: assert 500 == 201\n",
+			expected: []Failure{{File: "tests/test_api.py", Test: "test_create", Message: "AssertionError: assert 500 == 201"}},
+		},
+		{
+			name: "JUnit XML",
+			output: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites><testsuite name="api">
+<testcase classname="tests.test_api" name="test_ok"/>
+<testcase classname="tests.test_api" name="test_create" file="tests/test_api.py" line="21">
+<failure message="assert 500 == 201">Traceback...</failure>
+</testcase>
+<testcase classname="UserSpec" name="loads">
+<error>NullPointerException
+	at UserSpec.java:33</error>
+</testcase>
+</testsuite></testsuites>`,
+			expected: []Failure{
+				{File: "tests/test_api.py", Line: 21, Test: "tests.test_api.test_create", Message: "assert 500 == 201"},
+				{File: "UserSpec.java", Line: 33, Test: "UserSpec.loads", Message: "NullPointerException"},
+			},
+		},
+		{
+			name: "eslint JSON",
+			output: `[{"filePath":"/app/src/a.js","messages":[` +
+				`{"ruleId":"no-unused-vars","severity":2,"message":"'x' is defined but never used.","line":3,"column":7},` +
+				`{"ruleId":"semi","severity":1,"message":"Missing semicolon.","line":4,"column":2}]},` +
+				`{"filePath":"/app/src/b.js","messages":[]}]`,
+			expected: []Failure{{File: "/app/src/a.js", Line: 3, Column: 7, Message: "'x' is defined but never used. (no-unused-vars)"}},
+		},
+		{
+			name:     "nothing recognised",
+			output:   "Error: something went wrong at 12:30:45\nsee http://example.com:8080/help\n",
+			expected: nil,
+		},
+	}
+
+	// Test case: Each format's failures are found with their file, line and test name
+	for _, test := range tests {
+		failures := Parse(test.output)
+		if !reflect.DeepEqual(failures, test.expected) {
+			t.Errorf("%s: Expected %+v, got %+v", test.name, test.expected, failures)
+		}
+	}
+}
+
+func TestFailureString(t *testing.T) {
+	// Test case 1: A test failure with a location names both
+	f := Failure{File: "add_test.go", Line: 12, Test: "TestAdd", Message: "Expected 2, got 3"}
+	if f.String() != "TestAdd (add_test.go:12): Expected 2, got 3" {
+		t.Errorf("Expected the test and its location, got %q", f.String())
+	}
+
+	// Test case 2: A diagnostic reads like the compiler's own output
+	f = Failure{File: "calc.go", Line: 7, Column: 2, Message: "undefined: total"}
+	if f.String() != "calc.go:7:2: undefined: total" {
+		t.Errorf("Expected file:line:col: message, got %q", f.String())
+	}
+
+	// Test case 3: Files lists each file once, in order
+	files := Files([]Failure{{File: "b.go"}, {Test: "TestX"}, {File: "a.go"}, {File: "b.go"}})
+	if !reflect.DeepEqual(files, []string{"b.go", "a.go"}) {
+		t.Errorf("Expected [b.go a.go], got %v", files)
+	}
+}
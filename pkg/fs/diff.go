@@ -0,0 +1,167 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffContext is the number of unchanged lines shown around each change in a diff.
+const DiffContext = 3
+
+// maxDiffCells bounds the table used to line up two files. Past it, the differing
+// middle of the files is shown as removed and re-added rather than line by line.
+const maxDiffCells = 4000000
+
+// diffLine is one line of a diff: ' ' for unchanged, '-' for removed, '+' for added.
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff returns a unified diff from old to new, labelled with oldName and
+// newName, e.g. "a/main.go" and "b/main.go", or "/dev/null" for a file that doesn't
+// exist. It returns "" when the contents have the same lines.
+func UnifiedDiff(oldName string, newName string, old string, new string) string {
+	lines := diffLines(splitLines(old), splitLines(new))
+
+	var out strings.Builder
+	oldLine, newLine := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+
+		// Extend the hunk over every change separated by no more unchanged lines than
+		// the context on both sides would show anyway.
+		start := i - DiffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i + 1
+		for j := i; j < len(lines) && j-end <= 2*DiffContext; j++ {
+			if lines[j].kind != ' ' {
+				end = j + 1
+			}
+		}
+		stop := end + DiffContext
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+
+		oldStart, newStart := oldLine-(i-start), newLine-(i-start)
+		oldCount, newCount := 0, 0
+		var body strings.Builder
+		for _, line := range lines[start:stop] {
+			if line.kind != '+' {
+				oldCount++
+			}
+			if line.kind != '-' {
+				newCount++
+			}
+			body.WriteByte(line.kind)
+			body.WriteString(line.text)
+			body.WriteByte('\n')
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n%s", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount), body.String())
+
+		for _, line := range lines[i:stop] {
+			if line.kind != '+' {
+				oldLine++
+			}
+			if line.kind != '-' {
+				newLine++
+			}
+		}
+		i = stop
+	}
+	return out.String()
+}
+
+// DiffStat counts the lines a unified diff adds and removes.
+func DiffStat(diff string) (added int, removed int) {
+	for _, line := range splitLines(diff) {
+		switch {
+		case strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// hunkRange formats the start and length of one side of a hunk. An empty side
+// starts at the line before it, as in diff -u.
+func hunkRange(before int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// diffLines lines up a and b along their longest common subsequence.
+func diffLines(a []string, b []string) []diffLine {
+	var lines []diffLine
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		lines = append(lines, diffLine{' ', a[prefix]})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, line := range midA {
+			lines = append(lines, diffLine{'-', line})
+		}
+		for _, line := range midB {
+			lines = append(lines, diffLine{'+', line})
+		}
+	} else {
+		// common[i][j] is the length of the longest common subsequence of midA[i:]
+		// and midB[j:].
+		width := len(midB) + 1
+		common := make([]int32, (len(midA)+1)*width)
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					common[i*width+j] = common[(i+1)*width+j+1] + 1
+				} else if common[(i+1)*width+j] >= common[i*width+j+1] {
+					common[i*width+j] = common[(i+1)*width+j]
+				} else {
+					common[i*width+j] = common[i*width+j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(midA) || j < len(midB) {
+			switch {
+			case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+				lines = append(lines, diffLine{' ', midA[i]})
+				i++
+				j++
+			case j == len(midB) || (i < len(midA) && common[(i+1)*width+j] >= common[i*width+j+1]):
+				lines = append(lines, diffLine{'-', midA[i]})
+				i++
+			default:
+				lines = append(lines, diffLine{'+', midB[j]})
+				j++
+			}
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', line})
+	}
+	return lines
+}
@@ -0,0 +1,53 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	old := numberedLines(20)
+
+	// Test case 1: Identical content has no diff
+	if diff := UnifiedDiff("a/f.txt", "b/f.txt", old, old); diff != "" {
+		t.Errorf("Expected no diff, got:\n%s", diff)
+	}
+
+	// Test case 2: One change gets one hunk with three lines of context
+	new := strings.Replace(old, "line 10\n", "line ten\n", 1)
+	diff := UnifiedDiff("a/f.txt", "b/f.txt", old, new)
+	expected := "--- a/f.txt\n+++ b/f.txt\n@@ -7,7 +7,7 @@\n line 7\n line 8\n line 9\n-line 10\n+line ten\n line 11\n line 12\n line 13\n"
+	if diff != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, diff)
+	}
+
+	// Test case 3: Distant changes get separate hunks, and the diff applies back
+	new = strings.Replace(strings.Replace(old, "line 2\n", "", 1), "line 19\n", "line 19\nline 19.5\n", 1)
+	diff = UnifiedDiff("a/f.txt", "b/f.txt", old, new)
+	if strings.Count(diff, "@@ -") != 2 {
+		t.Errorf("Expected two hunks, got:\n%s", diff)
+	}
+	if added, removed := DiffStat(diff); added != 1 || removed != 1 {
+		t.Errorf("Expected 1 line added and 1 removed, got %d and %d", added, removed)
+	}
+	patched, rejected, err := ApplyPatch(old, diff)
+	if err != nil || len(rejected) != 0 || patched != new {
+		t.Errorf("Expected the diff to apply back, got %v %v:\n%s", rejected, err, patched)
+	}
+
+	// Test case 4: A new file is diffed against /dev/null
+	diff = UnifiedDiff("/dev/null", "b/f.txt", "", "a\nb\n")
+	expected = "--- /dev/null\n+++ b/f.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n"
+	if diff != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, diff)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+
+	"github.com/productdevtool/pdt-cli/pkg/runner"
 )
 
 // Exists checks if a file or directory exists.
@@ -27,15 +30,17 @@ func ReadTodoFile(path string) ([]string, error) {
 	}
 	defer file.Close()
 
-	var tasks []string
+	tasks := []string{}
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		// We'll skip empty lines and lines that are just markdown headers or separators
 		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "---") {
-			// Remove the markdown checkbox prefix if present
+			// Remove the markdown checkbox or bullet prefix if present
 			if strings.HasPrefix(line, "- [ ] ") {
 				line = strings.TrimPrefix(line, "- [ ] ")
+			} else if strings.HasPrefix(line, "- ") {
+				line = strings.TrimPrefix(line, "- ")
 			}
 			tasks = append(tasks, line)
 		}
@@ -81,63 +86,13 @@ func RewriteTodoFile(path string, tasks []string) error {
 	return nil
 }
 
-// CodeBlock represents a code block extracted from markdown.
-type CodeBlock struct {
-	FilePath string
-	Content  string
-}
-
-// ExtractCodeBlocks extracts code blocks from a markdown string.
-func ExtractCodeBlocks(markdown string) ([]CodeBlock, error) {
-	var codeBlocks []CodeBlock
-	scanner := bufio.NewScanner(strings.NewReader(markdown))
-
-	inCodeBlock := false
-	currentFilePath := ""
-	currentContent := strings.Builder{}
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if strings.HasPrefix(line, "```") {
-			if inCodeBlock {
-				// End of a code block
-				codeBlocks = append(codeBlocks, CodeBlock{
-					FilePath: currentFilePath,
-					Content:  currentContent.String(),
-				})
-				inCodeBlock = false
-				currentFilePath = ""
-				currentContent.Reset()
-			} else {
-				// Start of a code block
-				inCodeBlock = true
-				// Try to extract file path from the line, e.g., ```go // path/to/file.go
-				parts := strings.Fields(line)
-				if len(parts) > 1 && strings.HasPrefix(parts[1], "//") {
-					currentFilePath = strings.TrimSpace(strings.TrimPrefix(parts[1], "//"))
-				} else if len(parts) > 1 && !strings.Contains(parts[1], " ") {
-					// If it's just a language, assume no path for now
-					currentFilePath = ""
-				}
-			}
-		} else if inCodeBlock {
-			currentContent.WriteString(line + "\n")
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	return codeBlocks, nil
-}
-
-// GetProjectCommand extracts a specific command from project-description.md.
-func GetProjectCommand(commandName string) (string, error) {
-	content, err := os.ReadFile("docs/project-description.md")
+// GetProjectCommand extracts a specific command from the Commands section of the
+// project description at path. The command may be followed by attributes, as
+// described in runner.Parse.
+func GetProjectCommand(path string, commandName string) (runner.Command, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return "", fmt.Errorf("error reading project-description.md: %w", err)
+		return runner.Command{}, fmt.Errorf("error reading %s: %w", path, err)
 	}
 
 	scanner := bufio.NewScanner(strings.NewReader(string(content)))
@@ -151,10 +106,11 @@ func GetProjectCommand(commandName string) (string, error) {
 		}
 
 		if inCommandsSection && strings.HasPrefix(line, fmt.Sprintf("- %s: `", commandName)) {
-			parts := strings.Split(line, "`")
-			if len(parts) >= 2 {
-				return parts[1], nil
+			command, err := runner.Parse(strings.TrimPrefix(line, fmt.Sprintf("- %s: ", commandName)))
+			if err != nil {
+				return runner.Command{}, fmt.Errorf("invalid %s command in %s: %w", commandName, path, err)
 			}
+			return command, nil
 		}
 
 		if inCommandsSection && strings.HasPrefix(line, "## ") && !strings.HasPrefix(line, "## Commands") {
@@ -163,19 +119,21 @@ func GetProjectCommand(commandName string) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("command '%s' not found in project-description.md", commandName)
+	return runner.Command{}, fmt.Errorf("command '%s' not found in %s", commandName, filepath.Base(path))
 }
 
-// GetValidationCommands extracts validation commands from project-description.md.
-func GetValidationCommands() ([]string, error) {
-	content, err := os.ReadFile("docs/project-description.md")
+// GetValidationCommands extracts validation commands from the Automated Validation
+// section of the project description at path. Each command may be followed by
+// attributes, as described in runner.Parse.
+func GetValidationCommands(path string) ([]runner.Command, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("error reading project-description.md: %w", err)
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
 	}
 
 	scanner := bufio.NewScanner(strings.NewReader(string(content)))
 	inValidationSection := false
-	var commands []string
+	commands := []runner.Command{}
 
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -186,10 +144,12 @@ func GetValidationCommands() ([]string, error) {
 		}
 
 		if inValidationSection {
-			if strings.HasPrefix(line, "- `") && strings.HasSuffix(line, "`") {
-				// Extract command between backticks
-				cmd := strings.TrimPrefix(line, "- `")
-				cmd = strings.TrimSuffix(cmd, "`")
+			if strings.HasPrefix(line, "- `") {
+				// Extract command between backticks and its attributes
+				cmd, err := runner.Parse(strings.TrimPrefix(line, "- "))
+				if err != nil {
+					return nil, fmt.Errorf("invalid validation command in %s: %w", path, err)
+				}
 				commands = append(commands, cmd)
 			} else if strings.HasPrefix(line, "## ") {
 				// Exited the validation section
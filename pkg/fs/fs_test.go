@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/productdevtool/pdt-cli/pkg/runner"
 )
 
 func TestExists(t *testing.T) {
@@ -40,7 +42,7 @@ func TestReadTodoFile(t *testing.T) {
 	// Test case 1: Valid todo.md file
 	dir := t.TempDir()
 	todoPath := filepath.Join(dir, "todo.md")
-	content := `# Todo\n\n- [ ] Task 1\n- [ ] Task 2\n  - Subtask\n- Another Task\n`
+	content := "# Todo\n\n- [ ] Task 1\n- [ ] Task 2\n  - Subtask\n- Another Task\n"
 	err := ioutil.WriteFile(todoPath, []byte(content), 0644)
 	if err != nil {
 		t.Fatalf("Failed to create todo file: %v", err)
@@ -110,7 +112,7 @@ func TestRewriteTodoFile(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to read rewritten todo file: %v", err)
 	}
-	expectedContent := `# Todo\n\n- [ ] Task A\n- [ ] Task B\n`
+	expectedContent := "# Todo\n\n- [ ] Task A\n- [ ] Task B\n"
 	if string(content) != expectedContent {
 		t.Errorf("Expected content:\n%s\nGot:\n%s", expectedContent, string(content))
 	}
@@ -126,7 +128,7 @@ func TestRewriteTodoFile(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to read empty rewritten todo file: %v", err)
 	}
-	expectedContent = `# Todo\n\n`
+	expectedContent = "# Todo\n\n"
 	if string(content) != expectedContent {
 		t.Errorf("Expected empty content:\n%s\nGot:\n%s", expectedContent, string(content))
 	}
@@ -134,28 +136,16 @@ func TestRewriteTodoFile(t *testing.T) {
 
 func TestExtractCodeBlocks(t *testing.T) {
 	// Test case 1: Markdown with code blocks and file paths
-	markdown := `
-# Header
-
-Some text.
-
-```go // main.go
-package main
-
-func main() {
-	fmt.Println("Hello, Go!")
-}
-```
-
-More text.
-
-```python // script.py
-print("Hello, Python!")
-```
-`
+	markdown := "\n# Header\n\nSome text.\n\n" +
+		"```go // main.go\n" +
+		"package main\n\nfunc main() {\n\tfmt.Println(\"Hello, Go!\")\n}\n" +
+		"```\n\nMore text.\n\n" +
+		"```python // script.py\n" +
+		"print(\"Hello, Python!\")\n" +
+		"```\n"
 	expected := []CodeBlock{
-	{FilePath: "main.go", Content: "package main\n\nfunc main() {\n\tfmt.Println(\"Hello, Go!\")\n}\n"},
-	{FilePath: "script.py", Content: "print(\"Hello, Python!\")\n"},
+	{FilePath: "main.go", Content: "package main\n\nfunc main() {\n\tfmt.Println(\"Hello, Go!\")\n}\n", Language: "go", Line: 6},
+	{FilePath: "script.py", Content: "print(\"Hello, Python!\")\n", Language: "python", Line: 16},
 }
 
 	actual, err := ExtractCodeBlocks(markdown)
@@ -179,13 +169,9 @@ print("Hello, Python!")
 	}
 
 	// Test case 3: Markdown with code block but no file path
-	markdown = `
-```javascript
-console.log("No path");
-```
-`
+	markdown = "\n```javascript\nconsole.log(\"No path\");\n```\n"
 	expected = []CodeBlock{
-	{FilePath: "", Content: "console.log(\"No path\");\n"},
+	{FilePath: "", Content: "console.log(\"No path\");\n", Language: "javascript", Line: 2},
 }
 	actual, err = ExtractCodeBlocks(markdown)
 	if err != nil {
@@ -204,16 +190,10 @@ func TestGetProjectCommand(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create docs directory: %v", err)
 	}
-	projectDescContent := `
-# Project Description
-
-## Commands
-- build: `npm run build`
-- deploy: `firebase deploy`
-- test: `npm test`
-
-## Other Section
-`
+	projectDescContent := "\n# Project Description\n\n## Commands\n" +
+		"- build: `npm run build`\n" +
+		"- deploy: `firebase deploy`\n" +
+		"- test: `npm test`\n\n## Other Section\n"
 	err = ioutil.WriteFile(projectDescPath, []byte(projectDescContent), 0644)
 	if err != nil {
 		t.Fatalf("Failed to write project-description.md: %v", err)
@@ -231,17 +211,17 @@ func TestGetProjectCommand(t *testing.T) {
 
 	// Test case 1: Command exists
 	expectedCommand := "npm run build"
-	actualCommand, err := GetProjectCommand("build")
+	actualCommand, err := GetProjectCommand("docs/project-description.md", "build")
 	if err != nil {
 		t.Fatalf("GetProjectCommand returned an error: %v", err)
 	}
-	if actualCommand != expectedCommand {
+	if actualCommand.Run != expectedCommand {
 		t.Errorf("Expected command %s, got %s", expectedCommand, actualCommand)
 	}
 
 	// Test case 2: Command does not exist
 	expectedError := "command 'nonexistent' not found in project-description.md"
-	_, err = GetProjectCommand("nonexistent")
+	_, err = GetProjectCommand("docs/project-description.md", "nonexistent")
 	if err == nil || err.Error() != expectedError {
 		t.Errorf("Expected error %s, got %v", expectedError, err)
 	}
@@ -255,15 +235,9 @@ func TestGetValidationCommands(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create docs directory: %v", err)
 	}
-	projectDescContent := `
-# Project Description
-
-## Automated Validation
-- `npm run lint`
-- `go test ./...`
-
-## Other Section
-`
+	projectDescContent := "\n# Project Description\n\n## Automated Validation\n" +
+		"- `npm run lint`\n" +
+		"- `go test ./...`\n\n## Other Section\n"
 	err = ioutil.WriteFile(projectDescPath, []byte(projectDescContent), 0644)
 	if err != nil {
 		t.Fatalf("Failed to write project-description.md: %v", err)
@@ -280,8 +254,8 @@ func TestGetValidationCommands(t *testing.T) {
 	defer os.Chdir(originalDir) // Restore original working directory
 
 	// Test case 1: Commands exist
-	expectedCommands := []string{"npm run lint", "go test ./..."}
-	actualCommands, err := GetValidationCommands()
+	expectedCommands := []runner.Command{{Run: "npm run lint"}, {Run: "go test ./..."}}
+	actualCommands, err := GetValidationCommands("docs/project-description.md")
 	if err != nil {
 		t.Fatalf("GetValidationCommands returned an error: %v", err)
 	}
@@ -290,22 +264,34 @@ func TestGetValidationCommands(t *testing.T) {
 	}
 
 	// Test case 2: No validation commands
-	projectDescContent = `
-# Project Description
-
-## Other Section
-`
+	projectDescContent = "\n# Project Description\n\n## Other Section\n"
 	err = ioutil.WriteFile(projectDescPath, []byte(projectDescContent), 0644)
 	if err != nil {
 		t.Fatalf("Failed to write project-description.md: %v", err)
 	}
 
-	expectedCommands = []string{}
-	actualCommands, err = GetValidationCommands()
+	expectedCommands = []runner.Command{}
+	actualCommands, err = GetValidationCommands("docs/project-description.md")
 	if err != nil {
 		t.Fatalf("GetValidationCommands returned an error: %v", err)
 	}
 	if !reflect.DeepEqual(expectedCommands, actualCommands) {
 		t.Errorf("Expected empty commands, got %v", actualCommands)
 	}
+
+	// Test case 3: Shell syntax and attributes
+	projectDescContent = "## Automated Validation\n- `go vet ./... && go test -run 'Test.*' ./...` (cwd=api, continue_on_error)\n"
+	err = ioutil.WriteFile(projectDescPath, []byte(projectDescContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write project-description.md: %v", err)
+	}
+
+	expectedCommands = []runner.Command{{Run: "go vet ./... && go test -run 'Test.*' ./...", Dir: "api", ContinueOnError: true}}
+	actualCommands, err = GetValidationCommands("docs/project-description.md")
+	if err != nil {
+		t.Fatalf("GetValidationCommands returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(expectedCommands, actualCommands) {
+		t.Errorf("Expected commands %v, got %v", expectedCommands, actualCommands)
+	}
 }
@@ -0,0 +1,341 @@
+package fs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// CodeBlock represents a code block extracted from markdown.
+type CodeBlock struct {
+	FilePath string
+	Content  string
+	// Language is the first word of the fence's info string, e.g. "go".
+	Language string
+	// Line is the 1-based line number of the opening fence.
+	Line int
+	// Truncated is set when the block has no closing fence, e.g. because the
+	// response was cut off.
+	Truncated bool
+}
+
+// Warning describes a code block that was extracted but may not be what the author meant.
+type Warning struct {
+	Line    int
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d: %s", w.Line, w.Message)
+}
+
+// ExtractCodeBlocks extracts code blocks from a markdown string.
+func ExtractCodeBlocks(markdown string) ([]CodeBlock, error) {
+	blocks, _ := ParseCodeBlocks(markdown)
+	return blocks, nil
+}
+
+// fence is an open fenced code block.
+type fence struct {
+	char   byte
+	length int
+	indent int
+	info   string
+	line   int
+}
+
+// ParseCodeBlocks extracts fenced code blocks following CommonMark's rules: fences of
+// three or more backticks or tildes, closed by a fence of the same character that is
+// at least as long, so longer fences can contain shorter ones. A block's file path is
+// taken, in order of preference, from its info string ("go // path", "go:path",
+// "filename=path", "title=\"path\"" or a bare path), from a comment on its first line
+// (which is removed from the content), or from a heading or label just above the
+// fence. Blocks that have no path, conflicting paths or no closing fence are still
+// returned, with a warning.
+func ParseCodeBlocks(markdown string) ([]CodeBlock, []Warning) {
+	blocks := []CodeBlock{}
+	var warnings []Warning
+
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var open *fence
+	var content []string
+	// label is a path named by the last non-blank line outside a block, if any.
+	label := ""
+	blankSinceLabel := 0
+
+	finish := func(closed bool) {
+		block, blockWarnings := newCodeBlock(open, content, label)
+		if !closed {
+			block.Truncated = true
+			blockWarnings = append(blockWarnings, Warning{Line: open.line, Message: "code block is never closed; using everything up to the end of the response"})
+		}
+		blocks = append(blocks, block)
+		warnings = append(warnings, blockWarnings...)
+		open, content, label = nil, nil, ""
+	}
+
+	for i, line := range lines {
+		if open != nil {
+			if isClosingFence(line, open) {
+				finish(true)
+				continue
+			}
+			content = append(content, stripIndent(line, open.indent))
+			continue
+		}
+
+		if f, ok := openingFence(line); ok {
+			f.line = i + 1
+			if blankSinceLabel > 1 {
+				label = ""
+			}
+			open = f
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			blankSinceLabel++
+			continue
+		}
+		label = pathFromLabel(line)
+		blankSinceLabel = 0
+	}
+	if open != nil {
+		finish(false)
+	}
+
+	seen := map[string]int{}
+	for _, block := range blocks {
+		if block.FilePath == "" {
+			continue
+		}
+		if first, ok := seen[block.FilePath]; ok {
+			warnings = append(warnings, Warning{Line: block.Line, Message: fmt.Sprintf("%s also appears in the block at line %d; the later block wins", block.FilePath, first)})
+		}
+		seen[block.FilePath] = block.Line
+	}
+	return blocks, warnings
+}
+
+func newCodeBlock(f *fence, content []string, label string) (CodeBlock, []Warning) {
+	var warnings []Warning
+	block := CodeBlock{Line: f.line}
+	fields := strings.Fields(f.info)
+	if len(fields) > 0 {
+		block.Language = fields[0]
+	}
+
+	if block.Language == OpsLanguage {
+		// File operations name their own paths.
+		if len(content) > 0 {
+			block.Content = strings.Join(content, "\n") + "\n"
+		}
+		return block, nil
+	}
+
+	infoPath := pathFromInfo(f.info)
+	if i := strings.Index(block.Language, ":"); i >= 0 {
+		block.Language = block.Language[:i]
+	}
+
+	commentPath := ""
+	if len(content) > 0 {
+		commentPath = pathFromComment(content[0], block.Language)
+	}
+
+	switch {
+	case infoPath != "":
+		block.FilePath = infoPath
+		if commentPath != "" && commentPath != infoPath {
+			warnings = append(warnings, Warning{Line: f.line, Message: fmt.Sprintf("the fence names %s but the first line names %s; using %s", infoPath, commentPath, infoPath)})
+		}
+	case commentPath != "":
+		block.FilePath = commentPath
+	case label != "":
+		block.FilePath = label
+	}
+	if commentPath != "" && commentPath == block.FilePath {
+		content = content[1:]
+	}
+
+	if block.FilePath == "" {
+		warnings = append(warnings, Warning{Line: f.line, Message: "code block has no file path, so it can't be written; name one in the fence, e.g. ```go // path/to/file.go"})
+	}
+	if len(content) > 0 {
+		block.Content = strings.Join(content, "\n") + "\n"
+	}
+	return block, warnings
+}
+
+// openingFence recognises a line that opens a fenced code block.
+func openingFence(line string) (*fence, bool) {
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	rest := line[indent:]
+	if len(rest) < 3 || (rest[0] != '`' && rest[0] != '~') {
+		return nil, false
+	}
+	char := rest[0]
+	length := 0
+	for length < len(rest) && rest[length] == char {
+		length++
+	}
+	if length < 3 {
+		return nil, false
+	}
+	info := strings.TrimSpace(rest[length:])
+	if char == '`' && strings.Contains(info, "`") {
+		// Inline code such as ```foo``` is not a fence.
+		return nil, false
+	}
+	return &fence{char: char, length: length, indent: indent, info: info}, true
+}
+
+// isClosingFence reports whether line closes f: the same character, at least as many
+// of them, and nothing else on the line.
+func isClosingFence(line string, f *fence) bool {
+	rest := strings.TrimSpace(line)
+	if len(rest) < f.length {
+		return false
+	}
+	for i := 0; i < len(rest); i++ {
+		if rest[i] != f.char {
+			return false
+		}
+	}
+	return true
+}
+
+// stripIndent removes up to n leading spaces, the indentation of the opening fence.
+func stripIndent(line string, n int) string {
+	for i := 0; i < n && strings.HasPrefix(line, " "); i++ {
+		line = line[1:]
+	}
+	return line
+}
+
+var (
+	infoAttribute = regexp.MustCompile(`(?:^|[\s{,])(?:filename|file|path|title)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s},]+))`)
+	fileExtension = regexp.MustCompile(`\.[A-Za-z0-9_-]+$`)
+	inlineCode    = regexp.MustCompile("`([^`]+)`")
+)
+
+// pathFromInfo finds a path in a fence's info string.
+func pathFromInfo(info string) string {
+	if m := infoAttribute.FindStringSubmatch(info); m != nil {
+		return cleanPath(m[1] + m[2] + m[3])
+	}
+	if i := strings.Index(info, "//"); i >= 0 {
+		if fields := strings.Fields(info[i+2:]); len(fields) > 0 && looksLikePath(fields[0]) {
+			return cleanPath(fields[0])
+		}
+		return ""
+	}
+
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return ""
+	}
+	// "go:cmd/main.go"
+	if i := strings.Index(fields[0], ":"); i >= 0 && looksLikePath(fields[0][i+1:]) {
+		return cleanPath(fields[0][i+1:])
+	}
+	// "cmd/main.go" or "go cmd/main.go"
+	if len(fields) > 2 {
+		fields = fields[:2]
+	}
+	for _, field := range fields {
+		if isPath(field) {
+			return cleanPath(field)
+		}
+	}
+	return ""
+}
+
+var commentMarkers = []struct{ open, close string }{
+	{"//", ""}, {"#", ""}, {"--", ""}, {";", ""}, {"/*", "*/"}, {"<!--", "-->"}, {"{/*", "*/}"},
+}
+
+// pathFromComment finds a path in a comment such as "// path/to/file.go" or
+// "<!-- File: docs/intro.md -->" on the first line of a block. In markdown, "#" starts
+// a heading rather than a comment.
+func pathFromComment(line string, language string) string {
+	line = strings.TrimSpace(line)
+	for _, marker := range commentMarkers {
+		if !strings.HasPrefix(line, marker.open) || !strings.HasSuffix(line, marker.close) {
+			continue
+		}
+		if marker.open == "#" && (language == "md" || language == "markdown") {
+			return ""
+		}
+		text := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, marker.open), marker.close))
+		text = trimLabel(text)
+		if isPath(text) {
+			return cleanPath(text)
+		}
+		return ""
+	}
+	return ""
+}
+
+// pathFromLabel finds a path in the line before a fence, such as "### cmd/main.go",
+// "**File: `cmd/main.go`**", "`cmd/main.go`:" or "Create `cmd/main.go`:".
+func pathFromLabel(line string) string {
+	text := strings.TrimSpace(line)
+	if strings.HasSuffix(strings.TrimRight(text, "*_"), ":") {
+		if spans := inlineCode.FindAllStringSubmatch(text, -1); len(spans) == 1 && isPath(spans[0][1]) {
+			return cleanPath(spans[0][1])
+		}
+	}
+	text = strings.TrimLeft(text, "#")
+	text = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "- "))
+	text = strings.Trim(text, "*_ ")
+	text = strings.TrimSuffix(text, ":")
+	text = strings.Trim(text, "*_ ")
+	text = trimLabel(text)
+	text = strings.Trim(text, "`*_ ")
+	text = strings.TrimSuffix(text, ":")
+	if isPath(text) {
+		return cleanPath(text)
+	}
+	return ""
+}
+
+// trimLabel removes a "File:" style prefix.
+func trimLabel(text string) string {
+	for _, prefix := range []string{"File:", "file:", "Filename:", "filename:", "Path:", "path:"} {
+		if strings.HasPrefix(text, prefix) {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(text, prefix)), "`*_")
+		}
+	}
+	return text
+}
+
+// isPath reports whether s looks like a path and has a directory or an extension,
+// which tells "main.go" apart from a language name or a word.
+func isPath(s string) bool {
+	return looksLikePath(s) && (strings.Contains(s, "/") || fileExtension.MatchString(s))
+}
+
+// looksLikePath reports whether s could be a relative file path: no spaces, shell or
+// code punctuation, or URL scheme, and not just punctuation.
+func looksLikePath(s string) bool {
+	if s == "" || strings.ContainsAny(s, " \t`\"'<>|*?!$=,;{}") || strings.Contains(s, "://") {
+		return false
+	}
+	return strings.IndexFunc(s, func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+	}) >= 0
+}
+
+func cleanPath(s string) string {
+	s = strings.TrimPrefix(strings.Trim(s, "`"), "./")
+	if s == "" {
+		return ""
+	}
+	return path.Clean(s)
+}
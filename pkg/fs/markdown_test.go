@@ -0,0 +1,82 @@
+package fs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCodeBlocksPathAnnotations(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		path     string
+		content  string
+	}{
+		{"comment in info string", "```go // cmd/main.go\npackage main\n```\n", "cmd/main.go", "package main\n"},
+		{"language and path", "```go:cmd/main.go\npackage main\n```\n", "cmd/main.go", "package main\n"},
+		{"filename attribute", "```python filename=app/run.py\nprint()\n```\n", "app/run.py", "print()\n"},
+		{"title attribute", "```js title=\"src/index.js\"\nrun()\n```\n", "src/index.js", "run()\n"},
+		{"bare path", "```src/index.ts\nrun()\n```\n", "src/index.ts", "run()\n"},
+		{"heading above", "### `pkg/a/a.go`\n\n```go\npackage a\n```\n", "pkg/a/a.go", "package a\n"},
+		{"label above", "Create `web/app.css`:\n```css\nbody {}\n```\n", "web/app.css", "body {}\n"},
+		{"first-line comment", "```go\n// pkg/b/b.go\npackage b\n```\n", "pkg/b/b.go", "package b\n"},
+		{"html comment", "```html\n<!-- File: site/index.html -->\n<p></p>\n```\n", "site/index.html", "<p></p>\n"},
+		{"tilde fence", "~~~yaml // config.yaml\nkey: value\n~~~\n", "config.yaml", "key: value\n"},
+		{"longer fence nests a shorter one", "````md // README.md\n# Title\n```sh\nmake\n```\n````\n", "README.md", "# Title\n```sh\nmake\n```\n"},
+	}
+	for _, test := range tests {
+		blocks, warnings := ParseCodeBlocks(test.markdown)
+		if len(blocks) != 1 {
+			t.Errorf("%s: Expected 1 block, got %d: %v", test.name, len(blocks), blocks)
+			continue
+		}
+		if blocks[0].FilePath != test.path || blocks[0].Content != test.content {
+			t.Errorf("%s: Expected %s with %q, got %s with %q", test.name, test.path, test.content, blocks[0].FilePath, blocks[0].Content)
+		}
+		if len(warnings) != 0 {
+			t.Errorf("%s: Expected no warnings, got %v", test.name, warnings)
+		}
+	}
+}
+
+func TestParseCodeBlocksWarnings(t *testing.T) {
+	markdown := "Some prose.\n\n" +
+		"```sh\nmake build\n```\n\n" +
+		"```go // a.go\n// b.go\npackage a\n```\n\n" +
+		"```go // a.go\npackage a\n```\n\n" +
+		"```python\n#!/usr/bin/env python\nprint()\n"
+	blocks, warnings := ParseCodeBlocks(markdown)
+	if len(blocks) != 4 {
+		t.Fatalf("Expected 4 blocks, got %d: %v", len(blocks), blocks)
+	}
+
+	// Test case 1: Line numbers point at the opening fences
+	lines := []int{3, 7, 12, 16}
+	for i, block := range blocks {
+		if block.Line != lines[i] {
+			t.Errorf("Expected block %d at line %d, got %d", i, lines[i], block.Line)
+		}
+	}
+
+	// Test case 2: Each ambiguity is reported against its block
+	expected := []string{
+		"line 3: code block has no file path",
+		"line 7: the fence names a.go but the first line names b.go",
+		"line 16: code block has no file path",
+		"line 16: code block is never closed",
+		"line 12: a.go also appears in the block at line 7",
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %v", len(expected), warnings)
+	}
+	for i, warning := range warnings {
+		if !strings.HasPrefix(warning.String(), expected[i]) {
+			t.Errorf("Expected a warning starting with %q, got %q", expected[i], warning)
+		}
+	}
+
+	// Test case 3: A shebang is not a path, and an unclosed block keeps its content
+	if blocks[3].FilePath != "" || blocks[3].Content != "#!/usr/bin/env python\nprint()\n" || !blocks[3].Truncated {
+		t.Errorf("Expected the unclosed block to keep its shebang, got %s with %q", blocks[3].FilePath, blocks[3].Content)
+	}
+}
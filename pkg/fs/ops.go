@@ -0,0 +1,282 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Action is what a FileOperation does to its path.
+type Action string
+
+const (
+	// ActionCreate writes a new file.
+	ActionCreate Action = "create"
+	// ActionUpdate replaces the content of an existing file.
+	ActionUpdate Action = "update"
+	// ActionPatch edits a file with a unified diff or SEARCH/REPLACE blocks; a diff
+	// against /dev/null creates the file.
+	ActionPatch Action = "patch"
+	// ActionDelete removes a file.
+	ActionDelete Action = "delete"
+	// ActionRename moves a file to the path in To.
+	ActionRename Action = "rename"
+	// ActionChmod sets a file's permissions to the octal Mode, e.g. "0755".
+	ActionChmod Action = "chmod"
+)
+
+// OpsLanguage is the info string of a markdown code block that lists file operations
+// other than writes, one per line:
+//
+//	delete path/to/file
+//	rename old/path new/path
+//	chmod 0755 path/to/script
+const OpsLanguage = "pdt-ops"
+
+// FileOperation is one change the AI asked for, from either structured JSON output
+// or a fenced code block.
+type FileOperation struct {
+	Path      string `json:"path"`
+	Action    Action `json:"action"`
+	Content   string `json:"content"`
+	To        string `json:"to,omitempty"`
+	Mode      string `json:"mode,omitempty"`
+	Rationale string `json:"rationale,omitempty"`
+}
+
+// NeedsExistingFile reports whether the operation only makes sense on a file that
+// already exists: a delete, rename or chmod. Patches don't, since a diff against
+// /dev/null creates its file.
+func (op FileOperation) NeedsExistingFile() bool {
+	switch op.Action {
+	case ActionDelete, ActionRename, ActionChmod:
+		return true
+	}
+	return false
+}
+
+// structuredOutput is the JSON document the AI returns in structured mode.
+type structuredOutput struct {
+	Files []FileOperation `json:"files"`
+}
+
+// SchemaError lists every way a structured response breaks the schema.
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return "invalid structured output:\n- " + strings.Join(e.Problems, "\n- ")
+}
+
+// OperationsFromBlocks turns code blocks into file operations. Blocks that open with a
+// unified diff or SEARCH/REPLACE edits become patches, one per file they touch, while
+// other blocks with a path replace the whole file, even when they contain diff text,
+// and OpsLanguage blocks become deletes, renames and mode changes.
+// Blocks without a file path can't be written and are returned separately, as are
+// edits that can't be parsed, with a warning explaining why.
+func OperationsFromBlocks(blocks []CodeBlock) ([]FileOperation, []CodeBlock, []Warning) {
+	ops := []FileOperation{}
+	var skipped []CodeBlock
+	var warnings []Warning
+	for _, block := range blocks {
+		if block.Language == OpsLanguage {
+			fileOps, opWarnings := ParseFileCommands(block.Content, block.Line+1)
+			ops = append(ops, fileOps...)
+			warnings = append(warnings, opWarnings...)
+			continue
+		}
+		// A diff block without a path can only be an edit; parsing it says what's wrong.
+		edit := IsPatch(block.Content) || block.FilePath == "" && (block.Language == "diff" || block.Language == "patch")
+		if !edit {
+			if block.FilePath == "" {
+				skipped = append(skipped, block)
+				continue
+			}
+			ops = append(ops, FileOperation{Path: block.FilePath, Action: ActionUpdate, Content: block.Content})
+			continue
+		}
+
+		patches, err := ParsePatch(block.Content)
+		if err != nil {
+			skipped = append(skipped, block)
+			warnings = append(warnings, Warning{Line: block.Line, Message: fmt.Sprintf("edit can't be applied: %v", err)})
+			continue
+		}
+		for _, patch := range patches {
+			path := patch.Path
+			if path == "" {
+				path = block.FilePath
+			}
+			if path == "" {
+				skipped = append(skipped, block)
+				warnings = append(warnings, Warning{Line: block.Line, Message: "edit doesn't name the file it changes"})
+				continue
+			}
+			ops = append(ops, FileOperation{Path: path, Action: ActionPatch, Content: patch.Text})
+		}
+	}
+	return ops, skipped, warnings
+}
+
+// ParseFileCommands parses the lines of an OpsLanguage block, the first of which is
+// line firstLine of the response. Blank lines and lines starting with "#" are ignored,
+// "rm" and "remove" mean delete, "mv" and "move" mean rename, and a rename may put
+// "->" or "to" between its paths. Lines that can't be used are returned as warnings.
+func ParseFileCommands(text string, firstLine int) ([]FileOperation, []Warning) {
+	var ops []FileOperation
+	var warnings []Warning
+	for i, line := range splitLines(text) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var op FileOperation
+		switch strings.ToLower(fields[0]) {
+		case "delete", "remove", "rm":
+			if len(fields) == 2 {
+				op = FileOperation{Action: ActionDelete, Path: cleanPath(fields[1])}
+			}
+		case "rename", "move", "mv":
+			if len(fields) == 4 && (fields[2] == "->" || fields[2] == "to") {
+				fields = []string{fields[0], fields[1], fields[3]}
+			}
+			if len(fields) == 3 {
+				op = FileOperation{Action: ActionRename, Path: cleanPath(fields[1]), To: cleanPath(fields[2])}
+			}
+		case "chmod":
+			if len(fields) == 3 {
+				op = FileOperation{Action: ActionChmod, Path: cleanPath(fields[2]), Mode: fields[1]}
+			}
+		}
+
+		var problems []string
+		if op.Action == "" {
+			problems = []string{"expected \"delete PATH\", \"rename FROM TO\" or \"chmod MODE PATH\""}
+		} else {
+			problems = validateOperation("operation", op)
+		}
+		if len(problems) > 0 {
+			warnings = append(warnings, Warning{Line: firstLine + i, Message: fmt.Sprintf("ignoring file operation %q: %s", strings.TrimSpace(line), strings.Join(problems, "; "))})
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return ops, warnings
+}
+
+// ParseMode parses an octal permission mode such as "755" or "0644".
+func ParseMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("%q is not an octal permission mode such as 0644 or 0755", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// ApplyPatch applies a patch operation's edits to the current content of its file.
+func ApplyPatch(content string, patch string) (string, []RejectedHunk, error) {
+	patches, err := ParsePatch(patch)
+	if err != nil {
+		return "", nil, err
+	}
+	var hunks []Hunk
+	for _, p := range patches {
+		hunks = append(hunks, p.Hunks...)
+	}
+	patched, rejected := ApplyHunks(content, hunks)
+	return patched, rejected, nil
+}
+
+// ParseStructuredOutput decodes and validates the AI's JSON file operations. The
+// document may be wrapped in a ```json fence or surrounded by stray prose.
+func ParseStructuredOutput(text string) ([]FileOperation, error) {
+	document := extractJSONObject(text)
+	if document == "" {
+		return nil, &SchemaError{Problems: []string{"no JSON object found in the response"}}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(document)))
+	decoder.DisallowUnknownFields()
+	var output structuredOutput
+	if err := decoder.Decode(&output); err != nil {
+		return nil, &SchemaError{Problems: []string{fmt.Sprintf("not valid JSON for the schema: %v", err)}}
+	}
+	if output.Files == nil {
+		return nil, &SchemaError{Problems: []string{`missing required key "files"`}}
+	}
+
+	var problems []string
+	for i, op := range output.Files {
+		field := fmt.Sprintf("files[%d]", i)
+		problems = append(problems, validateOperation(field, op)...)
+	}
+	if len(problems) > 0 {
+		return nil, &SchemaError{Problems: problems}
+	}
+	return output.Files, nil
+}
+
+func validateOperation(field string, op FileOperation) []string {
+	var problems []string
+	if problem := validatePath(op.Path); problem != "" {
+		problems = append(problems, field+".path: "+problem)
+	}
+
+	switch op.Action {
+	case ActionCreate, ActionUpdate:
+		if op.Content == "" {
+			problems = append(problems, fmt.Sprintf("%s.content: is required for action %q", field, op.Action))
+		}
+	case ActionPatch:
+		if _, err := ParsePatch(op.Content); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.content: must be a unified diff or SEARCH/REPLACE blocks for action %q: %v", field, op.Action, err))
+		}
+	case ActionDelete:
+	case ActionRename:
+		if problem := validatePath(op.To); problem != "" {
+			problems = append(problems, fmt.Sprintf("%s.to: %s for action %q", field, problem, op.Action))
+		} else if path.Clean(op.To) == path.Clean(op.Path) {
+			problems = append(problems, fmt.Sprintf("%s.to: must differ from the path", field))
+		}
+	case ActionChmod:
+		if op.Mode == "" {
+			problems = append(problems, fmt.Sprintf("%s.mode: is required for action %q", field, op.Action))
+		} else if _, err := ParseMode(op.Mode); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.mode: %v", field, err))
+		}
+	case "":
+		problems = append(problems, field+".action: is required")
+	default:
+		problems = append(problems, fmt.Sprintf("%s.action: %q must be one of %q, %q, %q, %q, %q, %q", field, op.Action,
+			ActionCreate, ActionUpdate, ActionPatch, ActionDelete, ActionRename, ActionChmod))
+	}
+	return problems
+}
+
+// validatePath describes what is wrong with an operation's path, if anything.
+func validatePath(name string) string {
+	switch {
+	case name == "":
+		return "is required"
+	case strings.HasPrefix(name, "/") || strings.Contains(name, "\\"):
+		return fmt.Sprintf("%q must be a relative path using forward slashes", name)
+	case path.Clean(name) == ".." || strings.HasPrefix(path.Clean(name), "../"):
+		return fmt.Sprintf("%q must not leave the project", name)
+	}
+	return ""
+}
+
+// extractJSONObject returns the outermost {...} in text, ignoring fences and prose.
+func extractJSONObject(text string) string {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return ""
+	}
+	return text[start : end+1]
+}
@@ -0,0 +1,147 @@
+package fs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStructuredOutput(t *testing.T) {
+	// Test case 1: A fenced, valid document
+	text := "Here you go:\n```json\n" +
+		`{"files": [{"path": "cmd/main.go", "action": "create", "content": "package main\n", "rationale": "entry point"}]}` +
+		"\n```\n"
+	expected := []FileOperation{{Path: "cmd/main.go", Action: ActionCreate, Content: "package main\n", Rationale: "entry point"}}
+	actual, err := ParseStructuredOutput(text)
+	if err != nil {
+		t.Fatalf("ParseStructuredOutput returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	// Test case 2: Every schema violation is reported
+	text = `{"files": [{"path": "../outside.go", "action": "create", "content": "x"}, {"path": "a.go", "action": "explode"}, {"action": "update"}]}`
+	_, err = ParseStructuredOutput(text)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("Expected a SchemaError, got %v", err)
+	}
+	if len(schemaErr.Problems) != 4 {
+		t.Errorf("Expected 4 problems, got %d: %v", len(schemaErr.Problems), schemaErr.Problems)
+	}
+	if !strings.Contains(err.Error(), "files[1].action") {
+		t.Errorf("Expected the error to point at files[1].action, got %v", err)
+	}
+
+	// Test case 3: Unknown keys and prose-only answers are rejected
+	for _, text := range []string{`{"files": [], "notes": "extra"}`, "I could not do this."} {
+		if _, err := ParseStructuredOutput(text); !errors.As(err, &schemaErr) {
+			t.Errorf("Expected a SchemaError for %q, got %v", text, err)
+		}
+	}
+}
+
+func TestOperationsFromBlocks(t *testing.T) {
+	// Test case 1: Full files become updates, and blocks without a path are skipped
+	blocks := []CodeBlock{{FilePath: "a.go", Content: "a"}, {Content: "orphan"}}
+	ops, skipped, _ := OperationsFromBlocks(blocks)
+	if len(ops) != 1 || ops[0].Path != "a.go" || ops[0].Action != ActionUpdate {
+		t.Errorf("Expected one update for a.go, got %v", ops)
+	}
+	if len(skipped) != 1 || skipped[0].Content != "orphan" {
+		t.Errorf("Expected the block without a path to be skipped, got %v", skipped)
+	}
+
+	// Test case 2: A multi-file diff becomes one patch per file
+	diff := "--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n--- a/c.go\n+++ b/c.go\n@@ -1 +1 @@\n-c\n+d\n"
+	ops, _, warnings := OperationsFromBlocks([]CodeBlock{{Language: "diff", Content: diff}})
+	if len(ops) != 2 || ops[0].Path != "a.go" || ops[1].Path != "c.go" || ops[1].Action != ActionPatch || len(warnings) != 0 {
+		t.Fatalf("Expected patches for a.go and c.go, got %v (%v)", ops, warnings)
+	}
+	if patched, rejected, err := ApplyPatch("c\n", ops[1].Content); err != nil || patched != "d\n" || len(rejected) != 0 {
+		t.Errorf("Expected the c.go patch to apply on its own, got %q (%v, %v)", patched, rejected, err)
+	}
+
+	// Test case 3: A SEARCH/REPLACE block uses its code block's path
+	ops, _, _ = OperationsFromBlocks([]CodeBlock{{FilePath: "b.go", Content: "<<<<<<< SEARCH\nx\n=======\ny\n>>>>>>> REPLACE\n"}})
+	if len(ops) != 1 || ops[0].Path != "b.go" || ops[0].Action != ActionPatch {
+		t.Errorf("Expected a patch for b.go, got %v", ops)
+	}
+
+	// Test case 4: A diff against /dev/null is a patch that creates its file
+	diff = "--- /dev/null\n+++ b/new.go\n@@ -0,0 +1,2 @@\n+package main\n+\n"
+	ops, _, _ = OperationsFromBlocks([]CodeBlock{{Language: "diff", Content: diff}})
+	if len(ops) != 1 || ops[0].Path != "new.go" || ops[0].Action != ActionPatch || ops[0].NeedsExistingFile() {
+		t.Fatalf("Expected a patch for new.go that doesn't need the file to exist, got %v", ops)
+	}
+	if created, rejected, err := ApplyPatch("", ops[0].Content); err != nil || created != "package main\n\n" || len(rejected) != 0 {
+		t.Errorf("Expected the patch to create new.go, got %q (%v, %v)", created, rejected, err)
+	}
+	for _, action := range []Action{ActionDelete, ActionRename, ActionChmod} {
+		if !(FileOperation{Action: action}).NeedsExistingFile() {
+			t.Errorf("Expected %s to need an existing file", action)
+		}
+	}
+
+	// Test case 5: Files that only contain diff text are written whole, whatever their fence
+	readme := "# Patches\n\nEdits look like this:\n\n```diff\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n```\n"
+	fixture := "package fs\n\nconst edit = `\n<<<<<<< SEARCH\nx\n=======\ny\n>>>>>>> REPLACE\n@@ -1 +1 @@\n`\n"
+	blocks, _ = ParseCodeBlocks("Docs:\n\n````markdown docs/patches.md\n" + readme + "````\n")
+	blocks = append(blocks, []CodeBlock{
+		{FilePath: "pkg/fs/edit_test.go", Language: "go", Content: fixture},
+		{FilePath: "docs/example.md", Language: "diff", Content: readme},
+	}...)
+	ops, skipped, warnings = OperationsFromBlocks(blocks)
+	if len(blocks) != 3 || len(ops) != 3 || len(skipped) != 0 || len(warnings) != 0 {
+		t.Fatalf("Expected three whole-file updates, got %v (%v, %v)", ops, skipped, warnings)
+	}
+	for i, op := range ops {
+		if op.Action != ActionUpdate || op.Content != blocks[i].Content {
+			t.Errorf("Expected %s to be updated with its content, got %+v", blocks[i].FilePath, op)
+		}
+	}
+
+	// Test case 6: Edits may name their file before the first SEARCH block or open with a hunk
+	for _, content := range []string{"b.go\n<<<<<<< SEARCH\nx\n=======\ny\n>>>>>>> REPLACE\n", "@@ -1 +1 @@\n-x\n+y\n"} {
+		if ops, _, _ := OperationsFromBlocks([]CodeBlock{{FilePath: "b.go", Content: content}}); len(ops) != 1 || ops[0].Action != ActionPatch {
+			t.Errorf("Expected a patch for %q, got %v", content, ops)
+		}
+	}
+}
+
+func TestParseFileCommands(t *testing.T) {
+	markdown := "Cleaning up:\n\n```pdt-ops\n# old code\ndelete legacy/old.go\nmv a.go -> pkg/a.go\nchmod 755 scripts/run.sh\nchmod rwx x.sh\nrename ../x y\n```\n"
+	blocks, warnings := ParseCodeBlocks(markdown)
+	if len(warnings) != 0 {
+		t.Fatalf("Expected no warnings for an operations block, got %v", warnings)
+	}
+
+	// Test case 1: Each supported line becomes an operation
+	ops, _, warnings := OperationsFromBlocks(blocks)
+	expected := []FileOperation{
+		{Path: "legacy/old.go", Action: ActionDelete},
+		{Path: "a.go", Action: ActionRename, To: "pkg/a.go"},
+		{Path: "scripts/run.sh", Action: ActionChmod, Mode: "755"},
+	}
+	if !reflect.DeepEqual(expected, ops) {
+		t.Errorf("Expected %v, got %v", expected, ops)
+	}
+
+	// Test case 2: Invalid lines are reported with their line numbers
+	if len(warnings) != 2 || warnings[0].Line != 8 || warnings[1].Line != 9 {
+		t.Errorf("Expected warnings for lines 8 and 9, got %v", warnings)
+	}
+
+	// Test case 3: Structured output validates the new actions
+	text := `{"files": [{"path": "a.go", "action": "rename", "to": "b.go"}, {"path": "x.sh", "action": "chmod", "mode": "0755"}, {"path": "c.go", "action": "rename"}, {"path": "d.sh", "action": "chmod", "mode": "999"}]}`
+	_, err := ParseStructuredOutput(text)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || len(schemaErr.Problems) != 2 {
+		t.Fatalf("Expected 2 problems, got %v", err)
+	}
+	if mode, err := ParseMode("0755"); err != nil || mode != 0755 {
+		t.Errorf("Expected mode 0755, got %v (%v)", mode, err)
+	}
+}
@@ -0,0 +1,447 @@
+package fs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Hunk is one edit to a file: the lines to find and the lines to put in their place.
+type Hunk struct {
+	Old []string
+	New []string
+	// Line is the 1-based line where Old is expected to start, from a unified diff's
+	// @@ header; 0 when unknown.
+	Line int
+	// Leading and Trailing count the unchanged context lines at the start and end of
+	// Old, which may be dropped when the hunk doesn't match exactly.
+	Leading  int
+	Trailing int
+	// Text is the hunk as the AI wrote it.
+	Text string
+}
+
+// FilePatch is the hunks for one file. Path is empty when the edit didn't name its
+// file and the enclosing code block has to.
+type FilePatch struct {
+	Path  string
+	Hunks []Hunk
+	// Text is the part of the edit that applies to this file.
+	Text string
+}
+
+// RejectedHunk is a hunk that could not be applied.
+type RejectedHunk struct {
+	Hunk   Hunk
+	Reason string
+}
+
+func (r RejectedHunk) String() string {
+	return fmt.Sprintf("%s:\n%s", r.Reason, strings.TrimRight(r.Hunk.Text, "\n"))
+}
+
+var (
+	searchMarker  = regexp.MustCompile(`^<{5,9} ?SEARCH\s*$`)
+	dividerMarker = regexp.MustCompile(`^={5,9}\s*$`)
+	replaceMarker = regexp.MustCompile(`^>{5,9} ?REPLACE\s*$`)
+	hunkHeader    = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+)
+
+// IsPatch reports whether text is an edit in unified diff or SEARCH/REPLACE format
+// rather than the full content of a file: whether it opens with a diff's file or hunk
+// header, or with a SEARCH block that may follow the path of the file it edits. Files
+// that only contain diff text further down, like docs and test fixtures, aren't edits.
+func IsPatch(text string) bool {
+	lines := splitLines(text)
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return false
+	}
+	first := strings.TrimRight(lines[0], "\r")
+	second := ""
+	if len(lines) > 1 {
+		second = strings.TrimRight(lines[1], "\r")
+	}
+	switch {
+	case searchMarker.MatchString(first), strings.HasPrefix(first, "diff --git "):
+		return true
+	case strings.HasPrefix(first, "@@ ") || first == "@@":
+		return true
+	case strings.HasPrefix(first, "--- ") && strings.HasPrefix(second, "+++ "):
+		return true
+	}
+	return isPath(strings.Trim(strings.TrimSpace(first), "`*")) && searchMarker.MatchString(second)
+}
+
+// ParsePatch parses a unified diff or a set of SEARCH/REPLACE blocks.
+func ParsePatch(text string) ([]FilePatch, error) {
+	for _, line := range strings.Split(text, "\n") {
+		if searchMarker.MatchString(strings.TrimRight(line, "\r")) {
+			return ParseSearchReplace(text)
+		}
+	}
+	return ParseUnifiedDiff(text)
+}
+
+// ParseUnifiedDiff parses a unified diff, which may cover several files. File headers
+// are optional, and hunk line counts are ignored because AI-written diffs often get
+// them wrong; a hunk runs until the next hunk or file header.
+func ParseUnifiedDiff(text string) ([]FilePatch, error) {
+	lines := splitLines(text)
+	var patches []FilePatch
+	var current *FilePatch
+	var hunk *Hunk
+	sectionStart := 0
+
+	flushHunk := func() {
+		if hunk != nil {
+			trimHunkContext(hunk)
+			current.Hunks = append(current.Hunks, *hunk)
+			hunk = nil
+		}
+	}
+	flushFile := func(end int) {
+		flushHunk()
+		if current != nil {
+			current.Text = strings.Join(lines[sectionStart:end], "\n") + "\n"
+			patches = append(patches, *current)
+			current = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			flushFile(i)
+			sectionStart = i
+			oldPath := diffPath(line[4:])
+			newPath := diffPath(strings.TrimRight(lines[i+1], "\r")[4:])
+			if newPath == "/dev/null" {
+				return nil, fmt.Errorf("the diff deletes %s, which can't be done with an edit", oldPath)
+			}
+			current = &FilePatch{Path: newPath}
+			i++
+		case strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "index "):
+			// git metadata; the file is named by the ---/+++ lines that follow.
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				sectionStart = i
+				current = &FilePatch{}
+			}
+			flushHunk()
+			hunk = &Hunk{}
+			if m := hunkHeader.FindStringSubmatch(line); m != nil {
+				hunk.Line, _ = strconv.Atoi(m[1])
+				if m[2] == "0" {
+					// "-0,0" inserts before line 1; "-5,0" inserts after line 5.
+					hunk.Line++
+				}
+			}
+		case hunk != nil:
+			hunk.Text += line + "\n"
+			switch {
+			case strings.HasPrefix(line, "+"):
+				hunk.New = append(hunk.New, line[1:])
+			case strings.HasPrefix(line, "-"):
+				hunk.Old = append(hunk.Old, line[1:])
+			case strings.HasPrefix(line, "\\"):
+				// "\ No newline at end of file"
+			default:
+				// Context; a blank line is often missing its leading space.
+				context := strings.TrimPrefix(line, " ")
+				hunk.Old = append(hunk.Old, context)
+				hunk.New = append(hunk.New, context)
+			}
+		}
+	}
+	flushFile(len(lines))
+
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no hunks found in the diff")
+	}
+	for _, patch := range patches {
+		if len(patch.Hunks) == 0 {
+			return nil, fmt.Errorf("the diff for %s has no hunks", patch.Path)
+		}
+	}
+	return patches, nil
+}
+
+// trimHunkContext records the hunk's leading and trailing context and drops the
+// blank context lines that trail most AI-written hunks.
+func trimHunkContext(h *Hunk) {
+	for len(h.Old) > 0 && len(h.New) > 0 && h.Old[len(h.Old)-1] == "" && h.New[len(h.New)-1] == "" &&
+		strings.HasSuffix(h.Text, "\n\n") {
+		h.Old, h.New = h.Old[:len(h.Old)-1], h.New[:len(h.New)-1]
+		h.Text = strings.TrimSuffix(h.Text, "\n")
+	}
+	for h.Leading < len(h.Old) && h.Leading < len(h.New) && h.Old[h.Leading] == h.New[h.Leading] {
+		h.Leading++
+	}
+	for h.Trailing < len(h.Old)-h.Leading && h.Trailing < len(h.New)-h.Leading &&
+		h.Old[len(h.Old)-1-h.Trailing] == h.New[len(h.New)-1-h.Trailing] {
+		h.Trailing++
+	}
+}
+
+// diffPath extracts a path from a ---/+++ header, dropping git's a/ and b/ prefixes
+// and any timestamp.
+func diffPath(header string) string {
+	if i := strings.Index(header, "\t"); i >= 0 {
+		header = header[:i]
+	}
+	header = strings.TrimSpace(header)
+	if header == "/dev/null" {
+		return header
+	}
+	if strings.HasPrefix(header, "a/") || strings.HasPrefix(header, "b/") {
+		header = header[2:]
+	}
+	return cleanPath(header)
+}
+
+// ParseSearchReplace parses SEARCH/REPLACE blocks:
+//
+//	path/to/file.go
+//	<<<<<<< SEARCH
+//	lines to find
+//	=======
+//	lines to put in their place
+//	>>>>>>> REPLACE
+//
+// The path line is optional; blocks without one apply to the enclosing code block's file.
+func ParseSearchReplace(text string) ([]FilePatch, error) {
+	lines := splitLines(text)
+	var patches []FilePatch
+	index := map[string]int{}
+	path := ""
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if !searchMarker.MatchString(line) {
+			if candidate := strings.Trim(strings.TrimSpace(line), "`*"); isPath(candidate) {
+				path = cleanPath(candidate)
+			}
+			continue
+		}
+
+		start := i
+		hunk := Hunk{}
+		i++
+		for ; i < len(lines) && !dividerMarker.MatchString(strings.TrimRight(lines[i], "\r")); i++ {
+			hunk.Old = append(hunk.Old, strings.TrimRight(lines[i], "\r"))
+		}
+		if i == len(lines) {
+			return nil, fmt.Errorf("SEARCH block starting at line %d has no ======= divider", start+1)
+		}
+		i++
+		for ; i < len(lines) && !replaceMarker.MatchString(strings.TrimRight(lines[i], "\r")); i++ {
+			hunk.New = append(hunk.New, strings.TrimRight(lines[i], "\r"))
+		}
+		if i == len(lines) {
+			return nil, fmt.Errorf("SEARCH block starting at line %d has no >>>>>>> REPLACE marker", start+1)
+		}
+		hunk.Text = strings.Join(lines[start:i+1], "\n") + "\n"
+
+		n, ok := index[path]
+		if !ok {
+			n = len(patches)
+			index[path] = n
+			patches = append(patches, FilePatch{Path: path})
+		}
+		patches[n].Hunks = append(patches[n].Hunks, hunk)
+		patches[n].Text += hunk.Text
+	}
+
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no SEARCH/REPLACE blocks found")
+	}
+	for i := range patches {
+		if patches[i].Path != "" {
+			patches[i].Text = patches[i].Path + "\n" + patches[i].Text
+		}
+	}
+	return patches, nil
+}
+
+// ApplyHunks applies hunks to content in order. Each hunk is matched exactly first,
+// then ignoring trailing whitespace, then ignoring indentation (re-indenting the new
+// lines to match the file), and finally, for diffs, with up to two lines of context
+// dropped from each end. When a hunk matches in several places, the one nearest the
+// line its diff header names wins. Hunks that don't match are returned instead of
+// being applied.
+func ApplyHunks(content string, hunks []Hunk) (string, []RejectedHunk) {
+	lines := splitLines(content)
+	var rejected []RejectedHunk
+	offset := 0
+
+	for _, h := range hunks {
+		if len(h.Old) == 0 {
+			// Earlier hunks can shift the header's line out of the file; clamp it.
+			at := len(lines)
+			if h.Line > 0 {
+				at = h.Line - 1 + offset
+				if at < 0 {
+					at = 0
+				} else if at > len(lines) {
+					at = len(lines)
+				}
+			}
+			lines = splice(lines, at, 0, h.New)
+			offset += len(h.New)
+			continue
+		}
+
+		start, matched, replacement, ok := locateHunk(lines, h, offset)
+		if !ok {
+			rejected = append(rejected, RejectedHunk{Hunk: h, Reason: "the lines to replace were not found"})
+			continue
+		}
+		lines = splice(lines, start, matched, replacement)
+		offset += len(replacement) - matched
+	}
+
+	if len(lines) == 0 {
+		return "", rejected
+	}
+	return strings.Join(lines, "\n") + "\n", rejected
+}
+
+// locateHunk finds where h applies and returns the start, the number of file lines it
+// replaces and the lines to put there.
+func locateHunk(lines []string, h Hunk, offset int) (int, int, []string, bool) {
+	hint := -1
+	if h.Line > 0 {
+		hint = h.Line - 1 + offset
+	}
+
+	for _, compare := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		if start, ok := findLines(lines, h.Old, hint, compare); ok {
+			return start, len(h.Old), h.New, true
+		}
+	}
+
+	trimmed := func(a, b string) bool { return strings.TrimSpace(a) == strings.TrimSpace(b) }
+	if start, ok := findLines(lines, h.Old, hint, trimmed); ok {
+		return start, len(h.Old), reindent(h.Old, lines[start:start+len(h.Old)], h.New), true
+	}
+
+	for fuzz := 1; fuzz <= 2; fuzz++ {
+		lead, trail := fuzz, fuzz
+		if lead > h.Leading {
+			lead = h.Leading
+		}
+		if trail > h.Trailing {
+			trail = h.Trailing
+		}
+		if lead+trail == 0 || lead+trail >= len(h.Old) {
+			continue
+		}
+		old := h.Old[lead : len(h.Old)-trail]
+		fuzzyHint := hint
+		if hint >= 0 {
+			fuzzyHint = hint + lead
+		}
+		if start, ok := findLines(lines, old, fuzzyHint, trimmed); ok {
+			replacement := h.New[lead : len(h.New)-trail]
+			return start, len(old), reindent(old, lines[start:start+len(old)], replacement), true
+		}
+	}
+	return 0, 0, nil, false
+}
+
+// findLines returns the start of the match of want in lines nearest to hint, or the
+// first match when there is no hint.
+func findLines(lines []string, want []string, hint int, equal func(a, b string) bool) (int, bool) {
+	best, found := 0, false
+	for start := 0; start+len(want) <= len(lines); start++ {
+		match := true
+		for i := range want {
+			if !equal(lines[start+i], want[i]) {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		if hint < 0 {
+			return start, true
+		}
+		if !found || distance(start, hint) < distance(best, hint) {
+			best, found = start, true
+		}
+	}
+	return best, found
+}
+
+// reindent shifts replacement by the indentation the file adds to (or removes from)
+// the hunk's lines, so an edit written with the wrong indentation still fits. Lines
+// the edit keeps from the matched text keep the file's own indentation.
+func reindent(old []string, actual []string, replacement []string) []string {
+	want, have := "", ""
+	for i := range old {
+		if strings.TrimSpace(old[i]) != "" {
+			want, have = leadingSpace(old[i]), leadingSpace(actual[i])
+			break
+		}
+	}
+	if want == have {
+		return replacement
+	}
+
+	kept := map[string]string{}
+	for i := range old {
+		kept[old[i]] = actual[i]
+	}
+	var result []string
+	for _, line := range replacement {
+		if original, ok := kept[line]; ok {
+			result = append(result, original)
+			continue
+		}
+		switch {
+		case strings.TrimSpace(line) == "":
+			result = append(result, line)
+		case strings.HasPrefix(have, want):
+			result = append(result, have[len(want):]+line)
+		case strings.HasPrefix(want, have) && strings.HasPrefix(line, want[len(have):]):
+			result = append(result, line[len(want)-len(have):])
+		default:
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+func splice(lines []string, at int, remove int, insert []string) []string {
+	result := append([]string{}, lines[:at]...)
+	result = append(result, insert...)
+	return append(result, lines[at+remove:]...)
+}
+
+// splitLines splits text into lines without a trailing empty line.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
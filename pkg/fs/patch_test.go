@@ -0,0 +1,87 @@
+package fs
+
+import (
+	"strings"
+	"testing"
+)
+
+const patchOriginal = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("one")
+	fmt.Println("two")
+}
+
+func helper() {
+	fmt.Println("one")
+}
+`
+
+func TestApplyUnifiedDiff(t *testing.T) {
+	// Test case 1: The hunk header picks the nearer of two identical matches
+	diff := "--- a/main.go\n+++ b/main.go\n@@ -10,3 +10,3 @@\n func helper() {\n-\tfmt.Println(\"one\")\n+\tfmt.Println(\"uno\")\n }\n"
+	patched, rejected, err := ApplyPatch(patchOriginal, diff)
+	if err != nil || len(rejected) != 0 {
+		t.Fatalf("Expected the diff to apply, got %v (%v)", rejected, err)
+	}
+	if !strings.Contains(patched, "func helper() {\n\tfmt.Println(\"uno\")") || !strings.Contains(patched, "func main() {\n\tfmt.Println(\"one\")") {
+		t.Errorf("Expected only helper to change, got:\n%s", patched)
+	}
+
+	// Test case 2: Wrong line numbers and a stale context line are tolerated
+	diff = "@@ -1,4 +1,4 @@\n func main() {\n-\tfmt.Println(\"two\")\n+\tfmt.Println(\"dos\")\n }\n// stale context\n"
+	patched, rejected, err = ApplyPatch(patchOriginal, diff)
+	if err != nil || len(rejected) != 0 {
+		t.Fatalf("Expected the fuzzy diff to apply, got %v (%v)", rejected, err)
+	}
+	if !strings.Contains(patched, "\tfmt.Println(\"dos\")\n}") {
+		t.Errorf("Expected two to be replaced, got:\n%s", patched)
+	}
+
+	// Test case 3: Hunks that match nothing are rejected, others still apply
+	diff = "@@ -1 +1 @@\n-package main\n+package app\n@@ -20 +20 @@\n-no such line\n+replacement\n"
+	patched, rejected, err = ApplyPatch(patchOriginal, diff)
+	if err != nil {
+		t.Fatalf("ApplyPatch returned an error: %v", err)
+	}
+	if !strings.HasPrefix(patched, "package app\n") || len(rejected) != 1 || !strings.Contains(rejected[0].String(), "-no such line") {
+		t.Errorf("Expected one applied and one rejected hunk, got %v:\n%s", rejected, patched)
+	}
+
+	// Test case 4: A diff against /dev/null creates the file
+	patched, rejected, err = ApplyPatch("", "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+hello\n+world\n")
+	if err != nil || len(rejected) != 0 || patched != "hello\nworld\n" {
+		t.Errorf("Expected a new file, got %q (%v, %v)", patched, rejected, err)
+	}
+
+	// Test case 5: An insertion whose line a deleting hunk moved out of the file doesn't crash
+	patched, rejected, err = ApplyPatch("a\nb\nc\nd\ne\n", "@@ -1,5 +1,0 @@\n-a\n-b\n-c\n-d\n-e\n@@ -3,0 +1,1 @@\n+f\n")
+	if err != nil || len(rejected) != 0 || patched != "f\n" {
+		t.Errorf("Expected only the inserted line to remain, got %q (%v, %v)", patched, rejected, err)
+	}
+}
+
+func TestApplySearchReplace(t *testing.T) {
+	// Test case 1: Indentation differences are matched and the replacement re-indented
+	edit := "<<<<<<< SEARCH\nfmt.Println(\"two\")\n}\n=======\nfmt.Println(\"two\")\nfmt.Println(\"three\")\n}\n>>>>>>> REPLACE\n"
+	patched, rejected, err := ApplyPatch(patchOriginal, edit)
+	if err != nil || len(rejected) != 0 {
+		t.Fatalf("Expected the edit to apply, got %v (%v)", rejected, err)
+	}
+	if !strings.Contains(patched, "\tfmt.Println(\"two\")\n\tfmt.Println(\"three\")\n}") {
+		t.Errorf("Expected a re-indented insertion, got:\n%s", patched)
+	}
+
+	// Test case 2: Paths before the markers group hunks by file
+	patches, err := ParseSearchReplace("a.go\n<<<<<<< SEARCH\nx\n=======\ny\n>>>>>>> REPLACE\nb.go\n<<<<<<< SEARCH\nx\n=======\nz\n>>>>>>> REPLACE\n")
+	if err != nil || len(patches) != 2 || patches[0].Path != "a.go" || patches[1].Path != "b.go" {
+		t.Errorf("Expected edits for a.go and b.go, got %v (%v)", patches, err)
+	}
+
+	// Test case 3: An unterminated block is an error
+	if _, err := ParseSearchReplace("<<<<<<< SEARCH\nx\n=======\ny\n"); err == nil {
+		t.Errorf("Expected an error for a block without REPLACE")
+	}
+}
@@ -0,0 +1,276 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDeny lists the paths AI-generated writes never touch unless a rule is
+// removed: version control, secrets, pdt's own state and dependency lockfiles.
+func DefaultDeny() []string {
+	return []string{
+		".git", ".hg", ".svn",
+		".env", ".env.*",
+		".pdt",
+		"package-lock.json", "yarn.lock", "pnpm-lock.yaml", "bun.lockb",
+		"go.sum", "Cargo.lock", "poetry.lock", "Pipfile.lock", "Gemfile.lock", "composer.lock",
+	}
+}
+
+// BlockedError is returned for a write the sandbox refuses.
+type BlockedError struct {
+	Path   string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("refusing to write %s: %s", e.Path, e.Reason)
+}
+
+// Sandbox confines writes to a project root and filters them through allow and deny
+// glob lists. Patterns use forward slashes and are matched against paths relative to
+// the root. A pattern without a slash matches a file or directory name at any depth,
+// as in .gitignore; "**" matches any number of directories.
+type Sandbox struct {
+	root string
+	// Allow, when not empty, limits writes to paths matching one of its patterns.
+	Allow []string
+	// Deny blocks paths matching any of its patterns, even when they are allowed.
+	Deny []string
+}
+
+// NewSandbox returns a sandbox rooted at root with the default deny list.
+func NewSandbox(root string) (*Sandbox, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving project root %s: %w", root, err)
+	}
+	return &Sandbox{root: resolved, Deny: DefaultDeny()}, nil
+}
+
+// Root returns the resolved project root.
+func (s *Sandbox) Root() string { return s.root }
+
+// SetRules adds deny patterns and sets the allow list. A deny pattern starting with
+// "!" removes that pattern instead, e.g. "!go.sum" lets the AI update go.sum.
+func (s *Sandbox) SetRules(allow []string, deny []string) {
+	s.Allow = allow
+	for _, pattern := range deny {
+		if strings.HasPrefix(pattern, "!") {
+			var kept []string
+			for _, existing := range s.Deny {
+				if existing != pattern[1:] {
+					kept = append(kept, existing)
+				}
+			}
+			s.Deny = kept
+			continue
+		}
+		s.Deny = append(s.Deny, pattern)
+	}
+}
+
+// Resolve returns the absolute path that writing name under base would touch, after
+// following any symlinks, or a *BlockedError if the sandbox forbids it. name comes
+// from the AI and must be relative; base is a directory chosen by pdt.
+func (s *Sandbox) Resolve(base string, name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, 0) {
+		return "", &BlockedError{Path: name, Reason: "the path is empty or invalid"}
+	}
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) || filepath.VolumeName(name) != "" {
+		return "", &BlockedError{Path: name, Reason: "absolute paths are not allowed"}
+	}
+
+	abs, err := filepath.Abs(filepath.Join(base, name))
+	if err != nil {
+		return "", err
+	}
+	resolved, err := resolveExisting(abs)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(s.root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &BlockedError{Path: name, Reason: fmt.Sprintf("it resolves to %s, outside the project root %s", resolved, s.root)}
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		return "", &BlockedError{Path: name, Reason: "it is the project root"}
+	}
+
+	for _, pattern := range s.Deny {
+		if MatchGlob(pattern, rel) {
+			return "", &BlockedError{Path: name, Reason: fmt.Sprintf("%s matches the deny rule %q", rel, pattern)}
+		}
+	}
+	if len(s.Allow) > 0 {
+		allowed := false
+		for _, pattern := range s.Allow {
+			if MatchGlob(pattern, rel) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", &BlockedError{Path: name, Reason: fmt.Sprintf("%s doesn't match any allow rule (%s)", rel, strings.Join(s.Allow, ", "))}
+		}
+	}
+	return resolved, nil
+}
+
+// ReadFile reads the current content of name under base. A file that doesn't exist
+// yet reads as empty.
+func (s *Sandbox) ReadFile(base string, name string) (string, []byte, error) {
+	fullPath, err := s.Resolve(base, name)
+	if err != nil {
+		return "", nil, err
+	}
+	content, err := os.ReadFile(fullPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, err
+	}
+	return fullPath, content, nil
+}
+
+// WriteFile writes content to name under base, creating directories as needed, and
+// returns the path it wrote.
+func (s *Sandbox) WriteFile(base string, name string, content []byte) (string, error) {
+	fullPath, err := s.Resolve(base, name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", fmt.Errorf("error creating directory for %s: %w", fullPath, err)
+	}
+	if err := WriteFileAtomic(fullPath, content, 0644); err != nil {
+		return "", err
+	}
+	return fullPath, nil
+}
+
+// WriteFileAtomic writes content to a temporary file next to path and renames it into
+// place, so readers see either the old content or the new, never a partial write. An
+// existing file keeps its permissions; a new one gets perm.
+func WriteFileAtomic(path string, content []byte, perm os.FileMode) error {
+	temp, err := StageFile(path, content, perm)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("error writing to file %s: %w", path, err)
+	}
+	return nil
+}
+
+// StageFile writes content to a temporary file in path's directory, ready to be renamed
+// over path, and returns its name.
+func StageFile(path string, content []byte, perm os.FileMode) (string, error) {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".pdt-*")
+	if err != nil {
+		return "", fmt.Errorf("error writing to file %s: %w", path, err)
+	}
+	_, err = temp.Write(content)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), perm)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return "", fmt.Errorf("error writing to file %s: %w", path, err)
+	}
+	return temp.Name(), nil
+}
+
+// resolveExisting follows symlinks in the longest existing prefix of abs, so a link
+// anywhere on the path, including the file itself, is judged by where it points. A
+// dangling link is judged by the file writing through it would create.
+func resolveExisting(abs string) (string, error) {
+	return resolvePath(abs, 0)
+}
+
+func resolvePath(abs string, depth int) (string, error) {
+	if depth > 40 {
+		return "", fmt.Errorf("too many levels of symbolic links resolving %s", abs)
+	}
+	var missing []string
+	current := abs
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if info, err := os.Lstat(current); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(current)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(current), target)
+			}
+			return resolvePath(filepath.Join(append([]string{target}, missing...)...), depth+1)
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return abs, nil
+		}
+		missing = append([]string{filepath.Base(current)}, missing...)
+		current = parent
+	}
+}
+
+// MatchGlob reports whether a slash-separated relative path matches pattern. A
+// pattern without a slash matches any single path element, so ".git" also covers
+// everything inside a .git directory; "**" matches any number of elements.
+func MatchGlob(pattern string, name string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+	elements := strings.Split(name, "/")
+	if !strings.Contains(pattern, "/") && pattern != "**" {
+		for _, element := range elements {
+			if ok, _ := path.Match(pattern, element); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchElements(strings.Split(pattern, "/"), elements)
+}
+
+// matchElements matches pattern elements against path elements. A match of a
+// directory prefix also covers everything inside it.
+func matchElements(pattern []string, elements []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elements); i++ {
+			if matchElements(pattern[1:], elements[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elements) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], elements[0]); !ok {
+		return false
+	}
+	return matchElements(pattern[1:], elements[1:])
+}
@@ -0,0 +1,74 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSandboxResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	sandbox, err := NewSandbox(root)
+	if err != nil {
+		t.Fatalf("NewSandbox returned an error: %v", err)
+	}
+
+	// Test case 1: Ordinary paths resolve inside the root, relative to the base
+	resolved, err := sandbox.Resolve(filepath.Join(root, "tasks"), "src/main.go")
+	if err != nil || resolved != filepath.Join(sandbox.Root(), "tasks", "src", "main.go") {
+		t.Errorf("Expected a path under the root, got %s (%v)", resolved, err)
+	}
+
+	// Test case 2: Escapes, absolute paths and protected files are blocked
+	for _, name := range []string{"../../.ssh/config", "/etc/passwd", "src/../../x", ".git/config", "app/.env.local", "go.sum", ".pdt/cache/x"} {
+		var blocked *BlockedError
+		if _, err := sandbox.Resolve(root, name); !errors.As(err, &blocked) {
+			t.Errorf("Expected %s to be blocked, got %v", name, err)
+		}
+	}
+
+	// Test case 3: Symlinks are judged by their target
+	if runtime.GOOS != "windows" {
+		os.Symlink(outside, filepath.Join(root, "linked"))
+		os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "file-link"))
+		for _, name := range []string{"linked/x.txt", "file-link"} {
+			if _, err := sandbox.Resolve(root, name); err == nil {
+				t.Errorf("Expected %s to be blocked because it leaves the root", name)
+			}
+		}
+	}
+
+	// Test case 4: Rules can be removed, and an allow list limits writes
+	sandbox.SetRules([]string{"src/**", "*.md"}, []string{"!go.sum", "src/generated"})
+	for name, allowed := range map[string]bool{"go.sum": false, "src/a/b.go": true, "docs/readme.md": true, "src/generated/x.go": false, "cmd/main.go": false} {
+		_, err := sandbox.Resolve(root, name)
+		if allowed != (err == nil) {
+			t.Errorf("Expected %s allowed=%v, got %v", name, allowed, err)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{".git", ".git/objects/ab", true},
+		{".env.*", "config/.env.production", true},
+		{"*.lock", "sub/Cargo.lock", true},
+		{"src/**/*.go", "src/a/b/c.go", true},
+		{"src/**/*.go", "src/c.go", true},
+		{"src/**/*.go", "lib/src/c.go", false},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"docs", "docs/guide/intro.md", true},
+	}
+	for _, test := range tests {
+		if MatchGlob(test.pattern, test.name) != test.match {
+			t.Errorf("Expected MatchGlob(%q, %q) to be %v", test.pattern, test.name, test.match)
+		}
+	}
+}
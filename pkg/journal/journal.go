@@ -0,0 +1,480 @@
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/productdevtool/pdt-cli/pkg/fs"
+)
+
+// manifestName is the file in a generation's directory that describes it.
+const manifestName = "generation.json"
+
+// Generation is one set of files written together, and what they held before.
+type Generation struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Files   []File    `json:"files"`
+	// Dirs are the directories created for new files, parents first.
+	Dirs []string `json:"dirs,omitempty"`
+	// Undone is when the generation was undone, if it has been.
+	Undone *time.Time `json:"undone,omitempty"`
+}
+
+// What a generation did to a file.
+const (
+	ActionWrite  = "write"
+	ActionDelete = "delete"
+	ActionChmod  = "chmod"
+)
+
+// File is one file a generation changed. Paths are relative to the project root. A
+// rename is recorded as a write of the new path and a delete of the old one.
+type File struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	// Existed is false for a file the generation created.
+	Existed bool `json:"existed"`
+	// Mode is the file's permissions before the generation.
+	Mode os.FileMode `json:"mode,omitempty"`
+	// Backup names the copy of the previous content in the generation's directory.
+	Backup string `json:"backup,omitempty"`
+	// Hash is the SHA-256 of the content the generation left, used to spot later
+	// edits. It is empty for a deleted file.
+	Hash string `json:"hash,omitempty"`
+}
+
+// ModifiedError is returned when undoing a generation would discard changes made to
+// its files since it was written.
+type ModifiedError struct {
+	Paths []string
+}
+
+func (e *ModifiedError) Error() string {
+	return fmt.Sprintf("%s changed since the generation was written", strings.Join(e.Paths, ", "))
+}
+
+// Journal records generations under Dir so they can be undone.
+type Journal struct {
+	Dir string
+	// Root is the project root that file paths are relative to.
+	Root string
+	// Keep is how many generations are kept; older ones are pruned. 0 keeps them all.
+	Keep int
+}
+
+// Transaction stages the writes of one generation so that they are applied together.
+type Transaction struct {
+	journal *Journal
+	gen     Generation
+	staged  []stagedFile
+}
+
+type stagedFile struct {
+	file     File
+	full     string
+	previous []byte
+	// temp holds the new content of a write; mode is the new mode of a chmod.
+	temp string
+	mode os.FileMode
+}
+
+// Begin starts a generation for command.
+func (j *Journal) Begin(command string) *Transaction {
+	return &Transaction{journal: j, gen: Generation{Time: time.Now().UTC(), Command: command}}
+}
+
+// Stage writes content to a temporary file next to fullPath, which must be inside the
+// project root, and snapshots the file's current content. Nothing is visible until
+// Commit.
+func (t *Transaction) Stage(fullPath string, content []byte) error {
+	return t.stageWrite(fullPath, content, 0644)
+}
+
+// Delete stages the removal of fullPath.
+func (t *Transaction) Delete(fullPath string) error {
+	staged, err := t.snapshot(fullPath, ActionDelete)
+	if err != nil {
+		return err
+	}
+	if !staged.file.Existed {
+		return fmt.Errorf("can't delete %s: it doesn't exist", fullPath)
+	}
+	t.add(staged)
+	return nil
+}
+
+// Rename stages moving from to to, keeping its content and permissions.
+func (t *Transaction) Rename(from string, to string) error {
+	content, err := os.ReadFile(from)
+	if err != nil {
+		return fmt.Errorf("can't rename %s: %w", from, err)
+	}
+	info, err := os.Stat(from)
+	if err != nil {
+		return fmt.Errorf("can't rename %s: %w", from, err)
+	}
+	if err := t.stageWrite(to, content, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return t.Delete(from)
+}
+
+// Chmod stages changing the permissions of fullPath to mode.
+func (t *Transaction) Chmod(fullPath string, mode os.FileMode) error {
+	staged, err := t.snapshot(fullPath, ActionChmod)
+	if err != nil {
+		return err
+	}
+	if !staged.file.Existed {
+		return fmt.Errorf("can't change the mode of %s: it doesn't exist", fullPath)
+	}
+	staged.file.Hash = hash(staged.previous)
+	staged.mode = mode
+	t.add(staged)
+	return nil
+}
+
+func (t *Transaction) stageWrite(fullPath string, content []byte, perm os.FileMode) error {
+	staged, err := t.snapshot(fullPath, ActionWrite)
+	if err != nil {
+		return err
+	}
+	staged.file.Hash = hash(content)
+	if err := t.makeDirs(filepath.Dir(fullPath)); err != nil {
+		return err
+	}
+	if staged.temp, err = fs.StageFile(fullPath, content, perm); err != nil {
+		return err
+	}
+	t.add(staged)
+	return nil
+}
+
+// snapshot records the current content and permissions of fullPath, which must be
+// inside the project root.
+func (t *Transaction) snapshot(fullPath string, action string) (stagedFile, error) {
+	rel, err := filepath.Rel(t.journal.Root, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return stagedFile{}, fmt.Errorf("%s is outside the project root %s", fullPath, t.journal.Root)
+	}
+
+	staged := stagedFile{full: fullPath, file: File{Path: filepath.ToSlash(rel), Action: action, Mode: 0644}}
+	staged.previous, err = os.ReadFile(fullPath)
+	if err == nil {
+		staged.file.Existed = true
+		if info, err := os.Stat(fullPath); err == nil {
+			staged.file.Mode = info.Mode().Perm()
+		}
+	} else if !os.IsNotExist(err) {
+		return stagedFile{}, err
+	}
+	return staged, nil
+}
+
+// add adds a staged change. A file staged twice keeps only its last change.
+func (t *Transaction) add(staged stagedFile) {
+	for i := range t.staged {
+		if t.staged[i].full == staged.full {
+			if t.staged[i].temp != "" {
+				os.Remove(t.staged[i].temp)
+			}
+			t.staged[i] = staged
+			return
+		}
+	}
+	t.staged = append(t.staged, staged)
+}
+
+// makeDirs creates dir and any missing parents, recording the ones it created.
+func (t *Transaction) makeDirs(dir string) error {
+	var missing []string
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil || current == filepath.Dir(current) {
+			break
+		}
+		missing = append([]string{current}, missing...)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory %s: %w", dir, err)
+	}
+	for _, created := range missing {
+		if rel, err := filepath.Rel(t.journal.Root, created); err == nil {
+			t.gen.Dirs = append(t.gen.Dirs, filepath.ToSlash(rel))
+		}
+	}
+	return nil
+}
+
+// Discard removes the staged files and any directories created for them.
+func (t *Transaction) Discard() {
+	for _, s := range t.staged {
+		if s.temp != "" {
+			os.Remove(s.temp)
+		}
+	}
+	removeDirs(t.journal.Root, t.gen.Dirs)
+	t.staged = nil
+}
+
+// Commit records the previous content of every staged file in the journal and then
+// applies the staged changes. If one fails, the changes already applied are undone
+// and nothing is recorded. Committing an empty transaction records nothing.
+func (t *Transaction) Commit() (*Generation, error) {
+	if len(t.staged) == 0 {
+		return nil, nil
+	}
+
+	dir, err := t.journal.create(t.gen.Time)
+	if err != nil {
+		t.Discard()
+		return nil, err
+	}
+	t.gen.ID = filepath.Base(dir)
+	for i := range t.staged {
+		s := &t.staged[i]
+		if s.file.Existed {
+			s.file.Backup = strconv.Itoa(i+1) + ".orig"
+			if err := os.WriteFile(filepath.Join(dir, s.file.Backup), s.previous, 0600); err != nil {
+				t.Discard()
+				os.RemoveAll(dir)
+				return nil, fmt.Errorf("error saving the previous content of %s: %w", s.file.Path, err)
+			}
+		}
+		t.gen.Files = append(t.gen.Files, s.file)
+	}
+	// The manifest is written before any file is replaced, so an interrupted commit
+	// can still be undone.
+	if err := writeManifest(dir, &t.gen); err != nil {
+		t.Discard()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	for i, s := range t.staged {
+		if err := s.apply(); err != nil {
+			for _, done := range t.staged[:i] {
+				restore(done.full, done.file, done.previous)
+			}
+			t.staged = t.staged[i:]
+			t.Discard()
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	t.staged = nil
+
+	t.journal.prune()
+	return &t.gen, nil
+}
+
+// apply makes a staged change.
+func (s stagedFile) apply() error {
+	var err error
+	switch s.file.Action {
+	case ActionDelete:
+		err = os.Remove(s.full)
+	case ActionChmod:
+		err = os.Chmod(s.full, s.mode)
+	default:
+		err = os.Rename(s.temp, s.full)
+	}
+	if err != nil {
+		return fmt.Errorf("error applying %s to %s: %w", s.file.Action, s.full, err)
+	}
+	return nil
+}
+
+// Generations returns the recorded generations, newest first.
+func (j *Journal) Generations() ([]*Generation, error) {
+	entries, err := os.ReadDir(j.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var gens []*Generation
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		gen, err := j.Load(entry.Name())
+		if err != nil {
+			continue
+		}
+		gens = append(gens, gen)
+	}
+	sort.Slice(gens, func(a, b int) bool {
+		if !gens[a].Time.Equal(gens[b].Time) {
+			return gens[a].Time.After(gens[b].Time)
+		}
+		return gens[a].ID > gens[b].ID
+	})
+	return gens, nil
+}
+
+// Latest returns the newest generation that hasn't been undone, or nil if there is none.
+func (j *Journal) Latest() (*Generation, error) {
+	gens, err := j.Generations()
+	if err != nil {
+		return nil, err
+	}
+	for _, gen := range gens {
+		if gen.Undone == nil {
+			return gen, nil
+		}
+	}
+	return nil, nil
+}
+
+// Load reads the generation with the given ID.
+func (j *Journal) Load(id string) (*Generation, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fmt.Errorf("invalid generation ID '%s'", id)
+	}
+	data, err := os.ReadFile(filepath.Join(j.Dir, id, manifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no generation with ID '%s' in %s", id, j.Dir)
+		}
+		return nil, err
+	}
+	var gen Generation
+	if err := json.Unmarshal(data, &gen); err != nil {
+		return nil, fmt.Errorf("error reading generation %s: %w", id, err)
+	}
+	gen.ID = id
+	return &gen, nil
+}
+
+// Undo puts every file of gen back the way it was before gen was written: previous
+// content and permissions are restored, deleted files come back and created files are
+// removed. Unless force is set, it refuses
+// with a *ModifiedError when any of the files changed since.
+func (j *Journal) Undo(gen *Generation, force bool) error {
+	if gen.Undone != nil {
+		return fmt.Errorf("generation %s was already undone at %s", gen.ID, gen.Undone.Local().Format(time.RFC3339))
+	}
+	if !force {
+		var modified []string
+		for _, file := range gen.Files {
+			content, err := os.ReadFile(j.path(file.Path))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			exists := err == nil
+			if exists != (file.Action != ActionDelete) || (exists && hash(content) != file.Hash) {
+				modified = append(modified, file.Path)
+			}
+		}
+		if len(modified) > 0 {
+			return &ModifiedError{Paths: modified}
+		}
+	}
+
+	dir := filepath.Join(j.Dir, gen.ID)
+	var errs []string
+	for _, file := range gen.Files {
+		var previous []byte
+		if file.Existed {
+			var err error
+			if previous, err = os.ReadFile(filepath.Join(dir, file.Backup)); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", file.Path, err))
+				continue
+			}
+		}
+		if err := restore(j.path(file.Path), file, previous); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", file.Path, err))
+		}
+	}
+	removeDirs(j.Root, gen.Dirs)
+	if len(errs) > 0 {
+		return errors.New("error restoring files:\n" + strings.Join(errs, "\n"))
+	}
+
+	now := time.Now().UTC()
+	gen.Undone = &now
+	return writeManifest(dir, gen)
+}
+
+// create makes the directory for a new generation, named after its time.
+func (j *Journal) create(at time.Time) (string, error) {
+	if err := os.MkdirAll(j.Dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating journal directory %s: %w", j.Dir, err)
+	}
+	id := at.Format("20060102-150405")
+	for n := 2; ; n++ {
+		dir := filepath.Join(j.Dir, id)
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("error creating journal entry %s: %w", dir, err)
+		}
+		id = fmt.Sprintf("%s-%d", at.Format("20060102-150405"), n)
+	}
+}
+
+// prune removes the oldest generations beyond Keep.
+func (j *Journal) prune() {
+	if j.Keep <= 0 {
+		return
+	}
+	gens, err := j.Generations()
+	if err != nil {
+		return
+	}
+	for i := j.Keep; i < len(gens); i++ {
+		os.RemoveAll(filepath.Join(j.Dir, gens[i].ID))
+	}
+}
+
+func (j *Journal) path(rel string) string {
+	return filepath.Join(j.Root, filepath.FromSlash(rel))
+}
+
+// restore puts back a file's previous content, or removes it if it didn't exist.
+func restore(fullPath string, file File, previous []byte) error {
+	if !file.Existed {
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := fs.WriteFileAtomic(fullPath, previous, file.Mode); err != nil {
+		return err
+	}
+	return os.Chmod(fullPath, file.Mode)
+}
+
+// removeDirs removes the given directories, deepest first, if they are empty.
+func removeDirs(root string, dirs []string) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(filepath.Join(root, filepath.FromSlash(dirs[i])))
+	}
+}
+
+func writeManifest(dir string, gen *Generation) error {
+	data, err := json.MarshalIndent(gen, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.WriteFileAtomic(filepath.Join(dir, manifestName), append(data, '\n'), 0644)
+}
+
+func hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
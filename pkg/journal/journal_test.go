@@ -0,0 +1,158 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitAndUndo(t *testing.T) {
+	root := t.TempDir()
+	j := &Journal{Dir: filepath.Join(root, ".pdt", "journal"), Root: root}
+	existing := filepath.Join(root, "main.go")
+	created := filepath.Join(root, "pkg", "util", "util.go")
+	os.WriteFile(existing, []byte("old\n"), 0600)
+
+	// Test case 1: Staged files are invisible until the commit
+	tx := j.Begin("code")
+	if err := tx.Stage(existing, []byte("new\n")); err != nil {
+		t.Fatalf("Stage returned an error: %v", err)
+	}
+	if err := tx.Stage(created, []byte("package util\n")); err != nil {
+		t.Fatalf("Stage returned an error: %v", err)
+	}
+	if content, _ := os.ReadFile(existing); string(content) != "old\n" {
+		t.Fatalf("Expected main.go to be unchanged before the commit, got %q", content)
+	}
+	gen, err := tx.Commit()
+	if err != nil {
+		t.Fatalf("Commit returned an error: %v", err)
+	}
+	if content, _ := os.ReadFile(existing); string(content) != "new\n" {
+		t.Errorf("Expected main.go to be written, got %q", content)
+	}
+	if info, _ := os.Stat(existing); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected main.go to keep mode 0600, got %v", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 3 {
+		t.Errorf("Expected no temporary files to be left behind, got %v", entries)
+	}
+
+	// Test case 2: The latest generation is the one just committed
+	latest, err := j.Latest()
+	if err != nil || latest == nil || latest.ID != gen.ID || len(latest.Files) != 2 {
+		t.Fatalf("Expected generation %s with 2 files, got %+v (%v)", gen.ID, latest, err)
+	}
+
+	// Test case 3: Undo refuses to discard later edits unless forced
+	os.WriteFile(existing, []byte("edited\n"), 0600)
+	var modified *ModifiedError
+	if err := j.Undo(latest, false); !errors.As(err, &modified) || len(modified.Paths) != 1 || modified.Paths[0] != "main.go" {
+		t.Fatalf("Expected a ModifiedError for main.go, got %v", err)
+	}
+
+	// Test case 4: Undo restores old content and removes created files and directories
+	if err := j.Undo(latest, true); err != nil {
+		t.Fatalf("Undo returned an error: %v", err)
+	}
+	if content, _ := os.ReadFile(existing); string(content) != "old\n" {
+		t.Errorf("Expected main.go to be restored, got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pkg")); !os.IsNotExist(err) {
+		t.Errorf("Expected the created pkg directory to be removed, got %v", err)
+	}
+	if latest, _ := j.Latest(); latest != nil {
+		t.Errorf("Expected no generation left to undo, got %s", latest.ID)
+	}
+}
+
+func TestDiscardAndFailedCommit(t *testing.T) {
+	root := t.TempDir()
+	j := &Journal{Dir: filepath.Join(root, ".pdt", "journal"), Root: root}
+	first := filepath.Join(root, "a.txt")
+	os.WriteFile(first, []byte("a\n"), 0644)
+
+	// Test case 1: Discard leaves the tree untouched
+	tx := j.Begin("doc")
+	tx.Stage(first, []byte("changed\n"))
+	tx.Stage(filepath.Join(root, "docs", "b.md"), []byte("b\n"))
+	tx.Discard()
+	if entries, _ := os.ReadDir(root); len(entries) != 1 {
+		t.Errorf("Expected only a.txt to remain, got %v", entries)
+	}
+
+	// Test case 2: A failed move restores the files already moved
+	tx = j.Begin("doc")
+	tx.Stage(first, []byte("changed\n"))
+	blocker := filepath.Join(root, "dir")
+	tx.Stage(blocker, []byte("x\n"))
+	os.Mkdir(blocker, 0755)
+	os.WriteFile(filepath.Join(blocker, "keep"), nil, 0644)
+	if _, err := tx.Commit(); err == nil {
+		t.Fatalf("Expected the commit to fail")
+	}
+	if content, _ := os.ReadFile(first); string(content) != "a\n" {
+		t.Errorf("Expected a.txt to be restored, got %q", content)
+	}
+	if gens, _ := j.Generations(); len(gens) != 0 {
+		t.Errorf("Expected nothing to be recorded, got %d generations", len(gens))
+	}
+
+	// Test case 3: Paths outside the root are refused
+	if err := j.Begin("doc").Stage(filepath.Join(root, "..", "x"), nil); err == nil {
+		t.Errorf("Expected an error for a path outside the root")
+	}
+}
+
+func TestDeleteRenameChmod(t *testing.T) {
+	root := t.TempDir()
+	j := &Journal{Dir: filepath.Join(root, ".pdt", "journal"), Root: root}
+	for _, name := range []string{"old.go", "gone.go", "run.sh"} {
+		os.WriteFile(filepath.Join(root, name), []byte(name+"\n"), 0644)
+	}
+
+	// Test case 1: Deletes, renames and mode changes are applied together
+	tx := j.Begin("code")
+	if err := tx.Delete(filepath.Join(root, "gone.go")); err != nil {
+		t.Fatalf("Delete returned an error: %v", err)
+	}
+	if err := tx.Rename(filepath.Join(root, "old.go"), filepath.Join(root, "pkg", "new.go")); err != nil {
+		t.Fatalf("Rename returned an error: %v", err)
+	}
+	if err := tx.Chmod(filepath.Join(root, "run.sh"), 0755); err != nil {
+		t.Fatalf("Chmod returned an error: %v", err)
+	}
+	gen, err := tx.Commit()
+	if err != nil {
+		t.Fatalf("Commit returned an error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "gone.go")); !os.IsNotExist(err) {
+		t.Errorf("Expected gone.go to be deleted, got %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "pkg", "new.go")); string(content) != "old.go\n" {
+		t.Errorf("Expected old.go to be moved to pkg/new.go, got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(root, "old.go")); !os.IsNotExist(err) {
+		t.Errorf("Expected old.go to be gone after the rename, got %v", err)
+	}
+	if info, _ := os.Stat(filepath.Join(root, "run.sh")); info.Mode().Perm() != 0755 {
+		t.Errorf("Expected run.sh to be executable, got %v", info.Mode().Perm())
+	}
+
+	// Test case 2: Undo brings everything back
+	if err := j.Undo(gen, false); err != nil {
+		t.Fatalf("Undo returned an error: %v", err)
+	}
+	for _, name := range []string{"old.go", "gone.go", "run.sh"} {
+		if content, _ := os.ReadFile(filepath.Join(root, name)); string(content) != name+"\n" {
+			t.Errorf("Expected %s to be restored, got %q", name, content)
+		}
+	}
+	if info, _ := os.Stat(filepath.Join(root, "run.sh")); info.Mode().Perm() != 0644 {
+		t.Errorf("Expected run.sh to get its old mode back, got %v", info.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(root, "pkg")); !os.IsNotExist(err) {
+		t.Errorf("Expected pkg/new.go and its directory to be removed, got %v", err)
+	}
+}
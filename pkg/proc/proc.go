@@ -0,0 +1,75 @@
+package proc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// gracePeriod is how long an interrupted command may take to exit before it is killed.
+const gracePeriod = 5 * time.Second
+
+// Interrupted is the cancellation cause recorded when pdt receives a signal.
+type Interrupted struct {
+	Signal os.Signal
+}
+
+func (e *Interrupted) Error() string {
+	return "interrupted by " + e.Signal.String()
+}
+
+// NotifyContext returns a context that is cancelled with an *Interrupted cause on
+// SIGINT or SIGTERM. After the first signal the default handlers are restored, so
+// a second Ctrl-C terminates pdt immediately.
+func NotifyContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			cancel(&Interrupted{Signal: sig})
+		case <-ctx.Done():
+			signal.Stop(signals)
+		}
+	}()
+
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+// Command is like exec.CommandContext, but runs the command in its own process group
+// and, when ctx is done, forwards the interrupting signal to the whole group before
+// killing it after a grace period.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return signalGroup(cmd, cancelSignal(ctx))
+	}
+	cmd.WaitDelay = gracePeriod
+	return cmd
+}
+
+// InterruptedBy returns the signal that cancelled ctx, or nil if it wasn't a signal.
+func InterruptedBy(ctx context.Context) os.Signal {
+	var interrupted *Interrupted
+	if errors.As(context.Cause(ctx), &interrupted) {
+		return interrupted.Signal
+	}
+	return nil
+}
+
+// cancelSignal is forwarded to children when ctx is done: the signal pdt received,
+// or SIGTERM when the context timed out.
+func cancelSignal(ctx context.Context) os.Signal {
+	if sig := InterruptedBy(ctx); sig != nil {
+		return sig
+	}
+	return syscall.SIGTERM
+}
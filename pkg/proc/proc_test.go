@@ -0,0 +1,28 @@
+package proc
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCommandCancelStopsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on sh")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The shell's child sleep shares its process group, so both must be stopped.
+	cmd := Command(ctx, "sh", "-c", "sleep 10; echo done")
+	start := time.Now()
+	err := cmd.Run()
+	if err == nil {
+		t.Fatalf("Expected the command to be cancelled")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected cancellation to stop the command promptly, took %v", elapsed)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// groupPoll is how often a signalled process group is checked for having exited.
+const groupPoll = 50 * time.Millisecond
+
+// signalGroup sends sig to the command's process group and kills anything still
+// running once the grace period is over.
+func signalGroup(cmd *exec.Cmd, sig os.Signal) error {
+	pgid := -cmd.Process.Pid
+	unixSig, ok := sig.(syscall.Signal)
+	if !ok {
+		unixSig = syscall.SIGTERM
+	}
+	if err := syscall.Kill(pgid, unixSig); err != nil {
+		return err
+	}
+	go killAfter(pgid, gracePeriod)
+	return nil
+}
+
+// killAfter sends SIGKILL to the process group pgid once grace is over, unless the
+// group exits first: its id may then be reused by an unrelated group, so it stops
+// watching instead. It reports whether it killed the group.
+func killAfter(pgid int, grace time.Duration) bool {
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		if syscall.Kill(pgid, 0) == syscall.ESRCH {
+			return false
+		}
+		time.Sleep(groupPoll)
+	}
+	return syscall.Kill(pgid, syscall.SIGKILL) == nil
+}
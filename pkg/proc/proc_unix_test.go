@@ -0,0 +1,50 @@
+//go:build !windows
+// +build !windows
+
+package proc
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestKillAfter(t *testing.T) {
+	start := func(script string) (*exec.Cmd, chan error) {
+		cmd := exec.Command("sh", "-c", script)
+		setProcessGroup(cmd)
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Start returned an error: %v", err)
+		}
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+		return cmd, done
+	}
+
+	// Test case 1: A group that exits on the signal is left alone once it's gone
+	cmd, done := start("sleep 10")
+	time.Sleep(100 * time.Millisecond)
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	<-done
+	begin := time.Now()
+	if killAfter(-cmd.Process.Pid, 30*time.Second) {
+		t.Errorf("Expected the exited group not to be killed")
+	}
+	if elapsed := time.Since(begin); elapsed > 10*time.Second {
+		t.Errorf("Expected to stop watching as soon as the group exited, took %v", elapsed)
+	}
+
+	// Test case 2: A group that ignores the signal is killed after the grace period
+	cmd, done = start(`trap "" TERM; sleep 10`)
+	time.Sleep(100 * time.Millisecond)
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	if !killAfter(-cmd.Process.Pid, 200*time.Millisecond) {
+		t.Errorf("Expected the group to be killed")
+	}
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Errorf("Expected the killed group to exit")
+	}
+}
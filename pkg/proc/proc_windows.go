@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup kills the command; Windows has no portable way to forward signals.
+func signalGroup(cmd *exec.Cmd, sig os.Signal) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,141 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SectionUsage is the token cost of one section in a budget report.
+type SectionUsage struct {
+	Name     string
+	Tokens   int
+	Required bool
+	Dropped  bool
+}
+
+// Report describes how a prompt was measured against a token budget.
+type Report struct {
+	Sections []SectionUsage
+	// Total is the token count of the sections that were kept.
+	Total  int
+	Budget int
+}
+
+// Trimmed reports whether any section was dropped.
+func (r Report) Trimmed() bool {
+	for _, s := range r.Sections {
+		if s.Dropped {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report as one line per section, largest first.
+func (r Report) String() string {
+	sections := append([]SectionUsage(nil), r.Sections...)
+	sort.SliceStable(sections, func(i, j int) bool { return sections[i].Tokens > sections[j].Tokens })
+
+	var b strings.Builder
+	for _, s := range sections {
+		status := ""
+		if s.Dropped {
+			status = " (dropped)"
+		} else if s.Required {
+			status = " (required)"
+		}
+		fmt.Fprintf(&b, "  %8d tokens  %s%s\n", s.Tokens, s.Name, status)
+	}
+	fmt.Fprintf(&b, "  %8d tokens  total, budget %d", r.Total, r.Budget)
+	return b.String()
+}
+
+// BudgetError is returned when a prompt doesn't fit its budget even without optional sections.
+type BudgetError struct {
+	Report Report
+}
+
+func (e *BudgetError) Error() string {
+	var largest []string
+	for _, s := range e.Report.Sections {
+		if s.Required {
+			largest = append(largest, fmt.Sprintf("%s (~%d tokens)", s.Name, s.Tokens))
+		}
+	}
+	return fmt.Sprintf("prompt needs ~%d tokens but the context budget is %d even after dropping optional sections; "+
+		"required sections: %s. Shorten them, pass fewer inputs, or raise the context budget",
+		e.Report.Total, e.Report.Budget, strings.Join(largest, ", "))
+}
+
+// Fit measures every section with count and, if the prompt is over budget and trim is
+// set, drops optional sections from the lowest priority (and, within a priority, the
+// last added) until it fits. It returns the prompt that should be sent and a report of
+// what each section cost. A budget of 0 disables the check.
+func Fit(p *Prompt, budget int, trim bool, count func(string) int) (*Prompt, Report, error) {
+	report := Report{Budget: budget}
+	for _, section := range p.Sections {
+		tokens := count(section.Content)
+		report.Sections = append(report.Sections, SectionUsage{Name: section.Name, Tokens: tokens, Required: section.Required})
+		report.Total += tokens
+	}
+	if budget <= 0 || report.Total <= budget {
+		return p, report, nil
+	}
+	if !trim {
+		return nil, report, &BudgetError{Report: report}
+	}
+
+	// Candidates to drop, cheapest to lose first.
+	var order []int
+	for i, section := range p.Sections {
+		if !section.Required {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, pb := p.Sections[order[a]].Priority, p.Sections[order[b]].Priority
+		if pa != pb {
+			return pa < pb
+		}
+		return order[a] > order[b]
+	})
+
+	for _, i := range order {
+		if report.Total <= budget {
+			break
+		}
+		report.Sections[i].Dropped = true
+		report.Total -= report.Sections[i].Tokens
+	}
+	for i, section := range p.Sections {
+		if section.Heading && !report.Sections[i].Dropped && headsNothing(p, report, i) {
+			report.Sections[i].Dropped = true
+			report.Total -= report.Sections[i].Tokens
+		}
+	}
+	if report.Total > budget {
+		return nil, report, &BudgetError{Report: report}
+	}
+
+	trimmed := &Prompt{}
+	for i, section := range p.Sections {
+		if !report.Sections[i].Dropped {
+			trimmed.Sections = append(trimmed.Sections, section)
+		}
+	}
+	return trimmed, report, nil
+}
+
+// headsNothing reports whether every section under the heading at i was dropped.
+func headsNothing(p *Prompt, report Report, i int) bool {
+	for j := i + 1; j < len(p.Sections); j++ {
+		if p.Sections[j].Heading || p.Sections[j].Required {
+			break
+		}
+		if !report.Sections[j].Dropped {
+			return false
+		}
+	}
+	return true
+}
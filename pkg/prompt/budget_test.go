@@ -0,0 +1,83 @@
+package prompt
+
+import (
+	"errors"
+	"testing"
+)
+
+// countChars counts one token per character to keep the arithmetic obvious.
+func countChars(s string) int { return len(s) }
+
+func TestFitWithinBudget(t *testing.T) {
+	p := &Prompt{Sections: []Section{{Name: "a", Content: "12345", Required: true}}}
+
+	fitted, report, err := Fit(p, 10, true, countChars)
+	if err != nil {
+		t.Fatalf("Fit returned an error: %v", err)
+	}
+	if fitted != p || report.Total != 5 || report.Trimmed() {
+		t.Errorf("Expected the prompt unchanged at 5 tokens, got %d tokens, trimmed=%v", report.Total, report.Trimmed())
+	}
+}
+
+func TestFitDropsLowestPriorityFirst(t *testing.T) {
+	p := &Prompt{Sections: []Section{
+		{Name: "instructions", Content: "1234", Required: true},
+		{Name: "description", Content: "12345", Priority: PriorityMedium},
+		{Name: "file a", Content: "123456", Priority: PriorityLow},
+		{Name: "file b", Content: "123456", Priority: PriorityLow},
+	}}
+
+	// Test case 1: Dropping the last low-priority file is enough
+	fitted, report, err := Fit(p, 15, true, countChars)
+	if err != nil {
+		t.Fatalf("Fit returned an error: %v", err)
+	}
+	if len(fitted.Sections) != 3 || fitted.Sections[2].Name != "file a" {
+		t.Errorf("Expected 'file b' to be dropped, got %+v", fitted.Sections)
+	}
+	if report.Total != 15 || !report.Trimmed() {
+		t.Errorf("Expected a trimmed total of 15, got %d", report.Total)
+	}
+
+	// Test case 2: Both files go before the description
+	fitted, _, err = Fit(p, 9, true, countChars)
+	if err != nil {
+		t.Fatalf("Fit returned an error: %v", err)
+	}
+	if len(fitted.Sections) != 2 || fitted.Sections[1].Name != "description" {
+		t.Errorf("Expected instructions and description to remain, got %+v", fitted.Sections)
+	}
+
+	// Test case 3: Required sections alone are over budget
+	var budgetErr *BudgetError
+	if _, _, err := Fit(p, 3, true, countChars); !errors.As(err, &budgetErr) {
+		t.Errorf("Expected a BudgetError, got %v", err)
+	}
+
+	// Test case 4: Trimming disabled refuses instead
+	if _, _, err := Fit(p, 15, false, countChars); !errors.As(err, &budgetErr) {
+		t.Errorf("Expected a BudgetError when trimming is disabled, got %v", err)
+	}
+}
+
+func TestFitDropsEmptyHeadings(t *testing.T) {
+	p := &Prompt{Sections: []Section{
+		{Name: "instructions", Content: "1234", Required: true},
+		{Name: "code heading", Content: "Code:", Priority: PriorityHigh, Heading: true},
+		{Name: "file a", Content: "123456", Priority: PriorityLow},
+		{Name: "file b", Content: "123456", Priority: PriorityLow},
+	}}
+
+	// Test case 1: The heading stays while a section under it does
+	fitted, _, err := Fit(p, 15, true, countChars)
+	if err != nil || len(fitted.Sections) != 3 || fitted.Sections[1].Name != "code heading" {
+		t.Errorf("Expected the heading and 'file a' to remain, got %+v (%v)", fitted, err)
+	}
+
+	// Test case 2: The heading goes with the last section under it
+	fitted, report, err := Fit(p, 9, true, countChars)
+	if err != nil || len(fitted.Sections) != 1 || report.Total != 4 {
+		t.Errorf("Expected only the instructions to remain, got %+v (%v)", fitted, err)
+	}
+}
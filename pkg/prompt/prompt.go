@@ -3,122 +3,305 @@ package prompt
 import (
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
+
+	"github.com/productdevtool/pdt-cli/pkg/diag"
 )
 
-// RefineTaskPrompt generates a prompt for refining a task.md file.
-func RefineTaskPrompt(projectDescriptionPath string, taskPath string) (string, error) {
-	projectDescription, err := ioutil.ReadFile(projectDescriptionPath)
+// Section priorities. When a prompt is over budget, optional sections are dropped
+// starting with the lowest priority.
+const (
+	PriorityLow    = 1
+	PriorityMedium = 2
+	PriorityHigh   = 3
+)
+
+// Section is one named part of a prompt, such as the task or a code file.
+type Section struct {
+	Name     string
+	Content  string
+	Priority int
+	// Required sections are never dropped to fit a budget.
+	Required bool
+	// Heading sections introduce the sections after them, up to the next heading or
+	// required section, and are dropped along with the last of those.
+	Heading bool
+}
+
+// Prompt is an ordered list of sections sent to the AI as one piece of text.
+type Prompt struct {
+	Sections []Section
+}
+
+// String renders the prompt.
+func (p *Prompt) String() string {
+	var parts []string
+	for _, section := range p.Sections {
+		parts = append(parts, section.Content)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Text returns a prompt made of a single required section.
+func Text(name string, content string) *Prompt {
+	return &Prompt{Sections: []Section{{Name: name, Content: content, Priority: PriorityHigh, Required: true}}}
+}
+
+func instructions(content string) Section {
+	return Section{Name: "instructions", Content: content, Priority: PriorityHigh, Required: true}
+}
+
+func projectDescriptionSection(path string) (Section, error) {
+	projectDescription, err := ioutil.ReadFile(path)
 	if err != nil {
-		return "", fmt.Errorf("error reading project description: %w", err)
+		return Section{}, fmt.Errorf("error reading project description: %w", err)
 	}
+	return Section{
+		Name:     "project description",
+		Content:  fmt.Sprintf("Here is the project description:\n%s", string(projectDescription)),
+		Priority: PriorityMedium,
+	}, nil
+}
 
-	task, err := ioutil.ReadFile(taskPath)
+func taskSection(path string, heading string) (Section, error) {
+	task, err := ioutil.ReadFile(path)
 	if err != nil {
-		return "", fmt.Errorf("error reading task: %w", err)
+		return Section{}, fmt.Errorf("error reading task: %w", err)
 	}
+	return Section{
+		Name:     "task",
+		Content:  fmt.Sprintf("%s\n%s", heading, string(task)),
+		Priority: PriorityHigh,
+		Required: true,
+	}, nil
+}
 
-	prompt := fmt.Sprintf(`\
-	Here is the project description:\n%s\n\n	Here is the task:\n%s\n\n	Please refine the task into a detailed, actionable technical plan. The plan should include specific file locations for code changes, required automated tests, and manual user-facing tests. The output should be a markdown file.
-	`, string(projectDescription), string(task))
+// RefineTaskPrompt generates a prompt for refining a task.md file.
+func RefineTaskPrompt(projectDescriptionPath string, taskPath string) (*Prompt, error) {
+	projectDescription, err := projectDescriptionSection(projectDescriptionPath)
+	if err != nil {
+		return nil, err
+	}
 
-	return prompt, nil
+	task, err := taskSection(taskPath, "Here is the task:")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Prompt{Sections: []Section{
+		projectDescription,
+		task,
+		instructions("Please refine the task into a detailed, actionable technical plan. The plan should include specific file locations for code changes, required automated tests, and manual user-facing tests. The output should be a markdown file."),
+	}}, nil
 }
 
-// InitialProjectDescriptionPrompt generates a prompt for creating the initial project-description.md.
-func InitialProjectDescriptionPrompt() string {
-	return `\
-	Please analyze the current codebase and generate a comprehensive project description. \
-	This description should include the project's structure, key commands, main functionalities, \
-	and any other relevant information that would help an AI understand and work with this project. \
-	The output should be a markdown file named "project-description.md".
-	`
+// InitialProjectDescriptionPrompt generates a prompt for drafting the initial
+// project-description.md from the skeleton pdt init wrote and the project's files.
+func InitialProjectDescriptionPrompt(skeleton string, files []string) *Prompt {
+	return &Prompt{Sections: []Section{
+		{Name: "skeleton", Content: fmt.Sprintf("Here is the skeleton of the project description:\n%s", skeleton), Priority: PriorityHigh, Required: true},
+		{Name: "files", Content: fmt.Sprintf("Here are the project's files:\n%s", strings.Join(files, "\n")), Priority: PriorityMedium},
+		instructions("Please analyze the current codebase and generate a comprehensive project description, filling in the skeleton. " +
+			"This description should include the project's structure, key commands, main functionalities, " +
+			"and any other relevant information that would help an AI understand and work with this project. " +
+			`The output should be only the markdown content of the file "project-description.md".`),
+	}}
 }
 
 // MasterImplementationPrompt generates the master prompt for code generation.
-func MasterImplementationPrompt(projectDescriptionPath string, taskPath string) (string, error) {
-	projectDescription, err := ioutil.ReadFile(projectDescriptionPath)
+func MasterImplementationPrompt(projectDescriptionPath string, taskPath string) (*Prompt, error) {
+	projectDescription, err := projectDescriptionSection(projectDescriptionPath)
 	if err != nil {
-		return "", fmt.Errorf("error reading project description: %w", err)
+		return nil, err
 	}
 
-	task, err := ioutil.ReadFile(taskPath)
+	task, err := taskSection(taskPath, "Here is the detailed task specification:")
 	if err != nil {
-		return "", fmt.Errorf("error reading task: %w", err)
+		return nil, err
 	}
 
-	prompt := fmt.Sprintf(`\
-	Here is the project description:\n%s\n\n	Here is the detailed task specification:\n%s\n\n	Please implement the task based on the provided project description and detailed specification. \
-	Generate the necessary code, making sure to adhere to the specified file locations and include any required tests. \
-	Provide the output as code blocks, clearly indicating file paths for each code block.
-	`, string(projectDescription), string(task))
+	return &Prompt{Sections: []Section{
+		projectDescription,
+		task,
+		instructions("Please implement the task based on the provided project description and detailed specification. " +
+			"Generate the necessary code, making sure to adhere to the specified file locations and include any required tests. " +
+			"Provide the output as code blocks, clearly indicating file paths for each code block. " +
+			editFormat),
+	}}, nil
+}
+
+// editFormat asks for edits rather than full copies of existing files, which the AI
+// tends to truncate.
+const editFormat = "For new files, give the complete content. To change an existing file, give only the changes as " +
+	"SEARCH/REPLACE blocks inside the file's code block, each copying the current lines exactly:\n" +
+	"<<<<<<< SEARCH\nlines to find\n=======\nlines to put in their place\n>>>>>>> REPLACE\n" +
+	"A unified diff in a diff code block is also accepted. To delete, move or change the permissions of files, " +
+	"list the operations in a pdt-ops code block, one per line:\n" +
+	"delete path/to/file\nrename old/path new/path\nchmod 0755 path/to/script"
 
-	return prompt, nil
+// HunkRepairPrompt asks the AI to redo the edits to a file that could not be applied.
+func HunkRepairPrompt(path string, content string, rejected []string) *Prompt {
+	return &Prompt{Sections: []Section{
+		instructions(fmt.Sprintf("Some of your edits to %s could not be applied because the lines they search for are not in the file. "+
+			"Rewrite only these edits against the current content below. Reply with a single code block for %s containing "+
+			"SEARCH/REPLACE blocks whose SEARCH sections copy the current lines exactly.", path, path)),
+		{Name: "rejected edits", Content: "Edits that failed:\n" + strings.Join(rejected, "\n\n"), Priority: PriorityHigh, Required: true},
+		{Name: "current file", Content: fmt.Sprintf("Current content of %s:\n```\n%s```", path, content), Priority: PriorityHigh, Required: true},
+	}}
 }
 
-// CommitMessagePrompt generates a prompt for creating a commit message.
-func CommitMessagePrompt(taskPath string) (string, error) {
-	task, err := ioutil.ReadFile(taskPath)
+// maxFailureOutput is how much of a failing command's output is sent to the AI. The
+// end of the output is kept, since that is where most tools report errors.
+const maxFailureOutput = 12000
+
+// ValidationFailure describes a validation command that failed after code generation.
+type ValidationFailure struct {
+	Command string
+	Output  string
+	// Failures are the failures parsed from the output, if any were recognised. The
+	// files they point at are included before the other files.
+	Failures []diag.Failure
+	// Files are the files written so far; their current content is included.
+	Files []string
+	// Attempts summarises the earlier attempts to fix the failure.
+	Attempts []string
+}
+
+// ValidationRepairPrompt asks the AI to fix the code it generated so that a failing
+// validation command passes. Each file is its own section so it can be dropped to fit
+// a budget.
+func ValidationRepairPrompt(projectDescriptionPath string, taskPath string, failure ValidationFailure) (*Prompt, error) {
+	projectDescription, err := projectDescriptionSection(projectDescriptionPath)
 	if err != nil {
-		return "", fmt.Errorf("error reading task: %w", err)
+		return nil, err
+	}
+	projectDescription.Priority = PriorityLow
+
+	task, err := taskSection(taskPath, "Here is the task you implemented:")
+	if err != nil {
+		return nil, err
+	}
+
+	output := failure.Output
+	if len(output) > maxFailureOutput {
+		output = "(earlier output omitted)\n" + output[len(output)-maxFailureOutput:]
+	}
+
+	p := &Prompt{Sections: []Section{
+		projectDescription,
+		task,
+		instructions(fmt.Sprintf("The code you generated for this task fails the automated validation command `%s`. "+
+			"Find the cause in its output below and fix the code so that the command passes, without weakening or deleting tests. "+
+			"Only change what the fix needs. ", failure.Command) + editFormat),
+	}}
+	outputSection := Section{Name: "validation output", Content: fmt.Sprintf("Output of `%s`:\n```\n%s\n```", failure.Command, strings.TrimRight(output, "\n")), Priority: PriorityHigh, Required: true}
+	if len(failure.Failures) > 0 {
+		var list []string
+		for _, f := range failure.Failures {
+			list = append(list, "- "+f.String())
+		}
+		p.Sections = append(p.Sections, Section{Name: "failures", Content: "The failures reported:\n" + strings.Join(list, "\n"), Priority: PriorityHigh, Required: true})
+		// The list says what failed, so the full output can go to fit a budget.
+		outputSection.Priority, outputSection.Required = PriorityMedium, false
+	}
+	p.Sections = append(p.Sections, outputSection)
+	if len(failure.Attempts) > 0 {
+		p.Sections = append(p.Sections, Section{
+			Name:     "earlier attempts",
+			Content:  "Earlier fixes that did not make validation pass:\n- " + strings.Join(failure.Attempts, "\n- "),
+			Priority: PriorityMedium,
+		})
+	}
+	failing := diag.Files(failure.Failures)
+	seen := map[string]bool{}
+	for i, path := range append(failing, failure.Files...) {
+		path = filepath.Clean(path)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		priority := PriorityMedium
+		if i < len(failing) {
+			priority = PriorityHigh
+		}
+		p.Sections = append(p.Sections, Section{
+			Name:     "code file " + path,
+			Content:  fmt.Sprintf("Current content of %s:\n```\n%s```", path, string(content)),
+			Priority: priority,
+		})
 	}
+	return p, nil
+}
 
-	prompt := fmt.Sprintf(`\
-	Based on the following task specification, please generate a concise and descriptive Git commit message. \
-	Focus on the "what" and "why" of the changes. The commit message should follow conventional commits guidelines (e.g., feat: add new feature). \
-	Task: %s
-	`, string(task))
+// CommitMessagePrompt generates a prompt for creating a commit message.
+func CommitMessagePrompt(taskPath string) (*Prompt, error) {
+	task, err := taskSection(taskPath, "Task:")
+	if err != nil {
+		return nil, err
+	}
 
-	return prompt, nil
+	return &Prompt{Sections: []Section{
+		instructions("Based on the following task specification, please generate a concise and descriptive Git commit message. " +
+			`Focus on the "what" and "why" of the changes. The commit message should follow conventional commits guidelines (e.g., feat: add new feature).`),
+		task,
+	}}, nil
 }
 
 // TestGenerationPrompt generates a prompt for creating tests based on a spec file.
-func TestGenerationPrompt(specPath string) (string, error) {
+func TestGenerationPrompt(specPath string) (*Prompt, error) {
 	specContent, err := ioutil.ReadFile(specPath)
 	if err != nil {
-		return "", fmt.Errorf("error reading spec file: %w", err)
+		return nil, fmt.Errorf("error reading spec file: %w", err)
 	}
 
-	prompt := fmt.Sprintf(`\
-	Based on the following specification, please generate comprehensive tests. \
-	The tests should cover unit, integration, and end-to-end scenarios as appropriate. \
-	Adhere to the project's existing testing patterns and frameworks. \
-	Provide the output as code blocks, clearly indicating file paths for each test file.
-	Specification: %s
-	`, string(specContent))
-
-	return prompt, nil
+	return &Prompt{Sections: []Section{
+		instructions("Based on the following specification, please generate comprehensive tests. " +
+			"The tests should cover unit, integration, and end-to-end scenarios as appropriate. " +
+			"Adhere to the project's existing testing patterns and frameworks. " +
+			"Provide the output as code blocks, clearly indicating file paths for each test file."),
+		{Name: "specification", Content: fmt.Sprintf("Specification: %s", string(specContent)), Priority: PriorityHigh, Required: true},
+	}}, nil
 }
 
 // DocGenerationPrompt generates a prompt for updating internal documentation.
-func DocGenerationPrompt(specPath string, codePaths []string) (string, error) {
+// Each code file is its own low-priority section so it can be dropped to fit a budget.
+func DocGenerationPrompt(specPath string, codePaths []string) (*Prompt, error) {
 	specContent, err := ioutil.ReadFile(specPath)
 	if err != nil {
-		return "", fmt.Errorf("error reading spec file: %w", err)
+		return nil, fmt.Errorf("error reading spec file: %w", err)
 	}
 
-	var codeContents []string
+	p := &Prompt{Sections: []Section{
+		instructions("Based on the following specification and implemented code, please update internal documentation. " +
+			"Explain how the feature works, its API, and how to use it."),
+		{Name: "specification", Content: fmt.Sprintf("Specification: %s", string(specContent)), Priority: PriorityHigh, Required: true},
+	}}
+
+	if len(codePaths) > 0 {
+		p.Sections = append(p.Sections, Section{Name: "code heading", Content: "Implemented Code:", Priority: PriorityHigh, Heading: true})
+	}
 	for _, path := range codePaths {
 		content, err := ioutil.ReadFile(path)
 		if err != nil {
-			return "", fmt.Errorf("error reading code file %s: %w", path, err)
+			return nil, fmt.Errorf("error reading code file %s: %w", path, err)
 		}
-		codeContents = append(codeContents, fmt.Sprintf("File: %s\n```\n%s\n```", path, string(content)))
+		p.Sections = append(p.Sections, Section{
+			Name:     "code file " + path,
+			Content:  fmt.Sprintf("File: %s\n```\n%s\n```", path, string(content)),
+			Priority: PriorityLow,
+		})
 	}
 
-	prompt := fmt.Sprintf(`\
-	Based on the following specification and implemented code, please update internal documentation. \
-	Explain how the feature works, its API, and how to use it. \
-	Specification: %s\n\nImplemented Code:\n%s
-	`, string(specContent), strings.Join(codeContents, "\n\n"))
-
-	return prompt, nil
+	return p, nil
 }
 
 // ContentGenerationPrompt generates a prompt for creating external-facing content.
-func ContentGenerationPrompt(contentType string, topic string) string {
-	return fmt.Sprintf(`\
-	Generate %s content about the following topic: %s. \
-	The output should be suitable for direct use and saved to a new file in a /content directory. \
-	`, contentType, topic)
+func ContentGenerationPrompt(contentType string, topic string) *Prompt {
+	return Text("instructions", fmt.Sprintf("Generate %s content about the following topic: %s. "+
+		"The output should be suitable for direct use and saved to a new file in a /content directory.", contentType, topic))
 }
@@ -0,0 +1,51 @@
+package prompt
+
+import (
+	"fmt"
+)
+
+// structuredOutputSchema is the JSON document requested in structured mode. It must
+// stay in sync with the validation in fs.ParseStructuredOutput.
+const structuredOutputSchema = `{
+  "files": [
+    {
+      "path": "relative/path/from/the/project/root.ext",
+      "action": "create", "update", "patch", "delete", "rename" or "chmod",
+      "content": "the complete content of the file, or for patch, the edits to make",
+      "to": "for rename, the new relative path",
+      "mode": "for chmod, the octal permissions, e.g. 0755",
+      "rationale": "one sentence explaining the change"
+    }
+  ]
+}`
+
+func structuredOutputInstructions() Section {
+	return Section{
+		Name: "output format",
+		Content: "Ignore any earlier request for markdown code blocks. Respond with a single JSON document and nothing else, " +
+			"matching this schema:\n" + structuredOutputSchema + "\n" +
+			`Use "create" for new files and "update" to rewrite existing ones, with the complete file content. ` +
+			`To change part of an existing file use "patch" with a unified diff or SEARCH/REPLACE blocks as the content. ` +
+			`Use "delete", "rename" (with "to") and "chmod" (with "mode") to remove, move or change the permissions of files; they need no content. ` +
+			`Escape newlines and quotes in "content" as JSON requires.`,
+		Priority: PriorityHigh,
+		Required: true,
+	}
+}
+
+// WithStructuredOutput returns a copy of p that asks for JSON file operations instead of markdown.
+func WithStructuredOutput(p *Prompt) *Prompt {
+	structured := &Prompt{Sections: append([]Section(nil), p.Sections...)}
+	structured.Sections = append(structured.Sections, structuredOutputInstructions())
+	return structured
+}
+
+// StructuredOutputRepairPrompt asks the AI to correct a structured response that failed validation.
+func StructuredOutputRepairPrompt(response string, problems error) *Prompt {
+	return &Prompt{Sections: []Section{
+		instructions("Your previous response was supposed to be a JSON document but it could not be used."),
+		{Name: "problems", Content: fmt.Sprintf("Problems found:\n%v", problems), Priority: PriorityHigh, Required: true},
+		{Name: "previous response", Content: fmt.Sprintf("Previous response:\n%s", response), Priority: PriorityHigh, Required: true},
+		structuredOutputInstructions(),
+	}}
+}
@@ -0,0 +1,587 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/productdevtool/pdt-cli/pkg/proc"
+)
+
+// Command is a command line and how to run it.
+type Command struct {
+	// Run is the command line, which is passed to the shell as written, so quotes,
+	// pipes, && and VAR=value assignments work as they do in a terminal.
+	Run string
+	// Dir is the directory to run in, relative to the runner's directory.
+	Dir string
+	// Env lists KEY=VALUE pairs added to pdt's environment.
+	Env []string
+	// Timeout stops the command after this long; 0 means no limit.
+	Timeout time.Duration
+	// ContinueOnError lets the commands that need this one run even if it fails, and
+	// keeps its failure from failing the run.
+	ContinueOnError bool
+	// Name identifies the command in Needs, output prefixes and reports.
+	Name string
+	// Needs lists the names of the commands that must finish before this one starts.
+	Needs []string
+	// Results is a file the command writes test results to, such as a JUnit XML
+	// report, relative to its directory.
+	Results string
+}
+
+func (c Command) String() string {
+	return c.Run
+}
+
+// Label returns the command's name, or a shortened command line if it has none.
+func (c Command) Label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if len(c.Run) > 40 {
+		return c.Run[:37] + "..."
+	}
+	return c.Run
+}
+
+// Parse parses a command as written in project-description.md: the command line in
+// backticks, optionally followed by attributes, e.g.
+//
+//	`go test ./...` (cwd=backend, env=CGO_ENABLED=0, timeout=5m, continue_on_error)
+//	`go test -race ./...` (name=race, needs="build,generate")
+//
+// The parentheses are optional and attributes may be separated by commas or spaces.
+// A command line that itself contains a backtick can be wrapped in two backticks,
+// as in markdown.
+func Parse(text string) (Command, error) {
+	text = strings.TrimSpace(text)
+	fence := len(text) - len(strings.TrimLeft(text, "`"))
+	if fence == 0 {
+		return Command{}, fmt.Errorf("expected a command in backticks, got %q", text)
+	}
+	delimiter := strings.Repeat("`", fence)
+	end := strings.Index(text[fence:], delimiter)
+	if end < 0 {
+		return Command{}, fmt.Errorf("missing closing backtick in %q", text)
+	}
+	c := Command{Run: strings.TrimSpace(text[fence : fence+end])}
+	if c.Run == "" {
+		return Command{}, fmt.Errorf("empty command in %q", text)
+	}
+
+	attributes := strings.TrimSpace(text[fence+end+fence:])
+	if strings.HasPrefix(attributes, "(") && strings.HasSuffix(attributes, ")") ||
+		strings.HasPrefix(attributes, "{") && strings.HasSuffix(attributes, "}") {
+		attributes = attributes[1 : len(attributes)-1]
+	}
+	fields, err := splitAttributes(attributes)
+	if err != nil {
+		return Command{}, fmt.Errorf("%w in %q", err, text)
+	}
+	for _, field := range fields {
+		if err := c.set(field); err != nil {
+			return Command{}, fmt.Errorf("invalid attribute for `%s`: %w", c.Run, err)
+		}
+	}
+	return c, nil
+}
+
+// set applies one key=value attribute.
+func (c *Command) set(field string) error {
+	key, value := field, ""
+	hasValue := false
+	if i := strings.Index(field, "="); i >= 0 {
+		key, value, hasValue = field[:i], field[i+1:], true
+	}
+	switch key {
+	case "cwd", "dir":
+		c.Dir = value
+	case "env":
+		if !strings.Contains(value, "=") || strings.HasPrefix(value, "=") {
+			return fmt.Errorf("env %q must be KEY=VALUE", value)
+		}
+		c.Env = append(c.Env, value)
+	case "timeout":
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("timeout %q: %w", value, err)
+		}
+		c.Timeout = timeout
+	case "name":
+		c.Name = value
+	case "results":
+		c.Results = value
+	case "needs":
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				c.Needs = append(c.Needs, name)
+			}
+		}
+	case "continue_on_error":
+		c.ContinueOnError = true
+		if hasValue {
+			continueOnError, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("continue_on_error %q must be true or false", value)
+			}
+			c.ContinueOnError = continueOnError
+		}
+	default:
+		return fmt.Errorf("unknown attribute %q; expected cwd, env, timeout, continue_on_error, name, needs or results", key)
+	}
+	if key != "continue_on_error" && (!hasValue || value == "") {
+		return fmt.Errorf("%s needs a value, e.g. %s=...", key, key)
+	}
+	return nil
+}
+
+// splitAttributes splits on commas and whitespace outside double or single quotes,
+// removing the quotes.
+func splitAttributes(s string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	inField := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == ',' || r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields, nil
+}
+
+// Shell returns the program and arguments that run a command line: bash -c, or sh -c
+// where bash isn't installed, and cmd /C on Windows.
+func Shell() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C"}
+	}
+	if _, err := exec.LookPath("bash"); err == nil {
+		return []string{"bash", "-c"}
+	}
+	return []string{"sh", "-c"}
+}
+
+// Result is the outcome of running a command.
+type Result struct {
+	Command Command
+	// Output is everything the command wrote to stdout and stderr, interleaved as it
+	// was written; Stdout and Stderr hold each stream on its own.
+	Output   string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+	// Err is non-nil when the command failed, timed out, couldn't be started or was
+	// skipped.
+	Err error
+	// Skipped is set when the command didn't run because a command it needs failed or
+	// the run was interrupted.
+	Skipped bool
+}
+
+// Statuses a Result can have.
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusIgnored = "ignored"
+	StatusSkipped = "skipped"
+)
+
+// Status returns StatusPassed, StatusFailed, StatusSkipped, or StatusIgnored for a
+// failed command that continues on error.
+func (r Result) Status() string {
+	switch {
+	case r.Skipped:
+		return StatusSkipped
+	case r.Err == nil:
+		return StatusPassed
+	case r.Command.ContinueOnError:
+		return StatusIgnored
+	default:
+		return StatusFailed
+	}
+}
+
+// Failed reports whether the result should fail the run.
+func (r Result) Failed() bool {
+	status := r.Status()
+	return status == StatusFailed || status == StatusSkipped
+}
+
+// Runner runs commands through the shell.
+type Runner struct {
+	// Dir is the directory commands run in and that their Dir is relative to; empty
+	// means the current directory.
+	Dir string
+	// Stdout and Stderr, when set, show the output as it is produced. It is captured
+	// in the Result either way.
+	Stdout io.Writer
+	Stderr io.Writer
+	// OnStart and OnDone, when set, are called before and after each command RunAll runs,
+	// one at a time.
+	OnStart func(c Command)
+	OnDone  func(result Result)
+}
+
+// Run runs c and waits for it to finish. Cancelling ctx, or reaching c's timeout,
+// stops the command and everything it started.
+func (r *Runner) Run(ctx context.Context, c Command) Result {
+	return r.run(ctx, c, r.Stdout, r.Stderr)
+}
+
+func (r *Runner) run(ctx context.Context, c Command, stdout, stderr io.Writer) Result {
+	start := time.Now()
+	runCtx := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	shell := Shell()
+	cmd := proc.Command(runCtx, shell[0], append(shell[1:], c.Run)...)
+	cmd.Dir = r.Dir
+	if c.Dir != "" {
+		cmd.Dir = c.Dir
+		if !filepath.IsAbs(c.Dir) {
+			cmd.Dir = filepath.Join(r.Dir, c.Dir)
+		}
+	}
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+
+	var output lockedBuffer
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdout = capture(stdout, &stdoutBuf, &output)
+	cmd.Stderr = capture(stderr, &stderrBuf, &output)
+
+	err := cmd.Run()
+	exitCode := 0
+	if err != nil {
+		exitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %v", c.Timeout)
+	}
+	return Result{
+		Command:  c,
+		Output:   output.buf.String(),
+		Stdout:   stdoutBuf.String(),
+		Stderr:   stderrBuf.String(),
+		ExitCode: exitCode,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+}
+
+// capture returns a writer that copies a stream to show, when set, and to its buffers.
+func capture(show io.Writer, buffers ...io.Writer) io.Writer {
+	if show != nil {
+		buffers = append([]io.Writer{show}, buffers...)
+	}
+	return io.MultiWriter(buffers...)
+}
+
+// lockedBuffer collects output that stdout and stderr may write at the same time.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// RunAll runs commands, up to jobs at a time, and returns a result for each in the
+// order given. A command starts once the commands it needs have finished and is
+// skipped if one of them failed without continuing on error; commands that don't
+// depend on each other run in parallel, so one failing doesn't stop the others. When
+// more than one command can run at once, each line of output is prefixed with the
+// command's label. RunAll returns an error, without running anything, if a command
+// needs an unknown name or the commands need each other in a cycle.
+func (r *Runner) RunAll(ctx context.Context, commands []Command, jobs int) ([]Result, error) {
+	needs, err := dependencies(commands)
+	if err != nil {
+		return nil, err
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	prefix := jobs > 1 && len(commands) > 1
+	var outputMu sync.Mutex
+
+	const (
+		pending = iota
+		running
+		done
+	)
+	state := make([]int, len(commands))
+	results := make([]Result, len(commands))
+	finished := make(chan int)
+	active := 0
+	finish := func(i int) {
+		state[i] = done
+		if r.OnDone != nil {
+			r.OnDone(results[i])
+		}
+	}
+
+	for {
+		for progress := true; progress; {
+			progress = false
+			for i, c := range commands {
+				if state[i] != pending || !finishedAll(state, needs[i], done) {
+					continue
+				}
+				if reason := skipReason(ctx, commands, results, needs[i]); reason != nil {
+					results[i] = Result{Command: c, ExitCode: -1, Err: reason, Skipped: true}
+					finish(i)
+					progress = true
+					continue
+				}
+				if active == jobs {
+					continue
+				}
+				state[i] = running
+				active++
+				if r.OnStart != nil {
+					r.OnStart(c)
+				}
+				stdout, stderr := r.Stdout, r.Stderr
+				var writers []*prefixWriter
+				if prefix {
+					label := "[" + c.Label() + "] "
+					if stdout != nil {
+						writers = append(writers, &prefixWriter{mu: &outputMu, w: stdout, prefix: label})
+						stdout = writers[len(writers)-1]
+					}
+					if stderr != nil {
+						writers = append(writers, &prefixWriter{mu: &outputMu, w: stderr, prefix: label})
+						stderr = writers[len(writers)-1]
+					}
+				}
+				go func(i int, c Command) {
+					results[i] = r.run(ctx, c, stdout, stderr)
+					for _, w := range writers {
+						w.Flush()
+					}
+					finished <- i
+				}(i, c)
+			}
+		}
+		if active == 0 {
+			return results, nil
+		}
+		i := <-finished
+		active--
+		finish(i)
+	}
+}
+
+// HasNeeds reports whether any of commands declares the commands it needs, so their
+// order is spelled out rather than implied by the list.
+func HasNeeds(commands []Command) bool {
+	for _, c := range commands {
+		if len(c.Needs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// dependencies returns, for each command, the indexes of the commands it needs.
+func dependencies(commands []Command) ([][]int, error) {
+	names := map[string]int{}
+	for i, c := range commands {
+		if c.Name == "" {
+			continue
+		}
+		if _, ok := names[c.Name]; ok {
+			return nil, fmt.Errorf("more than one command is named %q", c.Name)
+		}
+		names[c.Name] = i
+	}
+	needs := make([][]int, len(commands))
+	for i, c := range commands {
+		for _, name := range c.Needs {
+			j, ok := names[name]
+			if !ok {
+				return nil, fmt.Errorf("`%s` needs %q, but no command has that name", c.Run, name)
+			}
+			needs[i] = append(needs[i], j)
+		}
+	}
+
+	// Depth-first search for a cycle: visiting marks commands on the current path.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(commands))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch marks[i] {
+		case visiting:
+			return fmt.Errorf("commands need each other in a cycle through %q", commands[i].Label())
+		case visited:
+			return nil
+		}
+		marks[i] = visiting
+		for _, j := range needs[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		marks[i] = visited
+		return nil
+	}
+	for i := range commands {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return needs, nil
+}
+
+// finishedAll reports whether every command in indexes is in state done.
+func finishedAll(state []int, indexes []int, done int) bool {
+	for _, i := range indexes {
+		if state[i] != done {
+			return false
+		}
+	}
+	return true
+}
+
+// skipReason returns why a command whose needs have finished shouldn't run, or nil.
+func skipReason(ctx context.Context, commands []Command, results []Result, needs []int) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("not run: %w", ctx.Err())
+	}
+	for _, j := range needs {
+		if results[j].Failed() {
+			return fmt.Errorf("skipped because %s %s", commands[j].Label(), results[j].Status())
+		}
+	}
+	return nil
+}
+
+// prefixWriter writes whole lines to w, each starting with prefix, holding mu so
+// lines from commands running in parallel don't mix.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	line   []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.line = append(p.line, b...)
+	for {
+		i := bytes.IndexByte(p.line, '\n')
+		if i < 0 {
+			break
+		}
+		p.writeLine(p.line[:i+1])
+		p.line = p.line[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes a last line that didn't end in a newline.
+func (p *prefixWriter) Flush() {
+	if len(p.line) > 0 {
+		p.writeLine(append(p.line, '\n'))
+		p.line = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.w.Write(append([]byte(p.prefix), line...))
+}
+
+// Report is a machine-readable record of a run.
+type Report struct {
+	Passed bool         `json:"passed"`
+	Steps  []StepReport `json:"steps"`
+}
+
+// StepReport records one command of a run.
+type StepReport struct {
+	Name       string `json:"name"`
+	Command    string `json:"command"`
+	Status     string `json:"status"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMS int64  `json:"duration_ms"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NewReport builds the report of a run from its results.
+func NewReport(results []Result) Report {
+	report := Report{Passed: true, Steps: []StepReport{}}
+	for _, result := range results {
+		step := StepReport{
+			Name:       result.Command.Name,
+			Command:    result.Command.Run,
+			Status:     result.Status(),
+			ExitCode:   result.ExitCode,
+			DurationMS: result.Duration.Milliseconds(),
+			Stdout:     result.Stdout,
+			Stderr:     result.Stderr,
+		}
+		if result.Err != nil {
+			step.Error = result.Err.Error()
+		}
+		report.Passed = report.Passed && !result.Failed()
+		report.Steps = append(report.Steps, step)
+	}
+	return report
+}
@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// Test case 1: A bare command keeps its quotes and shell syntax
+	c, err := Parse("`go test ./... && echo \"all good\" | tee log`")
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if c.Run != `go test ./... && echo "all good" | tee log` {
+		t.Errorf("Expected the command line as written, got %q", c.Run)
+	}
+
+	// Test case 2: Attributes set the directory, environment, timeout and error handling
+	c, err = Parse("`npm test` (cwd=web, env=CI=1, env='NODE_OPTIONS=--max-old-space-size=4096', timeout=5m, continue_on_error)")
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	expected := Command{Run: "npm test", Dir: "web", Env: []string{"CI=1", "NODE_OPTIONS=--max-old-space-size=4096"}, Timeout: 5 * time.Minute, ContinueOnError: true}
+	if !reflect.DeepEqual(expected, c) {
+		t.Errorf("Expected %+v, got %+v", expected, c)
+	}
+
+	// Test case 3: Double backticks allow a backtick in the command
+	if c, err := Parse("``echo `date` ``"); err != nil || c.Run != "echo `date`" {
+		t.Errorf("Expected echo `date`, got %q (%v)", c.Run, err)
+	}
+
+	// Test case 4: Steps can be named, need other steps and name a results file
+	c, err = Parse("`pytest --junitxml=out/junit.xml` (name=test, needs=\"build, generate\", needs=lint, results=out/junit.xml)")
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if c.Name != "test" || !reflect.DeepEqual(c.Needs, []string{"build", "generate", "lint"}) || c.Results != "out/junit.xml" {
+		t.Errorf("Expected test needing build, generate and lint with results in out/junit.xml, got %+v", c)
+	}
+
+	// Test case 5: Mistakes are reported
+	for _, text := range []string{"go test", "`go test", "`go test` timeout=soon", "`go test` retries=3", "`go test` env=NOVALUE", "`go test` needs="} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("Expected an error for %q", text)
+		}
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on a POSIX shell")
+	}
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	r := &Runner{Dir: dir}
+
+	// Test case 1: Commands get their working directory and environment
+	results, err := r.RunAll(context.Background(), []Command{
+		{Run: `echo "$GREETING from $(basename "$PWD")"`, Dir: "sub", Env: []string{"GREETING=hello"}},
+	}, 1)
+	if err != nil || len(results) != 1 || results[0].Err != nil || strings.TrimSpace(results[0].Output) != "hello from sub" {
+		t.Fatalf("Expected 'hello from sub', got %+v (%v)", results, err)
+	}
+
+	// Test case 2: A failure skips the commands that need it, unless it continues on error
+	var started []string
+	r.OnStart = func(c Command) { started = append(started, c.Run) }
+	results, err = r.RunAll(context.Background(), []Command{
+		{Run: "exit 3", Name: "lint", ContinueOnError: true},
+		{Run: "echo built >&2; exit 1", Name: "build", Needs: []string{"lint"}},
+		{Run: "echo never", Needs: []string{"build"}},
+		{Run: "echo independent"},
+	}, 1)
+	if err != nil {
+		t.Fatalf("RunAll returned an error: %v", err)
+	}
+	var statuses []string
+	for _, result := range results {
+		statuses = append(statuses, result.Status())
+	}
+	if expected := []string{StatusIgnored, StatusFailed, StatusSkipped, StatusPassed}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected statuses %v, got %v", expected, statuses)
+	}
+	if results[0].ExitCode != 3 || results[1].Stderr != "built\n" || results[1].Stdout != "" || len(started) != 3 {
+		t.Errorf("Expected exit code 3, stderr captured and three commands started, got %+v", results)
+	}
+	if report := NewReport(results); report.Passed || len(report.Steps) != 4 || report.Steps[2].Error != "skipped because build failed" {
+		t.Errorf("Expected a failed report with the skip reason, got %+v", report)
+	}
+
+	// Test case 3: Independent commands run in parallel and dependent ones wait
+	r.OnStart = nil
+	var output bytes.Buffer
+	r.Stdout = &output
+	marker := filepath.Join(dir, "marker")
+	start := time.Now()
+	results, err = r.RunAll(context.Background(), []Command{
+		{Run: "sleep 0.5; echo a", Name: "a"},
+		{Run: "sleep 0.5; touch marker; printf b", Name: "b"},
+		{Run: "test -f marker && echo c", Needs: []string{"b"}},
+	}, 2)
+	if err != nil || !NewReport(results).Passed {
+		t.Fatalf("Expected every command to pass, got %+v (%v)", results, err)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("Expected a and b to run at the same time, took %v", elapsed)
+	}
+	for _, line := range []string{"[a] a\n", "[b] b\n", "[test -f marker && echo c] c\n"} {
+		if !strings.Contains(output.String(), line) {
+			t.Errorf("Expected output line %q, got %q", line, output.String())
+		}
+	}
+	os.Remove(marker)
+	r.Stdout = nil
+
+	// Test case 4: Unknown and circular needs are refused before anything runs
+	for _, commands := range [][]Command{
+		{{Run: "true", Needs: []string{"missing"}}},
+		{{Run: "true", Name: "x", Needs: []string{"y"}}, {Run: "true", Name: "y", Needs: []string{"x"}}},
+		{{Run: "true", Name: "x"}, {Run: "false", Name: "x"}},
+	} {
+		if _, err := r.RunAll(context.Background(), commands, 1); err == nil {
+			t.Errorf("Expected an error for %+v", commands)
+		}
+	}
+
+	// Test case 5: Only lists that use needs spell out their order
+	if HasNeeds([]Command{{Run: "npm install"}, {Run: "npm test"}}) || !HasNeeds([]Command{{Run: "true", Name: "x"}, {Run: "true", Needs: []string{"x"}}}) {
+		t.Errorf("Expected only the list with needs to declare its order")
+	}
+
+	// Test case 6: A timeout stops the command and says so
+	result := r.Run(context.Background(), Command{Run: "sleep 10", Timeout: 100 * time.Millisecond})
+	if result.Err == nil || !strings.Contains(result.Err.Error(), "timed out after 100ms") {
+		t.Errorf("Expected a timeout error, got %v", result.Err)
+	}
+}
@@ -0,0 +1,382 @@
+package scaffold
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/productdevtool/pdt-cli/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Stack is a language or toolchain found in a project, with the commands pdt init
+// proposes for it.
+type Stack struct {
+	Name string
+	// Marker is the file the stack was recognised by.
+	Marker     string
+	Build      string
+	Deploy     string
+	Validation []config.Command
+}
+
+// Detect returns the stacks of the project in root, recognised by their go.mod,
+// package.json, pyproject.toml or Cargo.toml.
+func Detect(root string) ([]Stack, error) {
+	detectors := []struct {
+		marker string
+		detect func(root string, data []byte) (Stack, error)
+	}{
+		{"go.mod", detectGo},
+		{"package.json", detectNode},
+		{"pyproject.toml", detectPython},
+		{"Cargo.toml", detectRust},
+	}
+	var stacks []Stack
+	for _, d := range detectors {
+		data, err := os.ReadFile(filepath.Join(root, d.marker))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		stack, err := d.detect(root, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.marker, err)
+		}
+		stack.Marker = d.marker
+		stacks = append(stacks, stack)
+	}
+	return stacks, nil
+}
+
+func detectGo(root string, data []byte) (Stack, error) {
+	return Stack{
+		Name:  "Go",
+		Build: "go build ./...",
+		Validation: []config.Command{
+			{Run: "go vet ./...", Name: "vet"},
+			{Run: "go test ./...", Name: "test"},
+		},
+	}, nil
+}
+
+// npmPlaceholderTest is the test script npm init writes, which always fails.
+const npmPlaceholderTest = `echo "Error: no test specified" && exit 1`
+
+func detectNode(root string, data []byte) (Stack, error) {
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return Stack{}, err
+	}
+
+	manager := "npm"
+	for _, lockfile := range []struct{ name, manager string }{{"pnpm-lock.yaml", "pnpm"}, {"yarn.lock", "yarn"}, {"bun.lockb", "bun"}} {
+		if _, err := os.Stat(filepath.Join(root, lockfile.name)); err == nil {
+			manager = lockfile.manager
+			break
+		}
+	}
+	script := func(name string) string {
+		if pkg.Scripts[name] == "" {
+			return ""
+		}
+		return manager + " run " + name
+	}
+
+	stack := Stack{Name: "Node.js", Build: script("build"), Deploy: script("deploy")}
+	for _, name := range []string{"lint", "typecheck"} {
+		if run := script(name); run != "" {
+			stack.Validation = append(stack.Validation, config.Command{Run: run, Name: name})
+		}
+	}
+	if test := pkg.Scripts["test"]; test != "" && test != npmPlaceholderTest {
+		stack.Validation = append(stack.Validation, config.Command{Run: manager + " test", Name: "test"})
+	}
+	return stack, nil
+}
+
+func detectPython(root string, data []byte) (Stack, error) {
+	sections := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			// [tool.pytest.ini_options] also counts as [tool.pytest] and [tool].
+			parts := strings.Split(strings.Trim(line, "[] "), ".")
+			for i := range parts {
+				sections[strings.Join(parts[:i+1], ".")] = true
+			}
+		}
+	}
+
+	// Run the tools through the project's environment manager when it has one.
+	runner := ""
+	if _, err := os.Stat(filepath.Join(root, "uv.lock")); err == nil {
+		runner = "uv run "
+	} else if _, err := os.Stat(filepath.Join(root, "poetry.lock")); err == nil {
+		runner = "poetry run "
+	}
+
+	stack := Stack{Name: "Python"}
+	if sections["build-system"] {
+		stack.Build = "python -m build"
+	}
+	if sections["tool.ruff"] {
+		stack.Validation = append(stack.Validation, config.Command{Run: runner + "ruff check .", Name: "lint"})
+	}
+	if sections["tool.mypy"] {
+		stack.Validation = append(stack.Validation, config.Command{Run: runner + "mypy .", Name: "typecheck"})
+	}
+	stack.Validation = append(stack.Validation, config.Command{Run: runner + "pytest", Name: "test"})
+	return stack, nil
+}
+
+func detectRust(root string, data []byte) (Stack, error) {
+	return Stack{
+		Name:  "Rust",
+		Build: "cargo build",
+		Validation: []config.Command{
+			{Run: "cargo clippy --all-targets", Name: "clippy"},
+			{Run: "cargo test", Name: "test"},
+		},
+	}, nil
+}
+
+// Options are what pdt init writes: the project's name and stacks and the commands
+// chosen for it.
+type Options struct {
+	Name       string
+	Stacks     []Stack
+	Build      string
+	Deploy     string
+	Validation []config.Command
+}
+
+// Propose returns the options pdt init suggests for a project named name with the
+// given stacks. The commands of several stacks are combined, and validation steps
+// that would share a name are told apart by their stack.
+func Propose(name string, stacks []Stack) Options {
+	o := Options{Name: name, Stacks: stacks}
+	var builds, deploys []string
+	count := map[string]int{}
+	for _, s := range stacks {
+		for _, step := range s.Validation {
+			count[step.Name]++
+		}
+	}
+	for _, s := range stacks {
+		if s.Build != "" {
+			builds = append(builds, s.Build)
+		}
+		if s.Deploy != "" {
+			deploys = append(deploys, s.Deploy)
+		}
+		for _, step := range s.Validation {
+			if count[step.Name] > 1 {
+				step.Name = stackID(s) + "-" + step.Name
+			}
+			o.Validation = append(o.Validation, step)
+		}
+	}
+	o.Build = strings.Join(builds, " && ")
+	o.Deploy = strings.Join(deploys, " && ")
+	return o
+}
+
+// stackID is the short lower-case name of a stack, such as node for Node.js.
+func stackID(s Stack) string {
+	return strings.ToLower(strings.TrimSuffix(s.Name, ".js"))
+}
+
+// ConfigFile returns the pdt.yaml for the options. Commands that are empty are left
+// as comments to fill in.
+func ConfigFile(o Options) string {
+	var b strings.Builder
+	b.WriteString("# pdt project configuration. Run `pdt config list` to see every setting and\n")
+	b.WriteString("# `pdt config explain <key>` to see where a value comes from.\n")
+	b.WriteString("commands:\n")
+	for _, c := range []struct{ name, run, example string }{
+		{"build", o.Build, "make build"},
+		{"deploy", o.Deploy, "./scripts/deploy.sh production"},
+	} {
+		if c.run == "" {
+			fmt.Fprintf(&b, "  # %s: %s\n", c.name, c.example)
+		} else {
+			fmt.Fprintf(&b, "  %s: %s\n", c.name, scalar(c.run))
+		}
+	}
+
+	b.WriteString("validation:\n  steps:\n")
+	if len(o.Validation) == 0 {
+		b.WriteString("    # - run: make test\n    #   name: test\n")
+	}
+	for _, step := range o.Validation {
+		fmt.Fprintf(&b, "    - run: %s\n", scalar(step.Run))
+		if step.Name != "" {
+			fmt.Fprintf(&b, "      name: %s\n", scalar(step.Name))
+		}
+	}
+	return b.String()
+}
+
+// scalar writes s as a YAML value, quoting it when it needs to be.
+func scalar(s string) string {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Sprintf("%q", s)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ProjectDescription returns the skeleton of a project description for the AI and
+// the people working on the project to fill in.
+func ProjectDescription(o Options) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", o.Name)
+	b.WriteString("## Overview\n\nWhat the project does, and who it is for.\n\n")
+	b.WriteString("## Stack\n\n")
+	if len(o.Stacks) == 0 {
+		b.WriteString("The languages, frameworks and services the project uses.\n")
+	}
+	for _, s := range o.Stacks {
+		fmt.Fprintf(&b, "- %s (%s)\n", s.Name, s.Marker)
+	}
+	b.WriteString("\n## Structure\n\nThe main directories and what lives in each.\n\n")
+	b.WriteString("## Conventions\n\nThe coding conventions, patterns and libraries new code should follow.\n")
+	return b.String()
+}
+
+// File is a file pdt init creates.
+type File struct {
+	Path    string
+	Content string
+}
+
+// Files returns the files that set a project up for pdt: the configuration file, the
+// documents and directories under paths, and the templates skeleton.
+func Files(o Options, paths config.Paths) []File {
+	files := []File{
+		{Path: config.FileNames[0], Content: ConfigFile(o)},
+		{Path: paths.ProjectDescription, Content: ProjectDescription(o)},
+		{Path: paths.Todo, Content: "# Todo\n\n"},
+		{Path: filepath.Join(paths.WorkDir(), ".gitkeep")},
+		{Path: filepath.Join(paths.DoneDir(), ".gitkeep")},
+		{Path: filepath.Join(paths.Specs, ".gitkeep")},
+		{Path: filepath.Join(paths.Handbook, ".gitkeep")},
+		{Path: filepath.Join(paths.Templates, "README.md"), Content: templatesReadme},
+	}
+	for _, s := range o.Stacks {
+		files = append(files, File{Path: filepath.Join(paths.Templates, stackID(s), ".gitkeep")})
+	}
+	return files
+}
+
+const templatesReadme = `# Templates
+
+Code templates the AI adapts when it implements a task, so new code follows the
+project's architecture and conventions instead of being written from scratch.
+Organise them by framework or component type, for example ` + "`react/`" + ` for
+components and ` + "`db/`" + ` for schemas and queries.
+`
+
+// Write creates the files under root that don't exist yet, and returns the paths of
+// those it created and those it left alone.
+func Write(root string, files []File) (created []string, existing []string, err error) {
+	for _, f := range files {
+		path := filepath.Join(root, f.Path)
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, f.Path)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return created, existing, err
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return created, existing, err
+		}
+		created = append(created, f.Path)
+	}
+	return created, existing, nil
+}
+
+// GitignoreEntries returns pdt's local state, which doesn't belong in version control:
+// the cache and journal directories, the usage ledger and the validation report where
+// c and getenv put them. Those outside the project are left out.
+func GitignoreEntries(c *config.Config, getenv func(string) string) []string {
+	var entries []string
+	for _, state := range []struct {
+		key string
+		dir bool
+	}{
+		{"cache.dir", true},
+		{"write.journal_dir", true},
+		{"usage.ledger", false},
+		{"validation.report", false},
+	} {
+		origin, err := c.Resolve(state.key, getenv, nil)
+		if err != nil || origin.Value == "" || filepath.IsAbs(origin.Value) {
+			continue
+		}
+		entry := filepath.ToSlash(filepath.Clean(origin.Value))
+		if entry == "." || entry == ".." || strings.HasPrefix(entry, "../") {
+			continue
+		}
+		if state.dir {
+			entry += "/"
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// AddToGitignore appends the entries that path doesn't list yet, neither themselves
+// nor a directory they are in, creating it if needed, and returns those it added.
+func AddToGitignore(path string, entries []string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	listed := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		listed[strings.TrimSpace(line)] = true
+	}
+	ignored := func(entry string) bool {
+		name := strings.TrimSuffix(entry, "/")
+		for dir := name; dir != "." && dir != "/"; dir = filepath.ToSlash(filepath.Dir(dir)) {
+			if listed[dir] || listed[dir+"/"] || listed["/"+dir] || listed["/"+dir+"/"] {
+				return true
+			}
+		}
+		return listed[entry] || listed["/"+entry]
+	}
+	var added []string
+	for _, entry := range entries {
+		if !ignored(entry) {
+			added = append(added, entry)
+		}
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	var b strings.Builder
+	b.Write(data)
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		b.WriteString("\n")
+	}
+	if len(data) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("# pdt\n")
+	for _, entry := range added {
+		b.WriteString(entry + "\n")
+	}
+	return added, os.WriteFile(path, []byte(b.String()), 0644)
+}
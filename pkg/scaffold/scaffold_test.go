@@ -0,0 +1,160 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/productdevtool/pdt-cli/pkg/config"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		build      string
+		validation []string
+	}{
+		{
+			name:       "Go",
+			files:      map[string]string{"go.mod": "module example.com/app\n"},
+			build:      "go build ./...",
+			validation: []string{"go vet ./...", "go test ./..."},
+		},
+		{
+			name: "Node.js with pnpm",
+			files: map[string]string{
+				"package.json":   `{"scripts": {"build": "next build", "lint": "eslint .", "test": "vitest run"}}`,
+				"pnpm-lock.yaml": "",
+			},
+			build:      "pnpm run build",
+			validation: []string{"pnpm run lint", "pnpm test"},
+		},
+		{
+			name:       "Node.js with the npm placeholder test",
+			files:      map[string]string{"package.json": `{"scripts": {"test": "echo \"Error: no test specified\" && exit 1"}}`},
+			validation: nil,
+		},
+		{
+			name: "Python with uv",
+			files: map[string]string{
+				"pyproject.toml": "[project]\nname = \"api\"\n\n[tool.ruff]\nline-length = 100\n\n[tool.pytest.ini_options]\n",
+				"uv.lock":        "",
+			},
+			validation: []string{"uv run ruff check .", "uv run pytest"},
+		},
+		{
+			name:       "Rust",
+			files:      map[string]string{"Cargo.toml": "[package]\nname = \"cli\"\n"},
+			build:      "cargo build",
+			validation: []string{"cargo clippy --all-targets", "cargo test"},
+		},
+	}
+
+	// Test case: Each stack is recognised by its marker file and gets its usual commands
+	for _, test := range tests {
+		dir := t.TempDir()
+		for name, content := range test.files {
+			os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		}
+		stacks, err := Detect(dir)
+		if err != nil || len(stacks) != 1 {
+			t.Errorf("%s: Expected one stack, got %+v (%v)", test.name, stacks, err)
+			continue
+		}
+		var validation []string
+		for _, step := range stacks[0].Validation {
+			validation = append(validation, step.Run)
+		}
+		if stacks[0].Build != test.build || !reflect.DeepEqual(validation, test.validation) {
+			t.Errorf("%s: Expected build %q and validation %v, got %q and %v", test.name, test.build, test.validation, stacks[0].Build, validation)
+		}
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	stacks := []Stack{
+		{Name: "Go", Marker: "go.mod", Build: "go build ./...", Validation: []config.Command{{Run: "go test ./...", Name: "test"}}},
+		{Name: "Node.js", Marker: "package.json", Build: "npm run build", Validation: []config.Command{{Run: "npm test", Name: "test"}}},
+	}
+
+	// Test case 1: The commands of several stacks are combined without clashing step names
+	o := Propose("app", stacks)
+	if o.Build != "go build ./... && npm run build" || o.Validation[0].Name != "go-test" || o.Validation[1].Name != "node-test" {
+		t.Errorf("Expected combined commands, got %+v", o)
+	}
+
+	// Test case 2: The configuration file is valid and holds the commands
+	c, err := config.Parse([]byte(ConfigFile(o)))
+	if err != nil {
+		t.Fatalf("Expected a valid configuration file, got %v:\n%s", err, ConfigFile(o))
+	}
+	if c.Commands["build"].Run != o.Build || len(c.Validation.Steps) != 2 || c.Validation.Steps[1].Run != "npm test" {
+		t.Errorf("Expected the build command and two steps, got %+v %+v", c.Commands, c.Validation.Steps)
+	}
+
+	// Test case 3: Without commands the file is still valid, with examples left as comments
+	data := ConfigFile(Options{Name: "app"})
+	if _, err := config.Parse([]byte(data)); err != nil || !strings.Contains(data, "# build: make build") {
+		t.Errorf("Expected a valid file with commented examples, got %v:\n%s", err, data)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	os.WriteFile(filepath.Join(dir, "docs", "todo.md"), []byte("- Ship it\n"), 0644)
+
+	// Test case 1: Missing files are created and existing ones left alone
+	files := Files(Propose("app", nil), config.Default().Paths)
+	created, existing, err := Write(dir, files)
+	if err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(existing, []string{"docs/todo.md"}) || len(created) != len(files)-1 {
+		t.Errorf("Expected everything but docs/todo.md to be created, got %v and %v", created, existing)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "docs", "todo.md")); string(data) != "- Ship it\n" {
+		t.Errorf("Expected the todo file to be kept, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs", "todos", "work")); err != nil {
+		t.Errorf("Expected the work directory, got %v", err)
+	}
+
+	// Test case 2: .gitignore gets the entries it lacks, once
+	gitignore := filepath.Join(dir, ".gitignore")
+	os.WriteFile(gitignore, []byte("node_modules/\n.pdt/cache/"), 0644)
+	entries := GitignoreEntries(config.Default(), func(string) string { return "" })
+	added, err := AddToGitignore(gitignore, entries)
+	if err != nil || len(added) != len(entries)-1 {
+		t.Errorf("Expected all entries but .pdt/cache/ to be added, got %v (%v)", added, err)
+	}
+	if added, _ := AddToGitignore(gitignore, entries); len(added) != 0 {
+		t.Errorf("Expected nothing to be added twice, got %v", added)
+	}
+	if data, _ := os.ReadFile(gitignore); !strings.HasPrefix(string(data), "node_modules/\n.pdt/cache/\n\n# pdt\n.pdt/journal/\n") {
+		t.Errorf("Expected the entries appended after the existing ones, got %q", data)
+	}
+
+	// Test case 3: The entries follow the configured locations, leaving out those outside the project
+	project := t.TempDir()
+	t.Setenv("PDT_USER_CONFIG", filepath.Join(project, "user.yaml"))
+	os.WriteFile(filepath.Join(project, "pdt.yaml"), []byte("cache:\n  dir: /var/cache/pdt\nusage:\n  ledger: var/usage.jsonl\n"), 0644)
+	c, err := config.Load(project)
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	env := map[string]string{"PDT_JOURNAL_DIR": "tmp/journal"}
+	entries = GitignoreEntries(c, func(name string) string { return env[name] })
+	if !reflect.DeepEqual(entries, []string{"tmp/journal/", "var/usage.jsonl", ".pdt/validation.json"}) {
+		t.Errorf("Expected the configured locations, got %v", entries)
+	}
+
+	// Test case 4: An ignored parent directory covers the entries in it
+	os.WriteFile(gitignore, []byte("/tmp/\n.pdt\n"), 0644)
+	if added, _ := AddToGitignore(gitignore, entries); !reflect.DeepEqual(added, []string{"var/usage.jsonl"}) {
+		t.Errorf("Expected only var/usage.jsonl to be added, got %v", added)
+	}
+}
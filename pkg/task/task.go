@@ -6,10 +6,9 @@ import (
 	"path/filepath"
 )
 
-// GetActiveTask returns the path to the active task directory.
+// GetActiveTask returns the path to the active task directory in workDir.
 // It returns an error if there is not exactly one task in the work directory.
-func GetActiveTask() (string, error) {
-	workDir := "docs/todos/work"
+func GetActiveTask(workDir string) (string, error) {
 	files, err := ioutil.ReadDir(workDir)
 	if err != nil {
 		return "", err
@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/productdevtool/pdt-cli/pkg/fs"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const redrawInterval = 100 * time.Millisecond
+
+// Options configures a Progress.
+type Options struct {
+	// Quiet hides the streamed text and the status line; completed file blocks are
+	// still listed so CI logs show what was generated.
+	Quiet bool
+	// Live redraws a status line in place. It should only be set when the output
+	// is a terminal.
+	Live bool
+	// Count estimates the number of tokens in the text received so far.
+	Count func(text string) int
+}
+
+// Progress renders a streamed AI response: the text as it arrives, a line for each
+// file block as soon as it is complete, and a status line with the elapsed time and
+// token count. Other output written through Progress while it runs is printed above
+// the status line.
+type Progress struct {
+	out  io.Writer
+	opts Options
+
+	mu      sync.Mutex
+	start   time.Time
+	text    strings.Builder
+	pending string
+	blocks  int
+	files   []string
+	frame   int
+	running bool
+	drawn   bool
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewProgress returns a Progress that writes to out.
+func NewProgress(out io.Writer, opts Options) *Progress {
+	if opts.Count == nil {
+		opts.Count = func(text string) int { return len(strings.Fields(text)) }
+	}
+	return &Progress{out: out, opts: opts}
+}
+
+// Start starts the clock and, in live mode, the status line.
+func (p *Progress) Start() {
+	p.mu.Lock()
+	p.start = time.Now()
+	p.mu.Unlock()
+
+	if !p.opts.Live || p.opts.Quiet {
+		return
+	}
+	p.mu.Lock()
+	p.running = true
+	p.mu.Unlock()
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(redrawInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.frame++
+				p.drawStatus()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Stream adds generated text. Complete lines are printed, and a file block is
+// reported as soon as its closing fence arrives.
+func (p *Progress) Stream(text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.text.WriteString(text)
+	p.pending += text
+	for {
+		i := strings.IndexByte(p.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := p.pending[:i+1]
+		p.pending = p.pending[i+1:]
+		p.printLine(line)
+	}
+}
+
+// Restart discards the text received so far because the call is being retried.
+func (p *Progress) Restart() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clearStatus()
+	if !p.opts.Quiet && p.pending != "" {
+		fmt.Fprintln(p.out, p.pending)
+	}
+	if p.text.Len() > 0 {
+		color.New(color.FgYellow).Fprintln(p.out, "Discarding the partial response.")
+	}
+	p.text.Reset()
+	p.pending = ""
+	p.blocks = 0
+	p.files = nil
+}
+
+// Write prints other output, such as warnings, above the status line.
+func (p *Progress) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clearStatus()
+	n, err := p.out.Write(data)
+	p.drawStatus()
+	return n, err
+}
+
+// Stop stops the status line and prints any unfinished last line of text.
+func (p *Progress) Stop() {
+	if p.stop != nil {
+		close(p.stop)
+		<-p.stopped
+		p.stop = nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = false
+	p.clearStatus()
+	if p.pending != "" {
+		line := p.pending + "\n"
+		p.pending = ""
+		p.printLine(line)
+	}
+}
+
+// Elapsed returns the time since Start.
+func (p *Progress) Elapsed() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Since(p.start)
+}
+
+// Tokens returns the estimated token count of the text received so far.
+func (p *Progress) Tokens() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.opts.Count(p.text.String())
+}
+
+// Files returns the paths of the file blocks completed so far.
+func (p *Progress) Files() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.files...)
+}
+
+// printLine prints one line of streamed text and reports any file block it closes.
+// It must be called with the lock held.
+func (p *Progress) printLine(line string) {
+	p.clearStatus()
+	if !p.opts.Quiet {
+		fmt.Fprint(p.out, line)
+	}
+
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") {
+		return
+	}
+	// Only the text up to this line is parsed, so a block counts once it is closed.
+	received := strings.TrimSuffix(p.text.String(), p.pending)
+	blocks, _ := fs.ParseCodeBlocks(received)
+	if len(blocks) > 0 && blocks[len(blocks)-1].Truncated {
+		blocks = blocks[:len(blocks)-1]
+	}
+	if len(blocks) <= p.blocks {
+		return
+	}
+	for _, block := range blocks[p.blocks:] {
+		p.blocks++
+		if block.Language == fs.OpsLanguage {
+			ops, _ := fs.ParseFileCommands(block.Content, block.Line+1)
+			for _, op := range ops {
+				color.New(color.FgGreen).Fprintf(p.out, "✔ %s %s\n", op.Action, op.Path)
+			}
+			continue
+		}
+		path := block.FilePath
+		if path == "" {
+			path = "(no file path)"
+		}
+		p.files = append(p.files, path)
+		color.New(color.FgGreen).Fprintf(p.out, "✔ %s (%d lines)\n", path, strings.Count(block.Content, "\n"))
+	}
+}
+
+// drawStatus draws the status line in live mode. It must be called with the lock held.
+func (p *Progress) drawStatus() {
+	if !p.running {
+		return
+	}
+	status := fmt.Sprintf("%s %s", spinnerFrames[p.frame%len(spinnerFrames)], time.Since(p.start).Round(time.Second))
+	if p.text.Len() > 0 {
+		status += fmt.Sprintf(" · ~%d tokens", p.opts.Count(p.text.String()))
+	} else {
+		status += " · waiting for the first tokens"
+	}
+	if len(p.files) > 0 {
+		status += fmt.Sprintf(" · %d files", len(p.files))
+	}
+	fmt.Fprint(p.out, "\r\033[K"+color.New(color.FgCyan).Sprint(status))
+	p.drawn = true
+}
+
+// clearStatus erases the status line. It must be called with the lock held.
+func (p *Progress) clearStatus() {
+	if p.drawn {
+		fmt.Fprint(p.out, "\r\033[K")
+		p.drawn = false
+	}
+}
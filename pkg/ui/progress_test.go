@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestProgressReportsFileBlocks(t *testing.T) {
+	color.NoColor = true
+	var out bytes.Buffer
+	p := NewProgress(&out, Options{})
+	p.Start()
+
+	// Test case 1: A block is reported once its closing fence arrives, even when
+	// chunks split lines
+	p.Stream("Here is the code:\n```go // main.go\npack")
+	p.Stream("age main\n")
+	if len(p.Files()) != 0 {
+		t.Errorf("Expected no completed files yet, got %v", p.Files())
+	}
+	p.Stream("```\nDone.")
+	expected := []string{"main.go"}
+	if !reflect.DeepEqual(expected, p.Files()) {
+		t.Errorf("Expected %v, got %v", expected, p.Files())
+	}
+
+	// Test case 2: Stop prints the unfinished last line
+	p.Stop()
+	if !strings.Contains(out.String(), "package main\n") || !strings.HasSuffix(out.String(), "Done.\n") {
+		t.Errorf("Expected the streamed text to be printed, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "✔ main.go (1 lines)") {
+		t.Errorf("Expected the completed file to be listed, got %q", out.String())
+	}
+}
+
+func TestProgressQuietAndRestart(t *testing.T) {
+	color.NoColor = true
+	var out bytes.Buffer
+	p := NewProgress(&out, Options{Quiet: true})
+	p.Start()
+
+	// Test case 1: Quiet mode only lists files
+	p.Stream("```go // a.go\npackage a\n```\nchatter\n")
+	if strings.Contains(out.String(), "package a") || strings.Contains(out.String(), "chatter") {
+		t.Errorf("Expected quiet mode to hide the streamed text, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "✔ a.go") {
+		t.Errorf("Expected quiet mode to list files, got %q", out.String())
+	}
+
+	// Test case 2: Restart discards what was received
+	p.Restart()
+	p.Stream("```go // b.go\npackage b\n```\n")
+	p.Stop()
+	if !reflect.DeepEqual([]string{"b.go"}, p.Files()) {
+		t.Errorf("Expected only b.go after the restart, got %v", p.Files())
+	}
+}
@@ -0,0 +1,105 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/productdevtool/pdt-cli/pkg/ai"
+)
+
+// BudgetExceededError is returned instead of calling the AI once the monthly cap is reached.
+type BudgetExceededError struct {
+	Spent  float64
+	Budget float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("monthly AI budget of $%.2f reached ($%.2f spent this month); raise the budget or wait for next month", e.Budget, e.Spent)
+}
+
+// Meter wraps a Provider and appends a ledger record for every call it makes.
+type Meter struct {
+	provider ai.Provider
+	ledger   *Ledger
+	prices   PriceTable
+
+	// Command and Task label the records.
+	Command string
+	Task    string
+	// MonthlyBudget stops calls once this month's spend reaches it; 0 disables the cap.
+	MonthlyBudget float64
+	// AlertAt is the fraction of the budget that triggers OnAlert, e.g. 0.8.
+	AlertAt float64
+	OnAlert func(spent float64, budget float64)
+	// OnUnpriced is told, when there is a budget, about calls to a model the price
+	// table doesn't list, which the budget can't count.
+	OnUnpriced func(target string)
+	// OnError is told about ledger failures, which never fail the AI call itself.
+	OnError func(err error)
+}
+
+// NewMeter returns p wrapped so that its calls are recorded in ledger.
+func NewMeter(p ai.Provider, ledger *Ledger, prices PriceTable) *Meter {
+	return &Meter{provider: p, ledger: ledger, prices: prices, AlertAt: 0.8}
+}
+
+// Name returns the wrapped provider's name.
+func (m *Meter) Name() string { return m.provider.Name() }
+
+// Model returns the wrapped provider's model.
+func (m *Meter) Model() string { return m.provider.Model() }
+
+// Complete checks the monthly budget, calls the provider and records the call.
+func (m *Meter) Complete(ctx context.Context, req ai.Request) (*ai.Response, error) {
+	if m.MonthlyBudget > 0 {
+		spent, err := m.ledger.MonthToDate(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if spent >= m.MonthlyBudget {
+			return nil, &BudgetExceededError{Spent: spent, Budget: m.MonthlyBudget}
+		}
+	}
+
+	start := time.Now()
+	resp, err := m.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	record := Record{
+		Time:           start.UTC(),
+		Command:        m.Command,
+		Task:           m.Task,
+		Provider:       resp.Provider,
+		Model:          resp.Model,
+		PromptTokens:   resp.PromptTokens,
+		ResponseTokens: resp.ResponseTokens,
+		LatencyMs:      time.Since(start).Milliseconds(),
+	}
+	if record.PromptTokens == 0 && record.ResponseTokens == 0 {
+		record.PromptTokens = ai.EstimateTokens(resp.Provider, req.Prompt)
+		record.ResponseTokens = ai.EstimateTokens(resp.Provider, resp.Text)
+		record.Estimated = true
+	}
+	cost, priced := m.prices.Cost(record.Model, record.PromptTokens, record.ResponseTokens)
+	record.Cost, record.Unpriced = cost, !priced
+
+	if err := m.ledger.Append(record); err != nil {
+		if m.OnError != nil {
+			m.OnError(err)
+		}
+		return resp, nil
+	}
+
+	if m.MonthlyBudget > 0 && record.Unpriced && m.OnUnpriced != nil {
+		m.OnUnpriced(record.Target())
+	}
+	if m.MonthlyBudget > 0 && m.OnAlert != nil {
+		if spent, err := m.ledger.MonthToDate(time.Now()); err == nil && spent >= m.MonthlyBudget*m.AlertAt {
+			m.OnAlert(spent, m.MonthlyBudget)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,252 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Record is one AI invocation in the ledger.
+type Record struct {
+	Time           time.Time `json:"time"`
+	Command        string    `json:"command"`
+	Task           string    `json:"task,omitempty"`
+	Provider       string    `json:"provider"`
+	Model          string    `json:"model"`
+	PromptTokens   int       `json:"prompt_tokens"`
+	ResponseTokens int       `json:"response_tokens"`
+	LatencyMs      int64     `json:"latency_ms"`
+	Cost           float64   `json:"cost_usd"`
+	// Estimated is set when the backend didn't report token counts.
+	Estimated bool `json:"estimated,omitempty"`
+	// Unpriced is set when the price table has no price for the model, so Cost is 0
+	// without the call being free.
+	Unpriced bool `json:"unpriced,omitempty"`
+}
+
+// Target names the provider and model of the record, as Summarize groups them.
+func (r Record) Target() string {
+	if r.Model == "" {
+		return r.Provider + "/(default model)"
+	}
+	return r.Provider + "/" + r.Model
+}
+
+// Unpriced returns the targets of the records the price table had no price for,
+// sorted and without repeats.
+func Unpriced(records []Record) []string {
+	seen := map[string]bool{}
+	var targets []string
+	for _, r := range records {
+		if r.Unpriced && !seen[r.Target()] {
+			seen[r.Target()] = true
+			targets = append(targets, r.Target())
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Ledger is an append-only JSON Lines file of usage records.
+type Ledger struct {
+	Path string
+}
+
+// Append adds a record to the ledger, creating it if needed.
+func (l *Ledger) Append(r Record) error {
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return fmt.Errorf("error creating usage ledger directory: %w", err)
+	}
+	file, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing usage ledger: %w", err)
+	}
+	return nil
+}
+
+// Load returns every record at or after since. A missing ledger has no records.
+func (l *Ledger) Load(since time.Time) ([]Record, error) {
+	file, err := os.Open(l.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid usage record: %w", l.Path, lineNumber, err)
+		}
+		if !r.Time.Before(since) {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// MonthToDate returns the total cost of records in the calendar month containing now.
+func (l *Ledger) MonthToDate(now time.Time) (float64, error) {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	records, err := l.Load(monthStart)
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, r := range records {
+		total += r.Cost
+	}
+	return total, nil
+}
+
+// Summary aggregates records that share a key.
+type Summary struct {
+	Key            string
+	Calls          int
+	PromptTokens   int
+	ResponseTokens int
+	Cost           float64
+	Latency        time.Duration
+}
+
+// Groupings accepted by Summarize.
+const (
+	ByDay     = "day"
+	ByCommand = "command"
+	ByTask    = "task"
+	ByModel   = "model"
+)
+
+// Summarize groups records by day, command, task or model, sorted by key.
+func Summarize(records []Record, by string) ([]Summary, error) {
+	var keyOf func(Record) string
+	switch by {
+	case ByDay:
+		keyOf = func(r Record) string { return r.Time.Local().Format("2006-01-02") }
+	case ByCommand:
+		keyOf = func(r Record) string { return r.Command }
+	case ByTask:
+		keyOf = func(r Record) string {
+			if r.Task == "" {
+				return "(no task)"
+			}
+			return r.Task
+		}
+	case ByModel:
+		keyOf = Record.Target
+	default:
+		return nil, fmt.Errorf("unknown grouping '%s' (expected %s, %s, %s or %s)", by, ByDay, ByCommand, ByTask, ByModel)
+	}
+
+	groups := map[string]*Summary{}
+	for _, r := range records {
+		key := keyOf(r)
+		s, ok := groups[key]
+		if !ok {
+			s = &Summary{Key: key}
+			groups[key] = s
+		}
+		s.Calls++
+		s.PromptTokens += r.PromptTokens
+		s.ResponseTokens += r.ResponseTokens
+		s.Cost += r.Cost
+		s.Latency += time.Duration(r.LatencyMs) * time.Millisecond
+	}
+
+	var summaries []Summary
+	for _, s := range groups {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Key < summaries[j].Key })
+	return summaries, nil
+}
+
+// Price is the cost of a model in US dollars per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// PriceTable maps model names, or model name prefixes, to prices.
+type PriceTable map[string]Price
+
+// DefaultPrices lists list prices for common models. Override them with a price file
+// for negotiated rates or models that aren't listed.
+func DefaultPrices() PriceTable {
+	return PriceTable{
+		"gemini-2.5-pro":        {Input: 1.25, Output: 10},
+		"gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+		"gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40},
+		"gemini-2.0-flash":      {Input: 0.10, Output: 0.40},
+		"gpt-4o":                {Input: 2.50, Output: 10},
+		"gpt-4o-mini":           {Input: 0.15, Output: 0.60},
+		"gpt-4.1":               {Input: 2, Output: 8},
+		"gpt-4.1-mini":          {Input: 0.40, Output: 1.60},
+		"gpt-4.1-nano":          {Input: 0.10, Output: 0.40},
+	}
+}
+
+// LoadPrices reads a JSON price file and merges it over the defaults.
+func LoadPrices(path string) (PriceTable, error) {
+	prices := DefaultPrices()
+	if path == "" {
+		return prices, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading price table: %w", err)
+	}
+	var overrides PriceTable
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("error parsing price table %s: %w", path, err)
+	}
+	for model, price := range overrides {
+		prices[model] = price
+	}
+	return prices, nil
+}
+
+// Cost estimates the cost of a call, and reports whether the table prices model. The
+// longest matching model prefix wins.
+func (t PriceTable) Cost(model string, promptTokens int, responseTokens int) (float64, bool) {
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" || model == "" {
+		return 0, false
+	}
+	price := t[best]
+	return (float64(promptTokens)*price.Input + float64(responseTokens)*price.Output) / 1e6, true
+}
@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/productdevtool/pdt-cli/pkg/ai"
+)
+
+type staticProvider struct{ calls int }
+
+func (p *staticProvider) Name() string  { return "openai" }
+func (p *staticProvider) Model() string { return "gpt-4o-mini" }
+
+func (p *staticProvider) Complete(ctx context.Context, req ai.Request) (*ai.Response, error) {
+	p.calls++
+	return &ai.Response{Text: "ok", Provider: "openai", Model: "gpt-4o-mini", PromptTokens: 1000000, ResponseTokens: 1000000}, nil
+}
+
+func TestPriceTableCost(t *testing.T) {
+	prices := PriceTable{"gpt-4o": {Input: 2.5, Output: 10}, "gpt-4o-mini": {Input: 0.15, Output: 0.6}}
+
+	// Test case 1: The longest matching prefix wins
+	if cost, priced := prices.Cost("gpt-4o-mini-2024-07-18", 1000000, 1000000); !priced || math.Abs(cost-0.75) > 1e-9 {
+		t.Errorf("Expected $0.75, got $%f", cost)
+	}
+
+	// Test case 2: Unknown models, and a tool's unnamed default, have no price rather than being free
+	for _, model := range []string{"llama3", ""} {
+		if cost, priced := prices.Cost(model, 1000, 1000); priced || cost != 0 {
+			t.Errorf("Expected no price for %q, got $%f", model, cost)
+		}
+	}
+}
+
+func TestMeterRecordsAndEnforcesBudget(t *testing.T) {
+	ledger := &Ledger{Path: filepath.Join(t.TempDir(), "usage.jsonl")}
+	p := &staticProvider{}
+	meter := NewMeter(p, ledger, DefaultPrices())
+	meter.Command = "code"
+	meter.Task = "add-login"
+	meter.MonthlyBudget = 1
+
+	// Test case 1: A call under budget is recorded with its cost
+	if _, err := meter.Complete(context.Background(), ai.Request{Prompt: "p"}); err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+	records, err := ledger.Load(time.Time{})
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	if len(records) != 1 || records[0].Command != "code" || records[0].Task != "add-login" || math.Abs(records[0].Cost-0.75) > 1e-9 {
+		t.Fatalf("Expected one $0.75 record for code/add-login, got %+v", records)
+	}
+
+	// Test case 2: Once the month's spend reaches the budget, calls are refused
+	meter.Complete(context.Background(), ai.Request{Prompt: "p"})
+	_, err = meter.Complete(context.Background(), ai.Request{Prompt: "p"})
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) {
+		t.Errorf("Expected a BudgetExceededError, got %v", err)
+	}
+	if p.calls != 2 {
+		t.Errorf("Expected 2 provider calls, got %d", p.calls)
+	}
+
+	// Test case 3: Records summarise by command
+	records, _ = ledger.Load(time.Time{})
+	summaries, err := Summarize(records, ByCommand)
+	if err != nil {
+		t.Fatalf("Summarize returned an error: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Key != "code" || summaries[0].Calls != 2 {
+		t.Errorf("Expected 2 calls for 'code', got %+v", summaries)
+	}
+}
+
+func TestMeterFlagsUnpricedModels(t *testing.T) {
+	ledger := &Ledger{Path: filepath.Join(t.TempDir(), "usage.jsonl")}
+	meter := NewMeter(&staticProvider{}, ledger, PriceTable{"gemini-2.5-pro": {Input: 1.25, Output: 10}})
+	meter.MonthlyBudget = 1
+	var warned []string
+	meter.OnUnpriced = func(target string) { warned = append(warned, target) }
+
+	// Test case 1: A call to a model without a price is recorded as unpriced and warned about
+	if _, err := meter.Complete(context.Background(), ai.Request{Prompt: "p"}); err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+	records, _ := ledger.Load(time.Time{})
+	if len(records) != 1 || !records[0].Unpriced || len(warned) != 1 || warned[0] != "openai/gpt-4o-mini" {
+		t.Errorf("Expected an unpriced record and a warning, got %+v and %v", records, warned)
+	}
+
+	// Test case 2: Unpriced lists each target once, naming a tool's default model
+	records = append(records, records[0], Record{Provider: "gemini", Unpriced: true}, Record{Provider: "openai", Model: "gpt-4o"})
+	if targets := Unpriced(records); !reflect.DeepEqual(targets, []string{"gemini/(default model)", "openai/gpt-4o-mini"}) {
+		t.Errorf("Expected the two unpriced targets, got %v", targets)
+	}
+}